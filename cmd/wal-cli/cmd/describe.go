@@ -2,6 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"maps"
+	"slices"
+	"time"
 
 	"github.com/spf13/cobra"
 
@@ -23,7 +26,13 @@ var describeCmd = &cobra.Command{
 			return fmt.Errorf("no segment found in %q", directory)
 		}
 
-		reader, err := wal.NewReader(directory, segments[0])
+		entryChecksumKey, err := readEntryChecksumKey()
+		if err != nil {
+			return err
+		}
+
+		// Control records are read as well, so that segments which only contain control records are described too.
+		reader, err := wal.NewReader(directory, segments[0], wal.WithReadOnly(), wal.WithReaderEntryChecksumKey(entryChecksumKey), wal.WithControlRecords())
 		if err != nil {
 			return err
 		}
@@ -43,6 +52,7 @@ var describeCmd = &cobra.Command{
 				fmt.Printf("Entry Length Encoding: %s\n", reader.Header().EntryLengthEncoding)
 				fmt.Printf("Entry Checksum Type:   %s\n", reader.Header().EntryChecksumType)
 				fmt.Printf("First Sequence Number: %d\n", reader.Header().FirstSequenceNumber)
+				describeMetadata(reader.Header().Metadata)
 				fmt.Println()
 			}
 
@@ -54,6 +64,28 @@ var describeCmd = &cobra.Command{
 	},
 }
 
+// describeMetadata prints the metadata of a segment header. Values which are not set are omitted.
+func describeMetadata(metadata wal.HeaderMetadata) {
+	if !metadata.CreatedAt.IsZero() {
+		fmt.Printf("Created At:            %s\n", metadata.CreatedAt.Format(time.RFC3339Nano))
+	}
+	if metadata.WriterID != "" {
+		fmt.Printf("Writer ID:             %s\n", metadata.WriterID)
+	}
+	if metadata.ApplicationID != "" {
+		fmt.Printf("Application ID:        %s\n", metadata.ApplicationID)
+	}
+	for _, key := range slices.Sorted(maps.Keys(metadata.UserData)) {
+		fmt.Printf("Metadata:              %s=%s\n", key, metadata.UserData[key])
+	}
+	if len(metadata.PreviousDigest) > 0 {
+		fmt.Printf("Previous Digest:       %x\n", metadata.PreviousDigest)
+	}
+	if metadata.Compression != wal.CompressionNone {
+		fmt.Printf("Compression:           %s\n", metadata.Compression)
+	}
+}
+
 func init() {
 	rootCmd.AddCommand(describeCmd)
 
@@ -11,6 +11,10 @@ import (
 var (
 	initEntryLengthEncoding string
 	initEntryChecksumType   string
+	initCompression         string
+	initWriterID            string
+	initApplicationID       string
+	initMetadata            map[string]string
 )
 
 // initCmd represents the init command.
@@ -48,11 +52,47 @@ var initCmd = &cobra.Command{
 			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeCrc32)
 		case "crc64":
 			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeCrc64)
+		case "crc32c":
+			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeCrc32c)
+		case "xxhash64":
+			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeXXHash64)
+		case "sha256-chain":
+			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeSha256Chain)
+		case "hmac-sha256-chain":
+			withEntryChecksumType = wal.WithEntryChecksumType(wal.EntryChecksumTypeHmacSha256Chain)
 		default:
 			return fmt.Errorf("unsupported entry checksum type %q", initEntryChecksumType)
 		}
 
-		if err := wal.Init(directory, withEntryLengthEncoding, withEntryChecksumType); err != nil {
+		var withCompression wal.WriterOption
+		switch initCompression {
+		case "none":
+			withCompression = wal.WithCompression(wal.CompressionNone)
+		case "flate":
+			withCompression = wal.WithCompression(wal.CompressionFlate)
+		case "flate-fast":
+			withCompression = wal.WithCompression(wal.CompressionFlateFast)
+		default:
+			return fmt.Errorf("unsupported compression %q", initCompression)
+		}
+
+		entryChecksumKey, err := readEntryChecksumKey()
+		if err != nil {
+			return err
+		}
+
+		options := []wal.WriterOption{
+			withEntryLengthEncoding,
+			withEntryChecksumType,
+			wal.WithEntryChecksumKey(entryChecksumKey),
+			withCompression,
+			wal.WithWriterID(initWriterID),
+			wal.WithApplicationID(initApplicationID),
+		}
+		for key, value := range initMetadata {
+			options = append(options, wal.WithUserMetadata(key, value))
+		}
+		if err := wal.Init(directory, options...); err != nil {
 			return err
 		}
 		fmt.Printf("WAL initialized at %q.\n", directory)
@@ -76,6 +116,34 @@ func init() {
 		"entry-checksum-type",
 		"c",
 		"crc32",
-		"The entry checksum type to use. Valid values are crc32, crc64.",
+		"The entry checksum type to use. Valid values are crc32, crc64, crc32c, xxhash64, sha256-chain, hmac-sha256-chain.",
+	)
+
+	initCmd.Flags().StringVar(
+		&initCompression,
+		"compression",
+		"none",
+		"The compression of entries to use. Valid values are none, flate, flate-fast.",
+	)
+
+	initCmd.Flags().StringVar(
+		&initWriterID,
+		"writer-id",
+		"",
+		"The identifier of the writer to store in the segment header.",
+	)
+
+	initCmd.Flags().StringVar(
+		&initApplicationID,
+		"application-id",
+		"",
+		"The identifier of the application to store in the segment header.",
+	)
+
+	initCmd.Flags().StringToStringVar(
+		&initMetadata,
+		"metadata",
+		nil,
+		"Additional key=value pairs to store in the segment header.",
 	)
 }
@@ -1,12 +1,17 @@
 package cmd
 
 import (
+	"fmt"
 	"os"
+	"strings"
 
 	"github.com/spf13/cobra"
 )
 
-var directory string
+var (
+	directory            string
+	entryChecksumKeyFile string
+)
 
 // rootCmd represents the base command when called without any subcommands.
 var rootCmd = &cobra.Command{
@@ -35,4 +40,24 @@ func init() {
 		".",
 		"The directory the write-ahead log is located in.",
 	)
+
+	rootCmd.PersistentFlags().StringVar(
+		&entryChecksumKeyFile,
+		"entry-checksum-key-file",
+		"",
+		"The file holding the key for the entry checksum type hmac-sha256-chain.",
+	)
+}
+
+// readEntryChecksumKey reads the key from the file given with the entry checksum key file flag. Trailing line breaks
+// are removed. Returns nil when the flag was not set.
+func readEntryChecksumKey() ([]byte, error) {
+	if entryChecksumKeyFile == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(entryChecksumKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading the entry checksum key: %w", err)
+	}
+	return []byte(strings.TrimRight(string(content), "\r\n")), nil
 }
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+
+	"github.com/backbone81/write-ahead-log/pkg/wal"
+)
+
+// verifyCmd represents the verify command.
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verifies the entry chain of the write-ahead log.",
+	Long: `Verifies that the entries of the write-ahead log form an unbroken entry chain. This requires the entry
+checksum type sha256-chain or hmac-sha256-chain. Compare the last digest with the one of an earlier verification to
+detect entries removed from the end.`,
+	SilenceUsage: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		entryChecksumKey, err := readEntryChecksumKey()
+		if err != nil {
+			return err
+		}
+
+		result, err := wal.Verify(directory, wal.WithReaderEntryChecksumKey(entryChecksumKey))
+		if err != nil {
+			return err
+		}
+		fmt.Printf("First Sequence Number: %d\n", result.FirstSequenceNumber)
+		fmt.Printf("Next Sequence Number:  %d\n", result.NextSequenceNumber)
+		fmt.Printf("First Digest:          %x\n", result.FirstDigest)
+		fmt.Printf("Last Digest:           %x\n", result.LastDigest)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,180 @@
+package encoding
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+)
+
+var (
+	ErrCompressionUnsupported = errors.New("unsupported WAL compression")
+	ErrCompressedDataInvalid  = errors.New("invalid WAL compressed entry data")
+)
+
+// Compression describes how the data of entries flagged with EntryFlagCompressed is compressed. The compression is
+// stored in the header metadata of the segment file, so it is only available starting with header version 3.
+type Compression uint8
+
+const (
+	// CompressionNone disables the compression of entries.
+	CompressionNone Compression = iota
+
+	// CompressionFlate compresses entries with DEFLATE at the default compression level of compress/flate. This is a
+	// good fit for text based entries like JSON.
+	CompressionFlate
+
+	// CompressionFlateFast compresses entries with DEFLATE at the fastest compression level. This trades some of the
+	// compression ratio for less CPU time when appending. Entries are decompressed the same way as with
+	// CompressionFlate.
+	CompressionFlateFast
+)
+
+// String returns a string representation of the compression.
+func (c Compression) String() string {
+	switch c {
+	case CompressionNone:
+		return "none"
+	case CompressionFlate:
+		return "flate"
+	case CompressionFlateFast:
+		return "flate-fast"
+	default:
+		return "unknown"
+	}
+}
+
+// Compressions provides a list of supported compressions. Helpful for writing tests and benchmarks which iterate over
+// all possibilities.
+var Compressions = []Compression{
+	CompressionNone,
+	CompressionFlate,
+	CompressionFlateFast,
+}
+
+// DefaultCompression is the compression which is used when nothing else is configured. Entries are not compressed by
+// default.
+const DefaultCompression = CompressionNone
+
+// maxCompressionRatio is the highest ratio of uncompressed to compressed data DEFLATE can achieve. It limits the size
+// announced by compressed data, so that malformed data cannot trigger huge memory allocations.
+const maxCompressionRatio = 1032
+
+// Compressor compresses the data of entries. It reuses its internal state between calls to avoid memory allocations.
+//
+// Instances of Compressor are NOT safe to use concurrently. You need to provide external synchronization.
+type Compressor struct {
+	level  int
+	writer *flate.Writer
+	output appendWriter
+}
+
+// NewCompressor creates a new compressor for the given compression.
+// Returns ErrCompressionUnsupported for CompressionNone and unknown compressions.
+func NewCompressor(compression Compression) (*Compressor, error) {
+	switch compression {
+	case CompressionFlate:
+		return &Compressor{level: flate.DefaultCompression}, nil
+	case CompressionFlateFast:
+		return &Compressor{level: flate.BestSpeed}, nil
+	default:
+		return nil, ErrCompressionUnsupported
+	}
+}
+
+// AppendCompressed appends the compressed concatenation of all parts to the buffer. The compressed data starts with
+// the length of the uncompressed data encoded as uvarint, followed by the DEFLATE stream.
+func (c *Compressor) AppendCompressed(buffer []byte, parts ...[]byte) ([]byte, error) {
+	var length int
+	for _, part := range parts {
+		length += len(part)
+	}
+	c.output.buffer = binary.AppendUvarint(buffer, uint64(length))
+
+	// The writer is created on first use, as it needs a lot of memory which is wasted when no entry is ever big enough
+	// to be compressed.
+	if c.writer == nil {
+		writer, err := flate.NewWriter(&c.output, c.level)
+		if err != nil {
+			return nil, err
+		}
+		c.writer = writer
+	} else {
+		c.writer.Reset(&c.output)
+	}
+	for _, part := range parts {
+		if _, err := c.writer.Write(part); err != nil {
+			return nil, compressError(err)
+		}
+	}
+	if err := c.writer.Close(); err != nil {
+		return nil, compressError(err)
+	}
+
+	buffer = c.output.buffer
+	c.output.buffer = nil
+	return buffer, nil
+}
+
+// Decompressor decompresses the data of entries. It reuses its internal state between calls to avoid memory
+// allocations. The zero value is ready to use.
+//
+// Instances of Decompressor are NOT safe to use concurrently. You need to provide external synchronization.
+type Decompressor struct {
+	reader io.ReadCloser
+	source bytes.Reader
+}
+
+// AppendDecompressed appends the decompressed data to the buffer. The data needs to be encoded like
+// Compressor.AppendCompressed does.
+// Returns ErrCompressedDataInvalid when the data is malformed or does not decompress to the length stored in front of
+// the DEFLATE stream.
+func (d *Decompressor) AppendDecompressed(buffer []byte, data []byte) ([]byte, error) {
+	length, lengthBytes := binary.Uvarint(data)
+	if lengthBytes <= 0 {
+		return nil, fmt.Errorf("reading the length: %w", ErrCompressedDataInvalid)
+	}
+	if length > uint64(len(data))*maxCompressionRatio {
+		return nil, fmt.Errorf("%d bytes exceed the maximum possible size: %w", length, ErrCompressedDataInvalid)
+	}
+
+	d.source.Reset(data[lengthBytes:])
+	if d.reader == nil {
+		d.reader = flate.NewReader(&d.source)
+	} else if err := d.reader.(flate.Resetter).Reset(&d.source, nil); err != nil {
+		return nil, decompressError(err)
+	}
+
+	start := len(buffer)
+	buffer = slices.Grow(buffer, int(length))[:start+int(length)] //nolint:gosec // The length is bound by the size of the data.
+	if _, err := io.ReadFull(d.reader, buffer[start:]); err != nil {
+		return nil, decompressError(err)
+	}
+	// The DEFLATE stream needs to end exactly after the announced length.
+	var probe [1]byte
+	if n, err := d.reader.Read(probe[:]); n != 0 || !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("the data exceeds %d bytes: %w", length, ErrCompressedDataInvalid)
+	}
+	return buffer, nil
+}
+
+// appendWriter is an io.Writer which appends everything written to it to the buffer.
+type appendWriter struct {
+	buffer []byte
+}
+
+func (w *appendWriter) Write(data []byte) (int, error) {
+	w.buffer = append(w.buffer, data...)
+	return len(data), nil
+}
+
+func compressError(err error) error {
+	return fmt.Errorf("compressing WAL entry: %w", err)
+}
+
+func decompressError(err error) error {
+	return fmt.Errorf("decompressing WAL entry: %w", errors.Join(err, ErrCompressedDataInvalid))
+}
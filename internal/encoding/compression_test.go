@@ -0,0 +1,81 @@
+package encoding_test
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+)
+
+var _ = Describe("Compression", func() {
+	for _, compression := range encoding.Compressions {
+		if compression == encoding.CompressionNone {
+			continue
+		}
+
+		It(fmt.Sprintf("should decompress the data compressed with %s", compression), func() {
+			compressor, err := encoding.NewCompressor(compression)
+			Expect(err).ToNot(HaveOccurred())
+			var decompressor encoding.Decompressor
+
+			for _, parts := range [][][]byte{
+				{},
+				{[]byte("foo")},
+				{bytes.Repeat([]byte(`{"event":"order-created","amount":42}`), 100), []byte("bar")},
+			} {
+				compressed, err := compressor.AppendCompressed([]byte("prefix"), parts...)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(compressed).To(HavePrefix("prefix"))
+
+				decompressed, err := decompressor.AppendDecompressed([]byte("prefix"), compressed[len("prefix"):])
+				Expect(err).ToNot(HaveOccurred())
+				Expect(decompressed).To(Equal(append([]byte("prefix"), bytes.Join(parts, nil)...)))
+			}
+		})
+	}
+
+	It("should compress repetitive data", func() {
+		data := bytes.Repeat([]byte(`{"event":"order-created","amount":42}`), 100)
+		for _, compression := range []encoding.Compression{encoding.CompressionFlate, encoding.CompressionFlateFast} {
+			compressor, err := encoding.NewCompressor(compression)
+			Expect(err).ToNot(HaveOccurred())
+			compressed, err := compressor.AppendCompressed(nil, data)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(len(compressed)).To(BeNumerically("<", len(data)/10))
+		}
+	})
+
+	It("should fail decompressing invalid data", func() {
+		compressor, err := encoding.NewCompressor(encoding.CompressionFlate)
+		Expect(err).ToNot(HaveOccurred())
+		data := make([]byte, 1024)
+		Expect(rand.Read(data)).Error().ToNot(HaveOccurred())
+		compressed, err := compressor.AppendCompressed(nil, data)
+		Expect(err).ToNot(HaveOccurred())
+		var decompressor encoding.Decompressor
+
+		By("failing with truncated data")
+		for _, length := range []int{0, 1, len(compressed) / 2, len(compressed) - 1} {
+			Expect(decompressor.AppendDecompressed(nil, compressed[:length])).Error().To(MatchError(encoding.ErrCompressedDataInvalid))
+		}
+
+		By("failing with a length which does not match the data")
+		stream := compressed[binary.PutUvarint(make([]byte, binary.MaxVarintLen64), uint64(len(data))):]
+		for _, length := range []uint64{uint64(len(data)) - 1, uint64(len(data)) + 1, 1 << 40} {
+			Expect(decompressor.AppendDecompressed(nil, append(binary.AppendUvarint(nil, length), stream...))).Error().To(MatchError(encoding.ErrCompressedDataInvalid))
+		}
+
+		By("still decompressing valid data afterward")
+		Expect(decompressor.AppendDecompressed(nil, compressed)).To(Equal(data))
+	})
+
+	It("should not create a compressor without compression", func() {
+		Expect(encoding.NewCompressor(encoding.CompressionNone)).Error().To(MatchError(encoding.ErrCompressionUnsupported))
+		Expect(encoding.NewCompressor(0xff)).Error().To(MatchError(encoding.ErrCompressionUnsupported))
+	})
+})
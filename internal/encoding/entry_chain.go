@@ -0,0 +1,167 @@
+package encoding
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
+	"hash"
+	"io"
+	"slices"
+)
+
+var ErrEntryChecksumKeyRequired = errors.New("the WAL entry checksum type requires a key")
+
+// EntryChainDigestSize is the size in bytes of the digest of the chained entry checksum types.
+const EntryChainDigestSize = sha256.Size
+
+// EntryChain calculates the checksums of the chained entry checksum types. The checksum of every entry is a digest
+// over the digest of the previous entry followed by the entry itself. Changing, removing or reordering entries
+// therefore changes the digests of all following entries. With EntryChecksumTypeSha256Chain, the digest is a SHA-256
+// hash. Anybody can calculate it, so the digest of the last entry needs to be stored somewhere else to detect changes
+// made by somebody with write access to the segment files. With EntryChecksumTypeHmacSha256Chain, the digest is an
+// HMAC-SHA256 with a secret key, which cannot be calculated again without knowing the key.
+//
+// The chain keeps the digest of the last entry. Calculating a checksum only prepares the next digest. Call Commit
+// after the entry was written or read successfully to move the chain forward.
+type EntryChain struct {
+	entryChecksumType EntryChecksumType
+	hash              hash.Hash
+	digest            [EntryChainDigestSize]byte
+	pending           [EntryChainDigestSize]byte
+}
+
+// NewEntryChain creates a new entry chain for the chained entry checksum type. The chain continues from the given
+// digest of the previous entry. An empty digest starts a new chain. The key is required for
+// EntryChecksumTypeHmacSha256Chain. Without the key, calculating a checksum returns ErrEntryChecksumKeyRequired. This
+// allows opening segment files without knowing the key, for example to read the header.
+func NewEntryChain(entryChecksumType EntryChecksumType, key []byte, previousDigest []byte) (*EntryChain, error) {
+	if !entryChecksumType.IsChained() {
+		return nil, ErrEntryChecksumTypeUnsupported
+	}
+	chain := &EntryChain{
+		entryChecksumType: entryChecksumType,
+	}
+	chain.SetKey(key)
+	chain.SetDigest(previousDigest)
+	return chain, nil
+}
+
+// SetKey replaces the key of the chain. The key is ignored for EntryChecksumTypeSha256Chain.
+func (c *EntryChain) SetKey(key []byte) {
+	switch {
+	case c.entryChecksumType == EntryChecksumTypeSha256Chain:
+		c.hash = sha256.New()
+	case len(key) > 0:
+		c.hash = hmac.New(sha256.New, key)
+	default:
+		c.hash = nil
+	}
+}
+
+// Ready returns ErrEntryChecksumKeyRequired when the chain cannot calculate checksums because the key is missing.
+func (c *EntryChain) Ready() error {
+	if c.hash == nil {
+		return ErrEntryChecksumKeyRequired
+	}
+	return nil
+}
+
+// Digest returns a copy of the digest of the last entry.
+func (c *EntryChain) Digest() []byte {
+	return slices.Clone(c.digest[:])
+}
+
+// SetDigest moves the chain to the given digest of the previous entry. An empty digest starts a new chain.
+func (c *EntryChain) SetDigest(digest []byte) {
+	clear(c.digest[:])
+	copy(c.digest[:], digest)
+	c.pending = c.digest
+}
+
+// Commit moves the chain forward to the digest which was calculated last.
+func (c *EntryChain) Commit() {
+	c.digest = c.pending
+}
+
+// WriteEntryChecksum writes the digest over the previous digest and the data to the writer. It implements the
+// EntryChecksumWriter function signature. The buffer is not needed.
+func (c *EntryChain) WriteEntryChecksum(writer io.Writer, _ []byte, data []byte) error {
+	if err := c.sum(data); err != nil {
+		return err
+	}
+	if _, err := writer.Write(c.pending[:]); err != nil {
+		return checksumWriteError(err)
+	}
+	return nil
+}
+
+// ReadEntryChecksum reads the digest from the reader and compares it with the digest over the previous digest and the
+// data. It implements the EntryChecksumReader function signature.
+// The buffer is required to avoid allocations and should be big enough to hold the digest temporarily.
+func (c *EntryChain) ReadEntryChecksum(reader io.Reader, buffer []byte, data []byte) (int, error) {
+	if n, err := io.ReadFull(reader, buffer[:EntryChainDigestSize]); err != nil {
+		return n, checksumReadError(err)
+	}
+	if err := c.sum(data); err != nil {
+		return EntryChainDigestSize, err
+	}
+	if !hmac.Equal(buffer[:EntryChainDigestSize], c.pending[:]) {
+		return EntryChainDigestSize, ErrEntryChecksumMismatch
+	}
+	return EntryChainDigestSize, nil
+}
+
+// Hash returns a hash for calculating the digest incrementally. Resetting the hash starts with the digest of the
+// previous entry. Write the digest calculated by the hash with WriteEntryChecksumHash. Call Reset before writing the
+// first data.
+func (c *EntryChain) Hash() hash.Hash {
+	return entryChainHash{chain: c}
+}
+
+func (c *EntryChain) sum(data []byte) error {
+	if err := c.Ready(); err != nil {
+		return err
+	}
+	c.hash.Reset()
+	c.hash.Write(c.digest[:])
+	c.hash.Write(data)
+	c.hash.Sum(c.pending[:0])
+	return nil
+}
+
+// entryChainHash implements hash.Hash on top of an entry chain. The digest calculated by Sum is prepared for the next
+// Commit of the chain.
+type entryChainHash struct {
+	chain *EntryChain
+}
+
+func (h entryChainHash) Write(data []byte) (int, error) {
+	if err := h.chain.Ready(); err != nil {
+		return 0, err
+	}
+	return h.chain.hash.Write(data)
+}
+
+func (h entryChainHash) Sum(buffer []byte) []byte {
+	if h.chain.hash == nil {
+		return append(buffer, h.chain.pending[:]...)
+	}
+	h.chain.hash.Sum(h.chain.pending[:0])
+	return append(buffer, h.chain.pending[:]...)
+}
+
+func (h entryChainHash) Reset() {
+	if h.chain.hash == nil {
+		return
+	}
+	h.chain.hash.Reset()
+	h.chain.hash.Write(h.chain.digest[:])
+}
+
+func (h entryChainHash) Size() int {
+	return EntryChainDigestSize
+}
+
+func (h entryChainHash) BlockSize() int {
+	return sha256.BlockSize
+}
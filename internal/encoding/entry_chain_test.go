@@ -0,0 +1,109 @@
+package encoding_test
+
+import (
+	"bytes"
+	"fmt"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+)
+
+var _ = Describe("EntryChain", func() {
+	key := []byte("secret")
+
+	for _, entryChecksumType := range encoding.ChainedEntryChecksumTypes {
+		Context(fmt.Sprintf("With entry checksum %s", entryChecksumType), func() {
+			It("should read the chained entry checksums", func() {
+				writer, err := encoding.NewEntryChain(entryChecksumType, key, nil)
+				Expect(err).ToNot(HaveOccurred())
+				var output bytes.Buffer
+				for _, data := range []string{"foo", "bar"} {
+					Expect(writer.WriteEntryChecksum(&output, nil, []byte(data))).To(Succeed())
+					writer.Commit()
+				}
+				Expect(output.Len()).To(Equal(2 * encoding.EntryChainDigestSize))
+				Expect(writer.Digest()).To(Equal(output.Bytes()[encoding.EntryChainDigestSize:]))
+
+				reader, err := encoding.NewEntryChain(entryChecksumType, key, nil)
+				Expect(err).ToNot(HaveOccurred())
+				var buffer [encoding.MaxChecksumBufferLen]byte
+				for _, data := range []string{"foo", "bar"} {
+					Expect(reader.ReadEntryChecksum(&output, buffer[:], []byte(data))).To(Equal(encoding.EntryChainDigestSize))
+					reader.Commit()
+				}
+				Expect(reader.Digest()).To(Equal(writer.Digest()))
+			})
+
+			It("should detect reordered entries", func() {
+				writer, err := encoding.NewEntryChain(entryChecksumType, key, nil)
+				Expect(err).ToNot(HaveOccurred())
+				var first, second bytes.Buffer
+				Expect(writer.WriteEntryChecksum(&first, nil, []byte("foo"))).To(Succeed())
+				writer.Commit()
+				Expect(writer.WriteEntryChecksum(&second, nil, []byte("foo"))).To(Succeed())
+				writer.Commit()
+
+				reader, err := encoding.NewEntryChain(entryChecksumType, key, nil)
+				Expect(err).ToNot(HaveOccurred())
+				var buffer [encoding.MaxChecksumBufferLen]byte
+				_, err = reader.ReadEntryChecksum(&second, buffer[:], []byte("foo"))
+				Expect(err).To(MatchError(encoding.ErrEntryChecksumMismatch))
+			})
+
+			It("should continue the chain from the previous digest", func() {
+				writer, err := encoding.NewEntryChain(entryChecksumType, key, nil)
+				Expect(err).ToNot(HaveOccurred())
+				var output bytes.Buffer
+				Expect(writer.WriteEntryChecksum(&output, nil, []byte("foo"))).To(Succeed())
+				writer.Commit()
+				Expect(writer.WriteEntryChecksum(&output, nil, []byte("bar"))).To(Succeed())
+
+				reader, err := encoding.NewEntryChain(entryChecksumType, key, output.Next(encoding.EntryChainDigestSize))
+				Expect(err).ToNot(HaveOccurred())
+				var buffer [encoding.MaxChecksumBufferLen]byte
+				Expect(reader.ReadEntryChecksum(&output, buffer[:], []byte("bar"))).To(Equal(encoding.EntryChainDigestSize))
+			})
+
+			It("should calculate the same checksum incrementally", func() {
+				writer, err := encoding.NewEntryChain(entryChecksumType, key, []byte("previous"))
+				Expect(err).ToNot(HaveOccurred())
+				data := []byte("foo bar baz")
+
+				var wantOutput bytes.Buffer
+				Expect(writer.WriteEntryChecksum(&wantOutput, nil, data)).To(Succeed())
+
+				var gotOutput bytes.Buffer
+				var buffer [encoding.MaxChecksumBufferLen]byte
+				checksumHash := writer.Hash()
+				checksumHash.Reset()
+				Expect(checksumHash.Write(data[:4])).Error().ToNot(HaveOccurred())
+				Expect(checksumHash.Write(data[4:])).Error().ToNot(HaveOccurred())
+				Expect(encoding.WriteEntryChecksumHash(&gotOutput, buffer[:], checksumHash)).To(Succeed())
+				Expect(gotOutput.Bytes()).To(Equal(wantOutput.Bytes()))
+			})
+		})
+	}
+
+	It("should require the key for HMAC-SHA256", func() {
+		chain, err := encoding.NewEntryChain(encoding.EntryChecksumTypeHmacSha256Chain, nil, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(chain.Ready()).To(MatchError(encoding.ErrEntryChecksumKeyRequired))
+		Expect(chain.WriteEntryChecksum(&bytes.Buffer{}, nil, []byte("foo"))).To(MatchError(encoding.ErrEntryChecksumKeyRequired))
+
+		By("reading with a different key")
+		chain.SetKey([]byte("secret"))
+		var output bytes.Buffer
+		Expect(chain.WriteEntryChecksum(&output, nil, []byte("foo"))).To(Succeed())
+		chain.SetKey([]byte("other"))
+		var buffer [encoding.MaxChecksumBufferLen]byte
+		_, err = chain.ReadEntryChecksum(&output, buffer[:], []byte("foo"))
+		Expect(err).To(MatchError(encoding.ErrEntryChecksumMismatch))
+	})
+
+	It("should not create chains for other entry checksum types", func() {
+		Expect(encoding.NewEntryChain(encoding.EntryChecksumTypeCrc32, nil, nil)).Error().To(MatchError(encoding.ErrEntryChecksumTypeUnsupported))
+		Expect(encoding.GetEntryChecksumWriter(encoding.EntryChecksumTypeSha256Chain)).Error().To(MatchError(encoding.ErrEntryChecksumTypeChained))
+	})
+})
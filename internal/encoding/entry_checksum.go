@@ -1,20 +1,26 @@
 package encoding
 
 import (
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"hash"
 	"hash/crc32"
 	"hash/crc64"
 	"io"
+	"slices"
+
+	"github.com/cespare/xxhash/v2"
 )
 
 var (
 	ErrEntryChecksumTypeUnsupported = errors.New("unsupported WAL entry checksum type")
 	ErrEntryChecksumMismatch        = errors.New("WAL entry checksum mismatch")
+	ErrEntryChecksumTypeChained     = errors.New("the WAL entry checksum type requires an entry chain")
 )
 
 // MaxChecksumBufferLen is the size of the buffer which is big enough for all supported checksum types.
-const MaxChecksumBufferLen = crc64.Size
+const MaxChecksumBufferLen = sha256.Size
 
 // EntryChecksumType describes the type of checksum applied to an entry.
 type EntryChecksumType int
@@ -22,6 +28,10 @@ type EntryChecksumType int
 const (
 	EntryChecksumTypeCrc32 EntryChecksumType = iota + 1 // We do not start at 0 to detect missing values.
 	EntryChecksumTypeCrc64
+	EntryChecksumTypeCrc32c
+	EntryChecksumTypeXXHash64
+	EntryChecksumTypeSha256Chain
+	EntryChecksumTypeHmacSha256Chain
 )
 
 // String returns a string representation of the checksum.
@@ -31,16 +41,39 @@ func (e EntryChecksumType) String() string {
 		return "crc32"
 	case EntryChecksumTypeCrc64:
 		return "crc64"
+	case EntryChecksumTypeCrc32c:
+		return "crc32c"
+	case EntryChecksumTypeXXHash64:
+		return "xxhash64"
+	case EntryChecksumTypeSha256Chain:
+		return "sha256-chain"
+	case EntryChecksumTypeHmacSha256Chain:
+		return "hmac-sha256-chain"
 	default:
 		return "unknown"
 	}
 }
 
+// IsChained reports if the checksum of an entry covers the checksum of the previous entry as well. Checksums of these
+// types are calculated with an EntryChain instead of the entry checksum writer and reader functions.
+func (e EntryChecksumType) IsChained() bool {
+	return slices.Contains(ChainedEntryChecksumTypes, e)
+}
+
 // EntryChecksumTypes provides a list of supported checksum types. Helpful for writing tests and benchmarks which
 // iterate over all possibilities.
 var EntryChecksumTypes = []EntryChecksumType{
 	EntryChecksumTypeCrc32,
 	EntryChecksumTypeCrc64,
+	EntryChecksumTypeCrc32c,
+	EntryChecksumTypeXXHash64,
+}
+
+// ChainedEntryChecksumTypes provides a list of supported checksum types which chain the entries together. They are not
+// part of EntryChecksumTypes, because they need an EntryChain for calculating the checksums.
+var ChainedEntryChecksumTypes = []EntryChecksumType{
+	EntryChecksumTypeSha256Chain,
+	EntryChecksumTypeHmacSha256Chain,
 }
 
 // DefaultEntryChecksumType is the checksum type which should work fine for most use cases.
@@ -59,6 +92,12 @@ func GetEntryChecksumWriter(entryChecksumType EntryChecksumType) (EntryChecksumW
 		return WriteEntryChecksumCrc32, nil
 	case EntryChecksumTypeCrc64:
 		return WriteEntryChecksumCrc64, nil
+	case EntryChecksumTypeCrc32c:
+		return WriteEntryChecksumCrc32c, nil
+	case EntryChecksumTypeXXHash64:
+		return WriteEntryChecksumXXHash64, nil
+	case EntryChecksumTypeSha256Chain, EntryChecksumTypeHmacSha256Chain:
+		return nil, ErrEntryChecksumTypeChained
 	default:
 		return nil, ErrEntryChecksumTypeUnsupported
 	}
@@ -78,11 +117,58 @@ func GetEntryChecksumReader(entryChecksumType EntryChecksumType) (EntryChecksumR
 		return ReadEntryChecksumCrc32, nil
 	case EntryChecksumTypeCrc64:
 		return ReadEntryChecksumCrc64, nil
+	case EntryChecksumTypeCrc32c:
+		return ReadEntryChecksumCrc32c, nil
+	case EntryChecksumTypeXXHash64:
+		return ReadEntryChecksumXXHash64, nil
+	case EntryChecksumTypeSha256Chain, EntryChecksumTypeHmacSha256Chain:
+		return nil, ErrEntryChecksumTypeChained
+	default:
+		return nil, ErrEntryChecksumTypeUnsupported
+	}
+}
+
+// NewEntryChecksumHash returns a hash for calculating the checksum of the entry checksum type incrementally. This is
+// helpful when the data of an entry is not available as a single slice of bytes. Write the checksum calculated by the
+// hash with WriteEntryChecksumHash.
+func NewEntryChecksumHash(entryChecksumType EntryChecksumType) (hash.Hash, error) {
+	switch entryChecksumType {
+	case EntryChecksumTypeCrc32:
+		return crc32.New(crc32ChecksumTable), nil
+	case EntryChecksumTypeCrc64:
+		return crc64.New(crc64ChecksumTable), nil
+	case EntryChecksumTypeCrc32c:
+		return crc32.New(crc32cChecksumTable), nil
+	case EntryChecksumTypeXXHash64:
+		return xxhash.New(), nil
+	case EntryChecksumTypeSha256Chain, EntryChecksumTypeHmacSha256Chain:
+		return nil, ErrEntryChecksumTypeChained
 	default:
 		return nil, ErrEntryChecksumTypeUnsupported
 	}
 }
 
+// WriteEntryChecksumHash writes the checksum calculated by the hash to the writer. The hash needs to be created by
+// NewEntryChecksumHash or EntryChain.Hash. The result is the same as the one of the entry checksum writer for the same data.
+// The buffer is required to avoid allocations and should be big enough to hold the checksum temporarily.
+func WriteEntryChecksumHash(writer io.Writer, buffer []byte, checksumHash hash.Hash) error {
+	var checksum []byte
+	switch typedHash := checksumHash.(type) {
+	case hash.Hash32:
+		Endian.PutUint32(buffer[:4], typedHash.Sum32())
+		checksum = buffer[:4]
+	case hash.Hash64:
+		Endian.PutUint64(buffer[:8], typedHash.Sum64())
+		checksum = buffer[:8]
+	default:
+		checksum = checksumHash.Sum(buffer[:0])
+	}
+	if _, err := writer.Write(checksum); err != nil {
+		return checksumWriteError(err)
+	}
+	return nil
+}
+
 var crc32ChecksumTable = crc32.MakeTable(crc32.IEEE)
 
 // WriteEntryChecksumCrc32 writes the checksum to the writer as uint32.
@@ -139,6 +225,61 @@ func ReadEntryChecksumCrc64(reader io.Reader, buffer []byte, data []byte) (int,
 	return 8, nil
 }
 
+// The Castagnoli table is hardware accelerated on most modern CPUs and detects more errors than the IEEE table.
+var crc32cChecksumTable = crc32.MakeTable(crc32.Castagnoli)
+
+// WriteEntryChecksumCrc32c writes the checksum with the Castagnoli polynomial to the writer as uint32.
+// The buffer is required to avoid allocations and should be big enough to hold the checksum temporarily.
+// The data is the data to calculate the checksum over.
+func WriteEntryChecksumCrc32c(writer io.Writer, buffer []byte, data []byte) error {
+	Endian.PutUint32(buffer[:4], crc32.Checksum(data, crc32cChecksumTable))
+	if _, err := writer.Write(buffer[:4]); err != nil {
+		return checksumWriteError(err)
+	}
+	return nil
+}
+
+// ReadEntryChecksumCrc32c reads the checksum with the Castagnoli polynomial from the reader as uint32.
+// The buffer is required to avoid allocations and should be big enough to hold the checksum temporarily.
+// The data is the data to calculate the checksum over and compare to the checksum which was read.
+// The return value is the number of bytes read from reader.
+func ReadEntryChecksumCrc32c(reader io.Reader, buffer []byte, data []byte) (int, error) {
+	if n, err := io.ReadFull(reader, buffer[:4]); err != nil {
+		return n, checksumReadError(err)
+	}
+	checksum := Endian.Uint32(buffer[:4])
+	if checksum != crc32.Checksum(data, crc32cChecksumTable) {
+		return 4, ErrEntryChecksumMismatch
+	}
+	return 4, nil
+}
+
+// WriteEntryChecksumXXHash64 writes the xxHash64 checksum to the writer as uint64.
+// The buffer is required to avoid allocations and should be big enough to hold the checksum temporarily.
+// The data is the data to calculate the checksum over.
+func WriteEntryChecksumXXHash64(writer io.Writer, buffer []byte, data []byte) error {
+	Endian.PutUint64(buffer[:8], xxhash.Sum64(data))
+	if _, err := writer.Write(buffer[:8]); err != nil {
+		return checksumWriteError(err)
+	}
+	return nil
+}
+
+// ReadEntryChecksumXXHash64 reads the xxHash64 checksum from the reader as uint64.
+// The buffer is required to avoid allocations and should be big enough to hold the checksum temporarily.
+// The data is the data to calculate the checksum over and compare to the checksum which was read.
+// The return value is the number of bytes read from reader.
+func ReadEntryChecksumXXHash64(reader io.Reader, buffer []byte, data []byte) (int, error) {
+	if n, err := io.ReadFull(reader, buffer[:8]); err != nil {
+		return n, checksumReadError(err)
+	}
+	checksum := Endian.Uint64(buffer[:8])
+	if checksum != xxhash.Sum64(data) {
+		return 8, ErrEntryChecksumMismatch
+	}
+	return 8, nil
+}
+
 func checksumWriteError(err error) error {
 	return fmt.Errorf("writing WAL entry checksum: %w", err)
 }
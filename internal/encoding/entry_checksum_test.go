@@ -26,6 +26,8 @@ var _ = Describe("EntryChecksum", func() {
 		},
 		Entry("When using CRC32", encoding.EntryChecksumTypeCrc32, 4),
 		Entry("When using CRC64", encoding.EntryChecksumTypeCrc64, 8),
+		Entry("When using CRC32C", encoding.EntryChecksumTypeCrc32c, 4),
+		Entry("When using xxHash64", encoding.EntryChecksumTypeXXHash64, 8),
 	)
 
 	DescribeTable("Reading entry checksums",
@@ -44,6 +46,34 @@ var _ = Describe("EntryChecksum", func() {
 		},
 		Entry("When using CRC32", encoding.EntryChecksumTypeCrc32),
 		Entry("When using CRC64", encoding.EntryChecksumTypeCrc64),
+		Entry("When using CRC32C", encoding.EntryChecksumTypeCrc32c),
+		Entry("When using xxHash64", encoding.EntryChecksumTypeXXHash64),
+	)
+
+	DescribeTable("Writing entry checksums calculated incrementally",
+		func(entryChecksumType encoding.EntryChecksumType) {
+			writer, err := encoding.GetEntryChecksumWriter(entryChecksumType)
+			Expect(err).ToNot(HaveOccurred())
+
+			checksumHash, err := encoding.NewEntryChecksumHash(entryChecksumType)
+			Expect(err).ToNot(HaveOccurred())
+
+			var buffer [encoding.MaxChecksumBufferLen]byte
+			data := []byte("foo bar baz")
+
+			var wantOutput bytes.Buffer
+			Expect(writer(&wantOutput, buffer[:], data)).To(Succeed())
+
+			var gotOutput bytes.Buffer
+			Expect(checksumHash.Write(data[:4])).Error().ToNot(HaveOccurred())
+			Expect(checksumHash.Write(data[4:])).Error().ToNot(HaveOccurred())
+			Expect(encoding.WriteEntryChecksumHash(&gotOutput, buffer[:], checksumHash)).To(Succeed())
+			Expect(gotOutput.Bytes()).To(Equal(wantOutput.Bytes()))
+		},
+		Entry("When using CRC32", encoding.EntryChecksumTypeCrc32),
+		Entry("When using CRC64", encoding.EntryChecksumTypeCrc64),
+		Entry("When using CRC32C", encoding.EntryChecksumTypeCrc32c),
+		Entry("When using xxHash64", encoding.EntryChecksumTypeXXHash64),
 	)
 })
 
@@ -0,0 +1,48 @@
+package encoding
+
+import "errors"
+
+var ErrEntryFlagsUnsupported = errors.New("unsupported WAL entry flags")
+
+// EntryFlags describes additional properties of a single entry. Entry flags are available starting with header version
+// 2. They are encoded as a single byte between the entry length and the entry data and are covered by the checksum.
+type EntryFlags uint8
+
+const (
+	// EntryFlagBatch marks an entry which holds multiple entries appended atomically. The data of such an entry is a
+	// sequence of entry length followed by entry data for every entry in the batch. The entry length is encoded with
+	// the entry length encoding of the segment file. Every entry in the batch receives its own sequence number.
+	EntryFlagBatch EntryFlags = 1 << iota
+
+	// EntryFlagTyped marks an entry which starts with an envelope holding the entry type, application defined flags
+	// and headers. The envelope is followed by the data of the entry. See AppendEntryEnvelope for the encoding. A batch
+	// can not be typed.
+	EntryFlagTyped
+
+	// EntryFlagCompressed marks an entry which data is compressed with the compression stored in the header metadata of
+	// the segment file. The entry length and the checksum cover the compressed data. Batches and typed entries are
+	// compressed as a whole, including the lengths of the batch entries or the envelope.
+	EntryFlagCompressed
+)
+
+// EntryFlagsSupported is the combination of all entry flags which are supported.
+const EntryFlagsSupported = EntryFlagBatch | EntryFlagTyped | EntryFlagCompressed
+
+// EntryFlagsSize returns the number of bytes the entry flags occupy in segment files of the given header version.
+func EntryFlagsSize(version uint16) int {
+	if version < HeaderVersion2 {
+		return 0
+	}
+	return 1
+}
+
+// Validate returns an error if the entry flags contain flags which are not supported.
+func (f EntryFlags) Validate() error {
+	if f&^EntryFlagsSupported != 0 {
+		return ErrEntryFlagsUnsupported
+	}
+	if f&EntryFlagBatch != 0 && f&EntryFlagTyped != 0 {
+		return ErrEntryFlagsUnsupported
+	}
+	return nil
+}
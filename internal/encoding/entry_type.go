@@ -0,0 +1,110 @@
+package encoding
+
+import (
+	"errors"
+	"fmt"
+)
+
+var ErrEntryEnvelopeInvalid = errors.New("invalid WAL entry envelope")
+
+// EntryType describes what kind of data an entry holds. Applications use the entry types below EntryTypeControl to
+// distinguish their own kinds of entries without inventing an envelope inside the entry data. The entry types starting
+// with EntryTypeControl are reserved for control records written by the library itself. Readers should skip control
+// records they do not know.
+type EntryType uint8
+
+const (
+	// EntryTypeData is the entry type of all entries which were appended without an entry type.
+	EntryTypeData EntryType = 0
+
+	// EntryTypeControl is the first entry type reserved for control records.
+	EntryTypeControl EntryType = 0x80
+
+	// EntryTypeCheckpoint is the control record marking a checkpoint taken by the application. The data of the record
+	// is defined by the application, like the location of a snapshot.
+	EntryTypeCheckpoint EntryType = EntryTypeControl
+)
+
+// IsControl reports if the entry type is reserved for control records.
+func (t EntryType) IsControl() bool {
+	return t >= EntryTypeControl
+}
+
+// EntryHeader is a single key value attached to an entry.
+type EntryHeader struct {
+	// Key identifies the header. Encoded with a length of two bytes.
+	Key string
+
+	// Value is the value of the header. Encoded with a length of two bytes.
+	Value []byte
+}
+
+// MaxEntryHeaders is the maximum number of headers of a single entry.
+const MaxEntryHeaders = 0xff
+
+// MaxEntryHeaderLen is the maximum length in bytes of the key and of the value of a single header.
+const MaxEntryHeaderLen = 0xffff
+
+// AppendEntryEnvelope appends the envelope of a typed entry to the buffer. The envelope is located in front of the
+// entry data and consists of the entry type encoded as a single byte, the application defined flags encoded as a
+// single byte and the number of headers encoded as a single byte. Every header follows with the key length encoded as
+// two bytes, the key, the value length encoded as two bytes and the value.
+// Returns ErrEntryEnvelopeInvalid when there are too many headers or a header is too long.
+func AppendEntryEnvelope(buffer []byte, entryType EntryType, flags uint8, headers []EntryHeader) ([]byte, error) {
+	if len(headers) > MaxEntryHeaders {
+		return nil, fmt.Errorf("%d headers exceed the maximum of %d: %w", len(headers), MaxEntryHeaders, ErrEntryEnvelopeInvalid)
+	}
+	buffer = append(buffer, byte(entryType), flags, byte(len(headers)))
+	for _, header := range headers {
+		if len(header.Key) > MaxEntryHeaderLen || len(header.Value) > MaxEntryHeaderLen {
+			return nil, fmt.Errorf("the header %q exceeds the maximum length of %d bytes: %w", header.Key, MaxEntryHeaderLen, ErrEntryEnvelopeInvalid)
+		}
+		buffer = Endian.AppendUint16(buffer, uint16(len(header.Key))) //nolint:gosec // The length was checked above.
+		buffer = append(buffer, header.Key...)
+		buffer = Endian.AppendUint16(buffer, uint16(len(header.Value))) //nolint:gosec // The length was checked above.
+		buffer = append(buffer, header.Value...)
+	}
+	return buffer, nil
+}
+
+// ReadEntryEnvelope decodes the envelope at the start of the data of a typed entry. The headers are appended to the
+// given slice to avoid allocations. The values of the headers and the returned entry data point into data.
+// Returns ErrEntryEnvelopeInvalid when the envelope is truncated.
+func ReadEntryEnvelope(data []byte, headers []EntryHeader) (EntryType, uint8, []EntryHeader, []byte, error) {
+	if len(data) < 3 {
+		return 0, 0, nil, nil, fmt.Errorf("truncated envelope: %w", ErrEntryEnvelopeInvalid)
+	}
+	entryType := EntryType(data[0])
+	flags := data[1]
+	headerCount := int(data[2])
+	data = data[3:]
+	for range headerCount {
+		key, rest, ok := cutEnvelopeField(data)
+		if !ok {
+			return 0, 0, nil, nil, fmt.Errorf("truncated header key: %w", ErrEntryEnvelopeInvalid)
+		}
+		value, rest, ok := cutEnvelopeField(rest)
+		if !ok {
+			return 0, 0, nil, nil, fmt.Errorf("truncated header value: %w", ErrEntryEnvelopeInvalid)
+		}
+		headers = append(headers, EntryHeader{
+			Key:   string(key),
+			Value: value,
+		})
+		data = rest
+	}
+	return entryType, flags, headers, data, nil
+}
+
+// cutEnvelopeField splits a field with a length of two bytes from the start of the data. It reports false when the
+// data is too short.
+func cutEnvelopeField(data []byte) ([]byte, []byte, bool) {
+	if len(data) < 2 {
+		return nil, nil, false
+	}
+	length := int(Endian.Uint16(data[:2]))
+	if len(data)-2 < length {
+		return nil, nil, false
+	}
+	return data[2 : 2+length], data[2+length:], true
+}
@@ -0,0 +1,53 @@
+package encoding_test
+
+import (
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+)
+
+var _ = Describe("EntryType", func() {
+	It("should read the entry envelope", func() {
+		headers := []encoding.EntryHeader{
+			{Key: "trace-id", Value: []byte("4bf92f3577b34da6")},
+			{Key: "", Value: []byte("empty key")},
+		}
+		envelope, err := encoding.AppendEntryEnvelope(nil, 42, 0x81, headers)
+		Expect(err).ToNot(HaveOccurred())
+
+		entryType, flags, gotHeaders, data, err := encoding.ReadEntryEnvelope(append(envelope, "foo"...), nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(entryType).To(Equal(encoding.EntryType(42)))
+		Expect(flags).To(Equal(uint8(0x81)))
+		Expect(gotHeaders).To(Equal(headers))
+		Expect(data).To(Equal([]byte("foo")))
+	})
+
+	It("should fail reading a truncated entry envelope", func() {
+		envelope, err := encoding.AppendEntryEnvelope(nil, 1, 0, []encoding.EntryHeader{
+			{Key: "key", Value: []byte("value")},
+		})
+		Expect(err).ToNot(HaveOccurred())
+
+		for length := range len(envelope) {
+			Expect(encoding.ReadEntryEnvelope(envelope[:length], nil)).Error().To(MatchError(encoding.ErrEntryEnvelopeInvalid))
+		}
+	})
+
+	It("should fail writing headers exceeding the limits", func() {
+		Expect(encoding.AppendEntryEnvelope(nil, 1, 0, make([]encoding.EntryHeader, encoding.MaxEntryHeaders+1))).Error().To(MatchError(encoding.ErrEntryEnvelopeInvalid))
+		Expect(encoding.AppendEntryEnvelope(nil, 1, 0, []encoding.EntryHeader{
+			{Key: strings.Repeat("k", encoding.MaxEntryHeaderLen+1)},
+		})).Error().To(MatchError(encoding.ErrEntryEnvelopeInvalid))
+	})
+
+	It("should report control records", func() {
+		Expect(encoding.EntryTypeData.IsControl()).To(BeFalse())
+		Expect(encoding.EntryType(encoding.EntryTypeControl - 1).IsControl()).To(BeFalse())
+		Expect(encoding.EntryTypeControl.IsControl()).To(BeTrue())
+		Expect(encoding.EntryType(0xff).IsControl()).To(BeTrue())
+	})
+})
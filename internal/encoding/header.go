@@ -10,6 +10,7 @@ import (
 var (
 	ErrHeaderInvalidMagicBytes  = errors.New("invalid WAL header magic bytes")
 	ErrHeaderUnsupportedVersion = errors.New("unsupported WAL header version")
+	ErrHeaderInvalidMetadata    = errors.New("invalid WAL header metadata")
 )
 
 // Header describes the segment file header which is located at the start of every segment file.
@@ -33,17 +34,43 @@ type Header struct {
 	// accidental file renames.
 	// Encoded as eight bytes.
 	FirstSequenceNumber uint64
+
+	// Additional information about the segment file. The metadata is only stored starting with header version 3. It
+	// follows the fixed size part of the header and has a variable size.
+	Metadata HeaderMetadata
 }
 
-// HeaderSize provides the size in bytes of the header. Helpful for reading the full header before decoding individual
-// elements.
+// HeaderSize provides the size in bytes of the fixed size part of the header. Helpful for reading the fixed size part
+// before decoding individual elements. Starting with header version 3, the metadata follows. Use Header.Size for the
+// size of the full header.
 const HeaderSize = 4 + 2 + 1 + 1 + 8
 
 // Magic holds the magic bytes expected at the start of the file.
 var Magic = [4]byte{'W', 'A', 'L', 0}
 
-// HeaderVersion provides the currently supported header version.
-const HeaderVersion = 1
+const (
+	// HeaderVersion1 is the initial segment file format. Every entry consists of the entry length, the entry data and
+	// the entry checksum.
+	HeaderVersion1 = 1
+
+	// HeaderVersion2 extends every entry with entry flags which are located between the entry length and the entry
+	// data.
+	HeaderVersion2 = 2
+
+	// HeaderVersion3 extends the header with metadata of variable size which follows the fixed size part of the
+	// header. The entries are encoded like with HeaderVersion2.
+	HeaderVersion3 = 3
+)
+
+// HeaderVersion provides the header version which is used for new segment files.
+const HeaderVersion = HeaderVersion3
+
+// HeaderVersions provides a list of supported header versions. Segment files with any of those versions can be read.
+var HeaderVersions = []uint16{
+	HeaderVersion1,
+	HeaderVersion2,
+	HeaderVersion3,
+}
 
 // DefaultHeader provides a header configuration which is a sane default in most situations.
 var DefaultHeader = Header{
@@ -54,23 +81,50 @@ var DefaultHeader = Header{
 	FirstSequenceNumber: 0,
 }
 
+// Size returns the size in bytes of the full header including the metadata.
+func (h Header) Size() int64 {
+	if h.Version < HeaderVersion3 {
+		return HeaderSize
+	}
+	return HeaderSize + metadataFrameSize + int64(h.Metadata.size())
+}
+
 // WriteHeader writes the segment header to the writer.
-// The buffer is required to avoid allocations and should be big enough to hold the full header temporarily.
+// The buffer is required to avoid allocations and should be big enough to hold the full header temporarily. A buffer
+// which is too small for the metadata is only used for the fixed size part of the header.
+// Returns ErrHeaderInvalidMetadata when the header version does not support metadata or the metadata is too big.
+// Returns ErrEntryChecksumTypeUnsupported for chained entry checksum types before header version 3, because the chain
+// cannot be continued across segment files without the metadata.
 func WriteHeader(writer io.Writer, buffer []byte, header Header) error {
+	if header.EntryChecksumType.IsChained() && header.Version < HeaderVersion3 {
+		return headerWriteError(fmt.Errorf("%s with header version %d: %w", header.EntryChecksumType, header.Version, ErrEntryChecksumTypeUnsupported))
+	}
 	copy(buffer[:4], header.Magic[:])
 	Endian.PutUint16(buffer[4:6], header.Version)
 	buffer[6] = byte(header.EntryLengthEncoding)
 	buffer[7] = byte(header.EntryChecksumType)
 	Endian.PutUint64(buffer[8:16], header.FirstSequenceNumber)
-	if _, err := writer.Write(buffer); err != nil {
+	output := buffer[:HeaderSize]
+	if header.Version >= HeaderVersion3 {
+		var err error
+		output, err = appendMetadata(output, header.Metadata)
+		if err != nil {
+			return headerWriteError(err)
+		}
+	} else if !header.Metadata.IsZero() {
+		return headerWriteError(fmt.Errorf("header version %d: %w", header.Version, ErrHeaderInvalidMetadata))
+	}
+	if _, err := writer.Write(output); err != nil {
 		return headerWriteError(err)
 	}
 	return nil
 }
 
 // ReadHeader reads the segment header from the reader.
-// The buffer is required to avoid allocations and should be big enough to hold the full header temporarily.
-// An error is returned when the header does not match expectations (like magic bytes, version, etc.).
+// The buffer is required to avoid allocations and should be big enough to hold the full header temporarily. When the
+// metadata does not fit into the buffer, a bigger buffer is allocated for it.
+// An error is returned when the header does not match expectations (like magic bytes, version, metadata checksum,
+// etc.).
 func ReadHeader(reader io.Reader, buffer []byte) (Header, error) {
 	var result Header
 	if _, err := io.ReadFull(reader, buffer[:HeaderSize]); err != nil {
@@ -86,15 +140,22 @@ func ReadHeader(reader io.Reader, buffer []byte) (Header, error) {
 	if result.Magic != Magic {
 		return Header{}, ErrHeaderInvalidMagicBytes
 	}
-	if result.Version != HeaderVersion {
+	if !slices.Contains(HeaderVersions, result.Version) {
 		return Header{}, ErrHeaderUnsupportedVersion
 	}
 	if !slices.Contains(EntryLengthEncodings, result.EntryLengthEncoding) {
 		return Header{}, ErrEntryLengthEncodingUnsupported
 	}
-	if !slices.Contains(EntryChecksumTypes, result.EntryChecksumType) {
+	if !slices.Contains(EntryChecksumTypes, result.EntryChecksumType) && !result.EntryChecksumType.IsChained() {
 		return Header{}, ErrEntryChecksumTypeUnsupported
 	}
+	if result.Version >= HeaderVersion3 {
+		metadata, err := readMetadata(reader, buffer)
+		if err != nil {
+			return Header{}, err
+		}
+		result.Metadata = metadata
+	}
 	return result, nil
 }
 
@@ -0,0 +1,232 @@
+package encoding
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io"
+	"maps"
+	"slices"
+	"time"
+)
+
+// HeaderMetadata holds additional information about a segment file. It is stored in the header starting with header
+// version 3.
+//
+// The metadata is encoded as the length of the metadata records encoded as four bytes, followed by the metadata
+// records and a CRC32 checksum over the length and the records encoded as four bytes. Every record consists of the
+// record type encoded as a single byte, the length of the record value encoded as four bytes and the record value.
+// Readers skip records of unknown types, which allows adding new records without a new header version.
+type HeaderMetadata struct {
+	// The time the segment file was created. Encoded as nanoseconds since the Unix epoch in eight bytes. The zero time
+	// is not stored.
+	CreatedAt time.Time
+
+	// Identifies the writer which created the segment file, like the host name or the version of the service. Empty
+	// values are not stored.
+	WriterID string
+
+	// Identifies the application the entries of the segment file belong to. Empty values are not stored.
+	ApplicationID string
+
+	// Arbitrary key values provided by the user, like the version of the schema the entries are encoded with. Every
+	// key value is stored as its own record, with the key length encoded as two bytes followed by the key and the
+	// value.
+	UserData map[string]string
+
+	// The digest of the last entry of the previous segment file for the chained entry checksum types. The first entry
+	// of the segment file continues the chain from this digest. Empty values are not stored.
+	PreviousDigest []byte
+
+	// The compression of entries flagged with EntryFlagCompressed. Encoded as a single byte. CompressionNone is not
+	// stored.
+	Compression Compression
+}
+
+// MaxHeaderMetadataSize is the maximum size in bytes of all metadata records in a header.
+const MaxHeaderMetadataSize = 64 * 1024
+
+// metadataFrameSize is the number of bytes surrounding the metadata records. These are the length of the records and
+// the checksum.
+const metadataFrameSize = 4 + 4
+
+// metadataRecordType identifies the kind of value a metadata record holds.
+type metadataRecordType uint8
+
+const (
+	metadataRecordTypeCreatedAt metadataRecordType = iota + 1 // We do not start at 0 to detect missing values.
+	metadataRecordTypeWriterID
+	metadataRecordTypeApplicationID
+	metadataRecordTypeUserData
+	metadataRecordTypePreviousDigest
+	metadataRecordTypeCompression
+)
+
+// metadataRecordHeaderSize is the number of bytes in front of every record value. These are the record type and the
+// length of the value.
+const metadataRecordHeaderSize = 1 + 4
+
+// IsZero reports if the metadata holds no values.
+func (m HeaderMetadata) IsZero() bool {
+	return m.CreatedAt.IsZero() && m.WriterID == "" && m.ApplicationID == "" && len(m.UserData) == 0 && len(m.PreviousDigest) == 0 &&
+		m.Compression == CompressionNone
+}
+
+// size returns the number of bytes the metadata records occupy when encoded.
+func (m HeaderMetadata) size() int {
+	size := 0
+	if !m.CreatedAt.IsZero() {
+		size += metadataRecordHeaderSize + 8
+	}
+	if m.WriterID != "" {
+		size += metadataRecordHeaderSize + len(m.WriterID)
+	}
+	if m.ApplicationID != "" {
+		size += metadataRecordHeaderSize + len(m.ApplicationID)
+	}
+	for key, value := range m.UserData {
+		size += metadataRecordHeaderSize + 2 + len(key) + len(value)
+	}
+	if len(m.PreviousDigest) > 0 {
+		size += metadataRecordHeaderSize + len(m.PreviousDigest)
+	}
+	if m.Compression != CompressionNone {
+		size += metadataRecordHeaderSize + 1
+	}
+	return size
+}
+
+// appendMetadata appends the encoded metadata to the buffer. Returns ErrHeaderInvalidMetadata when the metadata is too
+// big or a user data key is too long. Returns ErrCompressionUnsupported for unknown compressions.
+func appendMetadata(buffer []byte, metadata HeaderMetadata) ([]byte, error) {
+	size := metadata.size()
+	if size > MaxHeaderMetadataSize {
+		return nil, fmt.Errorf("%d bytes exceed the maximum of %d bytes: %w", size, MaxHeaderMetadataSize, ErrHeaderInvalidMetadata)
+	}
+	start := len(buffer)
+	buffer = slices.Grow(buffer, metadataFrameSize+size)
+	buffer = Endian.AppendUint32(buffer, uint32(size)) //nolint:gosec // The size was checked above.
+
+	if !metadata.CreatedAt.IsZero() {
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypeCreatedAt, 8)
+		buffer = Endian.AppendUint64(buffer, uint64(metadata.CreatedAt.UnixNano())) //nolint:gosec // Times before the Unix epoch round-trip through the conversion.
+	}
+	if metadata.WriterID != "" {
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypeWriterID, len(metadata.WriterID))
+		buffer = append(buffer, metadata.WriterID...)
+	}
+	if metadata.ApplicationID != "" {
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypeApplicationID, len(metadata.ApplicationID))
+		buffer = append(buffer, metadata.ApplicationID...)
+	}
+	// We sort the keys to always produce the same header for the same metadata.
+	for _, key := range slices.Sorted(maps.Keys(metadata.UserData)) {
+		if len(key) > 0xffff {
+			return nil, fmt.Errorf("the user data key has %d bytes: %w", len(key), ErrHeaderInvalidMetadata)
+		}
+		value := metadata.UserData[key]
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypeUserData, 2+len(key)+len(value))
+		buffer = Endian.AppendUint16(buffer, uint16(len(key))) //nolint:gosec // The length was checked above.
+		buffer = append(buffer, key...)
+		buffer = append(buffer, value...)
+	}
+	if len(metadata.PreviousDigest) > 0 {
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypePreviousDigest, len(metadata.PreviousDigest))
+		buffer = append(buffer, metadata.PreviousDigest...)
+	}
+	if metadata.Compression != CompressionNone {
+		if !slices.Contains(Compressions, metadata.Compression) {
+			return nil, fmt.Errorf("compression %d: %w", metadata.Compression, ErrCompressionUnsupported)
+		}
+		buffer = appendMetadataRecordHeader(buffer, metadataRecordTypeCompression, 1)
+		buffer = append(buffer, byte(metadata.Compression))
+	}
+
+	buffer = Endian.AppendUint32(buffer, crc32.ChecksumIEEE(buffer[start:]))
+	return buffer, nil
+}
+
+func appendMetadataRecordHeader(buffer []byte, recordType metadataRecordType, length int) []byte {
+	buffer = append(buffer, byte(recordType))
+	return Endian.AppendUint32(buffer, uint32(length)) //nolint:gosec // The metadata size is limited.
+}
+
+// readMetadata reads the metadata following the fixed size part of the header. The buffer is used when it is big
+// enough for the metadata. Otherwise, a bigger buffer is allocated.
+func readMetadata(reader io.Reader, buffer []byte) (HeaderMetadata, error) {
+	if len(buffer) < metadataFrameSize {
+		buffer = make([]byte, metadataFrameSize)
+	}
+	if _, err := io.ReadFull(reader, buffer[:4]); err != nil {
+		return HeaderMetadata{}, headerReadError(err)
+	}
+	size := int(Endian.Uint32(buffer[:4]))
+	if size > MaxHeaderMetadataSize {
+		return HeaderMetadata{}, fmt.Errorf("%d bytes exceed the maximum of %d bytes: %w", size, MaxHeaderMetadataSize, ErrHeaderInvalidMetadata)
+	}
+	if len(buffer) < metadataFrameSize+size {
+		newBuffer := make([]byte, metadataFrameSize+size)
+		copy(newBuffer, buffer[:4])
+		buffer = newBuffer
+	}
+	buffer = buffer[:metadataFrameSize+size]
+	if _, err := io.ReadFull(reader, buffer[4:]); err != nil {
+		return HeaderMetadata{}, headerReadError(err)
+	}
+	if crc32.ChecksumIEEE(buffer[:4+size]) != Endian.Uint32(buffer[4+size:]) {
+		return HeaderMetadata{}, fmt.Errorf("checksum mismatch: %w", ErrHeaderInvalidMetadata)
+	}
+	return decodeMetadataRecords(buffer[4 : 4+size])
+}
+
+// decodeMetadataRecords decodes all metadata records. The values are copied, so that the buffer can be reused.
+func decodeMetadataRecords(records []byte) (HeaderMetadata, error) {
+	var metadata HeaderMetadata
+	for len(records) > 0 {
+		if len(records) < metadataRecordHeaderSize {
+			return HeaderMetadata{}, fmt.Errorf("truncated record: %w", ErrHeaderInvalidMetadata)
+		}
+		recordType := metadataRecordType(records[0])
+		length := int(Endian.Uint32(records[1:metadataRecordHeaderSize]))
+		records = records[metadataRecordHeaderSize:]
+		if length > len(records) {
+			return HeaderMetadata{}, fmt.Errorf("truncated record: %w", ErrHeaderInvalidMetadata)
+		}
+		value := records[:length]
+		records = records[length:]
+
+		switch recordType {
+		case metadataRecordTypeCreatedAt:
+			if length != 8 {
+				return HeaderMetadata{}, fmt.Errorf("creation time with %d bytes: %w", length, ErrHeaderInvalidMetadata)
+			}
+			metadata.CreatedAt = time.Unix(0, int64(Endian.Uint64(value))) //nolint:gosec // Times before the Unix epoch round-trip through the conversion.
+		case metadataRecordTypeWriterID:
+			metadata.WriterID = string(value)
+		case metadataRecordTypeApplicationID:
+			metadata.ApplicationID = string(value)
+		case metadataRecordTypeUserData:
+			if length < 2 || int(Endian.Uint16(value[:2])) > length-2 {
+				return HeaderMetadata{}, fmt.Errorf("truncated user data: %w", ErrHeaderInvalidMetadata)
+			}
+			keyLength := int(Endian.Uint16(value[:2]))
+			if metadata.UserData == nil {
+				metadata.UserData = make(map[string]string)
+			}
+			metadata.UserData[string(value[2:2+keyLength])] = string(value[2+keyLength:])
+		case metadataRecordTypePreviousDigest:
+			metadata.PreviousDigest = slices.Clone(value)
+		case metadataRecordTypeCompression:
+			if length != 1 {
+				return HeaderMetadata{}, fmt.Errorf("compression with %d bytes: %w", length, ErrHeaderInvalidMetadata)
+			}
+			metadata.Compression = Compression(value[0])
+			if !slices.Contains(Compressions, metadata.Compression) {
+				// Entries compressed with an unknown compression could not be read.
+				return HeaderMetadata{}, fmt.Errorf("compression %d: %w", metadata.Compression, ErrCompressionUnsupported)
+			}
+		default:
+			// Records of unknown types were written by newer versions and are skipped.
+		}
+	}
+	return metadata, nil
+}
@@ -2,8 +2,11 @@ package encoding_test
 
 import (
 	"bytes"
+	"hash/crc32"
 	"io"
+	"strings"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -17,7 +20,7 @@ var _ = Describe("Header", func() {
 		var output bytes.Buffer
 		var buffer [encoding.HeaderSize]byte
 		Expect(encoding.WriteHeader(&output, buffer[:], encoding.DefaultHeader)).To(Succeed())
-		Expect(output.Len()).To(Equal(encoding.HeaderSize))
+		Expect(output.Len()).To(BeEquivalentTo(encoding.DefaultHeader.Size()))
 	})
 
 	It("should read the header", func() {
@@ -31,6 +34,139 @@ var _ = Describe("Header", func() {
 		Expect(gotHeader).To(Equal(encoding.DefaultHeader))
 	})
 
+	It("should read a header of version 1", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion1
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+
+		gotHeader, err := encoding.ReadHeader(&output, buffer[:])
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(gotHeader).To(Equal(header))
+	})
+
+	It("should read a header of version 2", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion2
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+		Expect(output.Len()).To(Equal(encoding.HeaderSize))
+
+		gotHeader, err := encoding.ReadHeader(&output, buffer[:])
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(gotHeader).To(Equal(header))
+	})
+
+	It("should read the header with metadata", func() {
+		header := encoding.DefaultHeader
+		header.Metadata = encoding.HeaderMetadata{
+			CreatedAt:     time.Date(2025, 6, 1, 12, 30, 0, 123, time.UTC),
+			WriterID:      "orders-7f9c",
+			ApplicationID: "orders",
+			UserData: map[string]string{
+				"schema-version": "3",
+				"":               "empty key",
+				"empty value":    "",
+			},
+			PreviousDigest: bytes.Repeat([]byte{0xab}, encoding.EntryChainDigestSize),
+			Compression:    encoding.CompressionFlate,
+		}
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+		Expect(output.Len()).To(BeEquivalentTo(header.Size()))
+
+		gotHeader, err := encoding.ReadHeader(&output, buffer[:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gotHeader.Metadata.CreatedAt).To(BeTemporally("==", header.Metadata.CreatedAt))
+		gotHeader.Metadata.CreatedAt = header.Metadata.CreatedAt
+		Expect(gotHeader).To(Equal(header))
+	})
+
+	It("should skip metadata records of unknown types", func() {
+		header := encoding.DefaultHeader
+		header.Metadata.WriterID = "writer"
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+
+		// Change the type of the writer id record to a type which does not exist and fix up the checksum.
+		content := output.Bytes()
+		content[encoding.HeaderSize+4] = 0xff
+		metadataEnd := len(content) - 4
+		encoding.Endian.PutUint32(content[metadataEnd:], crc32.ChecksumIEEE(content[encoding.HeaderSize:metadataEnd]))
+
+		gotHeader, err := encoding.ReadHeader(&output, buffer[:])
+		Expect(err).ToNot(HaveOccurred())
+		Expect(gotHeader).To(Equal(encoding.DefaultHeader))
+	})
+
+	It("should fail reading the header with corrupt metadata", func() {
+		header := encoding.DefaultHeader
+		header.Metadata.ApplicationID = "orders"
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+
+		output.Bytes()[output.Len()-5] = 'X'
+		Expect(encoding.ReadHeader(&output, buffer[:])).Error().To(MatchError(encoding.ErrHeaderInvalidMetadata))
+	})
+
+	It("should fail writing metadata which is too big", func() {
+		header := encoding.DefaultHeader
+		header.Metadata.UserData = map[string]string{
+			"blob": strings.Repeat("x", encoding.MaxHeaderMetadataSize),
+		}
+
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(io.Discard, buffer[:], header)).To(MatchError(encoding.ErrHeaderInvalidMetadata))
+	})
+
+	It("should fail writing an unknown compression", func() {
+		header := encoding.DefaultHeader
+		header.Metadata.Compression = 0xff
+
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(io.Discard, buffer[:], header)).To(MatchError(encoding.ErrCompressionUnsupported))
+	})
+
+	It("should fail writing metadata with a header version which does not support it", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion2
+		header.Metadata.WriterID = "writer"
+
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(io.Discard, buffer[:], header)).To(MatchError(encoding.ErrHeaderInvalidMetadata))
+	})
+
+	It("should fail writing chained entry checksum types with a header version which does not support them", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion2
+		header.EntryChecksumType = encoding.EntryChecksumTypeSha256Chain
+
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(io.Discard, buffer[:], header)).To(MatchError(encoding.ErrEntryChecksumTypeUnsupported))
+	})
+
+	It("should fail reading the header with an unsupported version", func() {
+		header := encoding.DefaultHeader
+		header.Version = 0
+
+		var output bytes.Buffer
+		var buffer [encoding.HeaderSize]byte
+		Expect(encoding.WriteHeader(&output, buffer[:], header)).To(Succeed())
+		Expect(encoding.ReadHeader(&output, buffer[:])).Error().To(MatchError(encoding.ErrHeaderUnsupportedVersion))
+	})
+
 	It("should fail reading the header from an empty buffer", func() {
 		var input bytes.Buffer
 		var buffer [encoding.HeaderSize]byte
@@ -0,0 +1,163 @@
+package segment
+
+import (
+	"sync"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+)
+
+// compressorPools holds the compressors for every compression. Compressors need a lot of memory, so they are shared
+// by all entry compressors and only taken from the pool while a single entry is compressed.
+var compressorPools = func() map[encoding.Compression]*sync.Pool {
+	compressorPools := make(map[encoding.Compression]*sync.Pool)
+	for _, compression := range encoding.Compressions {
+		if compression == encoding.CompressionNone {
+			continue
+		}
+		compressorPools[compression] = &sync.Pool{
+			New: func() any {
+				// We only create pools for supported compressions, so this cannot fail.
+				compressor, _ := encoding.NewCompressor(compression)
+				return compressor
+			},
+		}
+	}
+	return compressorPools
+}()
+
+// EntryCompressor compresses entries ahead of appending them with SegmentWriter.AppendCompressedEntry. Compressing a
+// big entry takes a lot longer than writing it, so this allows compressing entries in parallel before taking the lock
+// which serializes the writes. It reuses its internal buffers between calls to avoid memory allocations.
+//
+// Instances of EntryCompressor are NOT safe to use concurrently. You need to provide external synchronization.
+type EntryCompressor struct {
+	// The compression of the entries. Entries are never compressed with encoding.CompressionNone.
+	compression encoding.Compression
+
+	// The pool of compressors for the compression. This is nil for encoding.CompressionNone.
+	compressorPool *sync.Pool
+
+	// The entry size in bytes above which entries are compressed.
+	compressionThreshold int
+
+	// This buffer is used to hold the compressed data of an entry.
+	compressionBuffer []byte
+
+	// This buffer is used to encode the envelope of typed entries.
+	envelopeBuffer []byte
+
+	// The parts of the last entry. This avoids allocating a new slice for every entry.
+	parts [2][]byte
+}
+
+// NewEntryCompressor creates a new EntryCompressor which compresses entries bigger than the compression threshold
+// with the given compression. Entries which do not get smaller are kept uncompressed.
+// Returns encoding.ErrCompressionUnsupported for unknown compressions.
+func NewEntryCompressor(compression encoding.Compression, compressionThreshold int) (*EntryCompressor, error) {
+	var compressorPool *sync.Pool
+	if compression != encoding.CompressionNone {
+		var ok bool
+		compressorPool, ok = compressorPools[compression]
+		if !ok {
+			return nil, encoding.ErrCompressionUnsupported
+		}
+	}
+	return &EntryCompressor{
+		compression:          compression,
+		compressorPool:       compressorPool,
+		compressionThreshold: compressionThreshold,
+	}, nil
+}
+
+// CompressedEntry is an entry which was prepared by an EntryCompressor. It is only valid until the EntryCompressor is
+// used again.
+type CompressedEntry struct {
+	// The compression the entry was prepared for.
+	compression encoding.Compression
+
+	// The flags of the entry.
+	flags encoding.EntryFlags
+
+	// The number of bytes of the data of the entry. This is reported in the metrics.
+	size int
+
+	// The uncompressed parts of the entry.
+	parts [][]byte
+
+	// The compressed data of the entry. This is nil when the entry is kept uncompressed.
+	compressed []byte
+}
+
+// Compress prepares an entry with the given data.
+func (c *EntryCompressor) Compress(data []byte) (CompressedEntry, error) {
+	c.parts[0] = data
+	return c.compress(0, len(data), c.parts[:1])
+}
+
+// CompressParts prepares a single entry which consists of the concatenation of all given parts.
+func (c *EntryCompressor) CompressParts(parts ...[]byte) (CompressedEntry, error) {
+	var size int
+	for _, part := range parts {
+		size += len(part)
+	}
+	return c.compress(0, size, parts)
+}
+
+// CompressTypedEntry prepares an entry together with its entry type, flags and headers.
+func (c *EntryCompressor) CompressTypedEntry(entry TypedEntry) (CompressedEntry, error) {
+	envelope, err := encoding.AppendEntryEnvelope(c.envelopeBuffer[:0], entry.Type, entry.Flags, entry.Headers)
+	if err != nil {
+		return CompressedEntry{}, err
+	}
+	c.envelopeBuffer = envelope
+
+	c.parts[0] = envelope
+	c.parts[1] = entry.Data
+	return c.compress(encoding.EntryFlagTyped, len(entry.Data), c.parts[:2])
+}
+
+// compress compresses the concatenation of all parts, when it is bigger than the compression threshold.
+func (c *EntryCompressor) compress(flags encoding.EntryFlags, size int, parts [][]byte) (CompressedEntry, error) {
+	flags, compressed, err := c.compressParts(flags, parts)
+	if err != nil {
+		return CompressedEntry{}, err
+	}
+	return CompressedEntry{
+		compression: c.compression,
+		flags:       flags,
+		size:        size,
+		parts:       parts,
+		compressed:  compressed,
+	}, nil
+}
+
+// compressParts compresses the concatenation of all parts, when it is bigger than the compression threshold. It
+// returns the flags of the entry together with the compressed data, which is nil when the entry is kept uncompressed.
+// The parts are not part of the result, so that they can stay on the stack of the caller.
+func (c *EntryCompressor) compressParts(flags encoding.EntryFlags, parts [][]byte) (encoding.EntryFlags, []byte, error) {
+	if c.compressorPool == nil {
+		return flags, nil, nil
+	}
+
+	var length int
+	for _, part := range parts {
+		length += len(part)
+	}
+	if length <= c.compressionThreshold {
+		return flags, nil, nil
+	}
+
+	compressor := c.compressorPool.Get().(*encoding.Compressor) //nolint:forcetypeassert // We only store compressors in the pool.
+	compressed, err := compressor.AppendCompressed(c.compressionBuffer[:0], parts...)
+	c.compressorPool.Put(compressor)
+	if err != nil {
+		return 0, nil, err
+	}
+	c.compressionBuffer = compressed
+
+	// We keep the entry uncompressed when compression does not make it smaller.
+	if len(compressed) >= length {
+		return flags, nil, nil
+	}
+	return flags | encoding.EntryFlagCompressed, compressed, nil
+}
@@ -0,0 +1,269 @@
+package segment
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+)
+
+// ErrIndexInvalid is returned when the content of an index file does not describe a valid index.
+var ErrIndexInvalid = errors.New("the WAL segment index is invalid")
+
+// IndexInterval is the minimum number of bytes between two entries recorded in the index of a segment file. A reader
+// seeking to a sequence number needs to read at most that many bytes of entries after jumping to the closest indexed
+// entry.
+const IndexInterval = 64 * 1024
+
+// indexRecordSize is the size in bytes of a single record in an index file. Every record consists of the sequence
+// number of an entry followed by the offset of that entry in the segment file, both encoded as eight bytes.
+const indexRecordSize = 8 + 8
+
+// IndexRecord describes the position of a single entry in a segment file.
+type IndexRecord struct {
+	// The sequence number of the entry. For a batch, this is the sequence number of the first entry in the batch.
+	SequenceNumber uint64
+
+	// The offset in bytes from the start of the segment file where the entry starts.
+	Offset int64
+}
+
+// ReadIndex reads all records from the index file. A partially written record at the end of the index file is ignored.
+// The header size is the size in bytes of the header of the segment file, see encoding.Header.Size.
+// Returns ErrIndexInvalid when the records are not strictly increasing or point into the segment header.
+func ReadIndex(indexFilePath string, headerSize int64) ([]IndexRecord, error) {
+	content, err := os.ReadFile(indexFilePath) //nolint:gosec // We can not validate paths in a library.
+	if err != nil {
+		return nil, err
+	}
+
+	records := make([]IndexRecord, 0, len(content)/indexRecordSize)
+	lastRecord := IndexRecord{Offset: headerSize - 1}
+	for len(content) >= indexRecordSize {
+		record := IndexRecord{
+			SequenceNumber: encoding.Endian.Uint64(content[0:8]),
+			Offset:         int64(encoding.Endian.Uint64(content[8:16])), //nolint:gosec // Overflows are caught by the validation below.
+		}
+		if record.Offset <= lastRecord.Offset || (len(records) > 0 && record.SequenceNumber <= lastRecord.SequenceNumber) {
+			return nil, fmt.Errorf("index file %q: %w", indexFilePath, ErrIndexInvalid)
+		}
+		records = append(records, record)
+		lastRecord = record
+		content = content[indexRecordSize:]
+	}
+	return records, nil
+}
+
+// BuildIndex reads all entries of the segment file and writes a new index file for it. The new index file is written
+// under a temporary name first and renamed afterward, so that readers never see a partially written index file. The
+// entry checksum key is only needed for segment files with the entry checksum type
+// encoding.EntryChecksumTypeHmacSha256Chain.
+func BuildIndex(directory string, firstSequenceNumber uint64, entryChecksumKey []byte) ([]IndexRecord, error) {
+	return buildIndex(path.Join(directory, SegmentFileName(firstSequenceNumber)), firstSequenceNumber, entryChecksumKey)
+}
+
+func buildIndex(segmentFilePath string, firstSequenceNumber uint64, entryChecksumKey []byte) ([]IndexRecord, error) {
+	records, err := collectIndex(segmentFilePath, firstSequenceNumber, entryChecksumKey)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeIndex(IndexFilePath(segmentFilePath), records); err != nil {
+		return nil, fmt.Errorf("writing the index for the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	return records, nil
+}
+
+// collectIndex reads all entries of the segment file and returns the records an index file for it would contain.
+// Returns encoding.ErrEntryChecksumKeyRequired when the entries cannot be verified without the entry checksum key, as
+// the records would be missing otherwise.
+func collectIndex(segmentFilePath string, firstSequenceNumber uint64, entryChecksumKey []byte) ([]IndexRecord, error) {
+	segmentReader, err := openSegment(segmentFilePath, firstSequenceNumber, openModeReadOnly)
+	if err != nil {
+		return nil, fmt.Errorf("the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	segmentReader.SetReadBuffer(DefaultReadBufferSize, false)
+	segmentReader.SetEntryChecksumKey(entryChecksumKey)
+
+	var records []IndexRecord
+	lastOffset := segmentReader.Offset()
+	for {
+		// Only entries read from the segment file itself can be indexed. Entries yielded from a batch do not have an
+		// offset of their own.
+		record := IndexRecord{
+			SequenceNumber: segmentReader.NextSequenceNumber(),
+			Offset:         segmentReader.Offset(),
+		}
+		startsEntry := segmentReader.batchReader.Len() == 0
+		if !segmentReader.Next() {
+			break
+		}
+		if startsEntry && record.Offset-lastOffset >= IndexInterval {
+			records = append(records, record)
+			lastOffset = record.Offset
+		}
+	}
+	if err := segmentReader.Err(); errors.Is(err, encoding.ErrEntryChecksumKeyRequired) {
+		return nil, errors.Join(
+			fmt.Errorf("indexing the WAL segment file %q: %w", segmentFilePath, err),
+			segmentReader.Close(),
+		)
+	}
+
+	if err := segmentReader.Close(); err != nil {
+		return nil, fmt.Errorf("closing the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	return records, nil
+}
+
+// writeIndex writes the records to a new index file. The index file is written under a temporary name first and
+// renamed afterward.
+func writeIndex(indexFilePath string, records []IndexRecord) error {
+	newIndexFilePath := indexFilePath + ".new"
+	file, err := os.OpenFile(newIndexFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o664) //nolint:gosec // We can not validate paths in a library.
+	if err != nil {
+		return fmt.Errorf("creating index file: %w", err)
+	}
+	var buffer [indexRecordSize]byte
+	for _, record := range records {
+		if err := writeIndexRecord(file, buffer[:], record); err != nil {
+			return errors.Join(fmt.Errorf("writing index file: %w", err), file.Close())
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("closing index file: %w", err)
+	}
+	if err := os.Rename(newIndexFilePath, indexFilePath); err != nil {
+		return fmt.Errorf("renaming index file: %w", err)
+	}
+	return nil
+}
+
+// IndexFilePath returns the path of the index file which belongs to the segment file with the given path.
+func IndexFilePath(segmentFilePath string) string {
+	return strings.TrimSuffix(segmentFilePath, segmentFileExtension) + indexFileExtension
+}
+
+// createIndex creates an empty index file. An existing index file is truncated.
+func createIndex(indexFilePath string) error {
+	file, err := os.OpenFile(indexFilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o664) //nolint:gosec // We can not validate paths in a library.
+	if err != nil {
+		return fmt.Errorf("creating index file %q: %w", indexFilePath, err)
+	}
+	return file.Close()
+}
+
+// loadIndex reads the index file which belongs to the segment file with the given header. A missing or invalid index
+// file is built again from the segment file.
+func loadIndex(segmentFilePath string, header encoding.Header, entryChecksumKey []byte) ([]IndexRecord, error) {
+	records, err := ReadIndex(IndexFilePath(segmentFilePath), header.Size())
+	if err == nil {
+		return records, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrIndexInvalid) {
+		return nil, err
+	}
+	return buildIndex(segmentFilePath, header.FirstSequenceNumber, entryChecksumKey)
+}
+
+// pruneIndex removes all records for entries at or after the given offset from the index file. An invalid index file
+// is removed, so that it is built again when needed.
+func pruneIndex(indexFilePath string, headerSize int64, offset int64) error {
+	records, err := ReadIndex(indexFilePath, headerSize)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if errors.Is(err, ErrIndexInvalid) {
+		return os.Remove(indexFilePath)
+	}
+	if err != nil {
+		return err
+	}
+	keep := len(records)
+	for keep > 0 && records[keep-1].Offset >= offset {
+		keep--
+	}
+	if keep == len(records) {
+		return nil
+	}
+	return writeIndex(indexFilePath, records[:keep])
+}
+
+func writeIndexRecord(writer io.Writer, buffer []byte, record IndexRecord) error {
+	encoding.Endian.PutUint64(buffer[0:8], record.SequenceNumber)
+	encoding.Endian.PutUint64(buffer[8:16], uint64(record.Offset)) //nolint:gosec // Offsets are never negative.
+	_, err := writer.Write(buffer[:indexRecordSize])
+	return err
+}
+
+// indexWriter appends records to the index file while entries are appended to the segment file.
+type indexWriter struct {
+	// The index file to append records to.
+	file *os.File
+
+	// The offset of the last entry which was recorded in the index.
+	lastOffset int64
+
+	// This is a temporary buffer for encoding a record.
+	buffer [indexRecordSize]byte
+}
+
+// openIndexWriter opens the index file of the segment file with the given header for appending records. All records
+// for entries at or after the given offset are removed from the index file, as they do not describe entries of the
+// segment file anymore.
+func openIndexWriter(segmentFilePath string, header encoding.Header, offset int64, entryChecksumKey []byte) (*indexWriter, error) {
+	records, err := loadIndex(segmentFilePath, header, entryChecksumKey)
+	if err != nil {
+		return nil, err
+	}
+	indexFilePath := IndexFilePath(segmentFilePath)
+	keep := len(records)
+	for keep > 0 && records[keep-1].Offset >= offset {
+		keep--
+	}
+
+	file, err := os.OpenFile(indexFilePath, os.O_WRONLY|os.O_APPEND, 0) //nolint:gosec // We can not validate paths in a library.
+	if err != nil {
+		return nil, fmt.Errorf("opening index file %q: %w", indexFilePath, err)
+	}
+	// Truncating also removes a partially written record at the end of the index file.
+	if err := file.Truncate(int64(keep) * indexRecordSize); err != nil {
+		return nil, errors.Join(fmt.Errorf("truncating index file %q: %w", indexFilePath, err), file.Close())
+	}
+
+	lastOffset := header.Size()
+	if keep > 0 {
+		lastOffset = records[keep-1].Offset
+	}
+	return &indexWriter{
+		file:       file,
+		lastOffset: lastOffset,
+	}, nil
+}
+
+// entryWritten records the entry in the index file, when the entry is far enough away from the last recorded entry.
+// It returns false when the record could not be written. The index file must not be written to anymore in that case.
+func (i *indexWriter) entryWritten(sequenceNumber uint64, offset int64) bool {
+	if offset-i.lastOffset < IndexInterval {
+		return true
+	}
+	record := IndexRecord{
+		SequenceNumber: sequenceNumber,
+		Offset:         offset,
+	}
+	if err := writeIndexRecord(i.file, i.buffer[:], record); err != nil {
+		log.Printf("WARNING: Writing to the WAL segment index file %q failed: %v\n", i.file.Name(), err)
+		return false
+	}
+	i.lastOffset = offset
+	return true
+}
+
+// Close closes the index file.
+func (i *indexWriter) Close() error {
+	return i.file.Close()
+}
@@ -0,0 +1,223 @@
+package segment_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+	"github.com/backbone81/write-ahead-log/internal/segment"
+)
+
+var _ = Describe("Index", func() {
+	var dir string
+	var indexFilePath string
+	var headerSize int64
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "test-index-*")
+		Expect(err).ToNot(HaveOccurred())
+		indexFilePath = segment.IndexFilePath(path.Join(dir, segment.SegmentFileName(0)))
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	// entryData returns the data of the entry with the given sequence number.
+	entryData := func(sequenceNumber uint64) []byte {
+		return bytes.Repeat(binary.LittleEndian.AppendUint64(nil, sequenceNumber), 128)
+	}
+
+	// writeEntries appends 1000 entries of 1 KiB each. Every tenth entry is written as part of a batch of three
+	// entries.
+	writeEntries := func() {
+		writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+			PreAllocationSize:   segment.DefaultPreAllocationSize,
+			EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+			EntryChecksumType:   encoding.DefaultEntryChecksumType,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		headerSize = writer.Header().Size()
+		for sequenceNumber := uint64(0); sequenceNumber < 1000; {
+			if sequenceNumber%10 == 0 && sequenceNumber+3 <= 1000 {
+				Expect(writer.AppendEntries([][]byte{
+					entryData(sequenceNumber),
+					entryData(sequenceNumber + 1),
+					entryData(sequenceNumber + 2),
+				})).To(Equal(sequenceNumber))
+				sequenceNumber += 3
+				continue
+			}
+			Expect(writer.AppendEntry(entryData(sequenceNumber))).To(Equal(sequenceNumber))
+			sequenceNumber++
+		}
+		Expect(writer.Close()).To(Succeed())
+	}
+
+	// expectSeek seeks to some sequence numbers and checks that the entry read afterward is the right one. When the
+	// index is used, the reader needs to be close to the sequence number after seeking.
+	expectSeek := func(readOnly bool, indexed bool) {
+		for _, sequenceNumber := range []uint64{0, 1, 63, 64, 65, 500, 501, 502, 999} {
+			var reader *segment.SegmentReader
+			var err error
+			if readOnly {
+				reader, err = segment.OpenSegmentReadOnly(dir, 0)
+			} else {
+				reader, err = segment.OpenSegment(dir, 0)
+			}
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.SeekIndex(sequenceNumber)).To(Succeed())
+			Expect(reader.NextSequenceNumber()).To(BeNumerically("<=", sequenceNumber))
+			if indexed {
+				Expect(sequenceNumber - reader.NextSequenceNumber()).To(BeNumerically("<", segment.IndexInterval/1024+3))
+			} else {
+				Expect(reader.NextSequenceNumber()).To(BeZero())
+			}
+			for reader.NextSequenceNumber() < sequenceNumber {
+				Expect(reader.Next()).To(BeTrue())
+			}
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().SequenceNumber).To(Equal(sequenceNumber))
+			Expect(reader.Value().Data).To(Equal(entryData(sequenceNumber)))
+			Expect(reader.Close()).To(Succeed())
+		}
+	}
+
+	It("should record entries in the index file while writing", func() {
+		writeEntries()
+
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(records)).To(BeNumerically(">=", 1000*1024/segment.IndexInterval-1))
+		for i := 1; i < len(records); i++ {
+			Expect(records[i].Offset - records[i-1].Offset).To(BeNumerically(">=", segment.IndexInterval))
+		}
+		expectSeek(false, true)
+		expectSeek(true, true)
+	})
+
+	// continueWriting reads all entries of the segment file and converts the reader into a writer, which is what
+	// continuing to write to an existing segment file looks like.
+	continueWriting := func() {
+		reader, err := segment.OpenSegment(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		for reader.Next() {
+		}
+		writer, err := reader.ToWriter()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+	}
+
+	It("should build the same index file again when it is missing", func() {
+		writeEntries()
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.Remove(indexFilePath)).To(Succeed())
+		expectSeek(false, false)
+		Expect(indexFilePath).ToNot(BeAnExistingFile())
+
+		continueWriting()
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).To(Equal(records))
+		expectSeek(false, true)
+	})
+
+	It("should build the index file again when it is invalid", func() {
+		writeEntries()
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+
+		Expect(os.WriteFile(indexFilePath, bytes.Repeat([]byte{0xff}, 32), 0o600)).To(Succeed())
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).Error().To(MatchError(segment.ErrIndexInvalid))
+		expectSeek(false, false)
+
+		continueWriting()
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).To(Equal(records))
+		expectSeek(false, true)
+	})
+
+	It("should reject records which point into the header metadata", func() {
+		writeEntries()
+		Expect(headerSize).To(BeNumerically(">", encoding.HeaderSize))
+
+		record := make([]byte, 0, 16)
+		record = binary.LittleEndian.AppendUint64(record, 1)
+		record = binary.LittleEndian.AppendUint64(record, uint64(encoding.HeaderSize))
+		Expect(os.WriteFile(indexFilePath, record, 0o600)).To(Succeed())
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).Error().To(MatchError(segment.ErrIndexInvalid))
+	})
+
+	It("should not build the index file when reading read-only", func() {
+		writeEntries()
+		Expect(os.Remove(indexFilePath)).To(Succeed())
+		expectSeek(true, false)
+		Expect(indexFilePath).ToNot(BeAnExistingFile())
+	})
+
+	It("should ignore records which do not point to a valid entry", func() {
+		writeEntries()
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+
+		By("record an entry which never made it into the segment file")
+		file, err := os.OpenFile(indexFilePath, os.O_WRONLY|os.O_APPEND, 0)
+		Expect(err).ToNot(HaveOccurred())
+		record := make([]byte, 0, 16)
+		record = binary.LittleEndian.AppendUint64(record, 2000)
+		record = binary.LittleEndian.AppendUint64(record, uint64(records[len(records)-1].Offset+4*segment.IndexInterval))
+		Expect(file.Write(record)).To(Equal(16))
+		Expect(file.Close()).To(Succeed())
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).To(HaveLen(len(records) + 1))
+
+		reader, err := segment.OpenSegmentReadOnly(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.SeekIndex(2000)).To(Succeed())
+		Expect(reader.NextSequenceNumber()).To(BeZero())
+		for reader.Next() {
+		}
+		Expect(reader.NextSequenceNumber()).To(Equal(uint64(1000)))
+		Expect(reader.Close()).To(Succeed())
+		expectSeek(true, true)
+	})
+
+	It("should ignore a partially written record at the end", func() {
+		writeEntries()
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+
+		file, err := os.OpenFile(indexFilePath, os.O_WRONLY|os.O_APPEND, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.Write([]byte{1, 2, 3})).To(Equal(3))
+		Expect(file.Close()).To(Succeed())
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).To(Equal(records))
+	})
+
+	It("should remove records after the offset the writer continues at", func() {
+		writeEntries()
+		records, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(records)).To(BeNumerically(">", 2))
+
+		writer, err := segment.TruncateSegment(dir, 0, records[1].SequenceNumber, segment.DefaultPreAllocationSize, nil)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.Offset()).To(Equal(records[1].Offset))
+		Expect(segment.ReadIndex(indexFilePath, headerSize)).To(Equal(records[:1]))
+
+		By("recording the entries appended afterward")
+		for sequenceNumber := records[1].SequenceNumber; sequenceNumber < 1000; sequenceNumber++ {
+			Expect(writer.AppendEntry(entryData(sequenceNumber))).To(Equal(sequenceNumber))
+		}
+		Expect(writer.Close()).To(Succeed())
+		newRecords, err := segment.ReadIndex(indexFilePath, headerSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(len(newRecords)).To(BeNumerically(">", 2))
+		Expect(newRecords[0]).To(Equal(records[0]))
+		expectSeek(false, true)
+	})
+})
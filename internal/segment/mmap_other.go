@@ -0,0 +1,22 @@
+//go:build !unix
+
+package segment
+
+import (
+	"os"
+)
+
+// mapFile reads the first size bytes of the file into memory. Memory-mapped files are not supported on this platform.
+// Reading the whole file provides the same behavior, but without the performance benefits.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	mapping := make([]byte, size)
+	if _, err := file.ReadAt(mapping, 0); err != nil {
+		return nil, err
+	}
+	return mapping, nil
+}
+
+// unmapFile releases the mapping created by mapFile.
+func unmapFile(mapping []byte) error {
+	return nil
+}
@@ -0,0 +1,29 @@
+//go:build unix
+
+package segment
+
+import (
+	"errors"
+	"math"
+	"os"
+	"syscall"
+)
+
+// mapFile maps the first size bytes of the file into memory read-only.
+func mapFile(file *os.File, size int64) ([]byte, error) {
+	if size == 0 {
+		return []byte{}, nil
+	}
+	if size > math.MaxInt {
+		return nil, errors.New("the file is too big to be mapped into memory")
+	}
+	return syscall.Mmap(int(file.Fd()), 0, int(size), syscall.PROT_READ, syscall.MAP_SHARED) //nolint:gosec // File descriptors always fit into an int.
+}
+
+// unmapFile releases the mapping created by mapFile.
+func unmapFile(mapping []byte) error {
+	if len(mapping) == 0 {
+		return nil
+	}
+	return syscall.Munmap(mapping)
+}
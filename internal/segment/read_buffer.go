@@ -0,0 +1,146 @@
+package segment
+
+import (
+	"io"
+)
+
+// DefaultReadBufferSize is the size in bytes of the buffer for reading segment files when not configured otherwise.
+const DefaultReadBufferSize = 64 * 1024
+
+// readBuffer buffers reads from a segment file to reduce the number of read calls. Without a buffer, every entry needs
+// at least one read call for the length, the data and the checksum each, and the uvarint length encoding even needs one
+// read call per byte.
+//
+// With read-ahead enabled, the next chunk of the segment file is read on a separate Go routine while the entries of the
+// current chunk are decoded.
+//
+// The file position is ahead of the position of the reader by the number of buffered bytes. Call discard before moving
+// the file position, so that buffered bytes are not mixed up with bytes read from the new file position.
+type readBuffer struct {
+	// The file to read from.
+	file io.Reader
+
+	// The chunk of the segment file we are currently reading from. The bytes not yet read are buffer[start:end].
+	buffer []byte
+	start  int
+	end    int
+
+	// The error returned by the file when reading the current chunk. It is returned after all bytes of the chunk were
+	// read.
+	err error
+
+	// Reports if the next chunk is read on a separate Go routine while the current chunk is read.
+	readAhead bool
+
+	// The buffer the next chunk is read into. This is nil while the read of the next chunk is in progress.
+	spare []byte
+
+	// Reports if the read of the next chunk is in progress. The result is delivered through results.
+	pending bool
+
+	// Delivers the result of reading the next chunk.
+	results chan readResult
+}
+
+// readResult is the result of reading a chunk of the segment file on a separate Go routine.
+type readResult struct {
+	buffer []byte
+	n      int
+	err    error
+}
+
+// newReadBuffer creates a new read buffer with the given size over the file.
+func newReadBuffer(file io.Reader, size int, readAhead bool) *readBuffer {
+	b := readBuffer{
+		file:      file,
+		buffer:    make([]byte, size),
+		readAhead: readAhead,
+	}
+	if readAhead {
+		b.spare = make([]byte, size)
+		b.results = make(chan readResult, 1)
+	}
+	return &b
+}
+
+// Read implements io.Reader.
+func (b *readBuffer) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if b.start == b.end {
+		if b.err != nil {
+			return 0, b.takeErr()
+		}
+		if !b.pending && len(p) >= len(b.buffer) {
+			// Big reads go to the file directly. Copying them through the buffer would not save any read calls.
+			return b.file.Read(p)
+		}
+		b.fill()
+		if b.start == b.end {
+			return 0, b.takeErr()
+		}
+	}
+	n := copy(p, b.buffer[b.start:b.end])
+	b.start += n
+	return n, nil
+}
+
+// fill reads the next chunk of the segment file into the buffer. The buffer must be empty.
+func (b *readBuffer) fill() {
+	if !b.readAhead {
+		b.start = 0
+		b.end, b.err = b.file.Read(b.buffer)
+		return
+	}
+
+	if !b.pending {
+		b.readNext()
+	}
+	result := <-b.results
+	b.pending = false
+	b.spare = b.buffer
+	b.buffer = result.buffer
+	b.start = 0
+	b.end = result.n
+	b.err = result.err
+	if b.err == nil {
+		// Read the chunk after this one while the entries of this chunk are decoded.
+		b.readNext()
+	}
+}
+
+// readNext starts reading the next chunk of the segment file into the spare buffer on a separate Go routine.
+func (b *readBuffer) readNext() {
+	buffer := b.spare
+	b.spare = nil
+	b.pending = true
+	go func() {
+		n, err := b.file.Read(buffer)
+		b.results <- readResult{
+			buffer: buffer,
+			n:      n,
+			err:    err,
+		}
+	}()
+}
+
+// takeErr returns the error of the last read and clears it, so that the next read tries reading from the file again.
+func (b *readBuffer) takeErr() error {
+	err := b.err
+	b.err = nil
+	return err
+}
+
+// discard drops all buffered bytes and waits for a read in progress to finish. Afterward, the file position can be
+// moved safely.
+func (b *readBuffer) discard() {
+	if b.pending {
+		result := <-b.results
+		b.pending = false
+		b.spare = result.buffer
+	}
+	b.start = 0
+	b.end = 0
+	b.err = nil
+}
@@ -1,17 +1,90 @@
 package segment
 
 import (
+	"bytes"
+	"cmp"
 	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path"
+	"slices"
 
 	"github.com/backbone81/write-ahead-log/internal/encoding"
 	"github.com/backbone81/write-ahead-log/internal/utils"
 )
 
-var ErrEntryNone = errors.New("this is no WAL entry")
+var (
+	ErrEntryNone       = errors.New("this is no WAL entry")
+	ErrEntryNotWritten = errors.New("the WAL entry is not yet written")
+	ErrEntryTorn       = errors.New("the WAL entry is torn or corrupt")
+	ErrEntryCorrupt    = errors.New("the WAL entry is corrupt")
+	ErrReadOnly        = errors.New("the WAL segment file is opened read-only")
+)
+
+// EntryError describes why an entry could not be read from a segment file. It always matches ErrEntryNone with
+// errors.Is. The kind of the failure is ErrEntryNotWritten, ErrEntryTorn or ErrEntryCorrupt and can be checked with
+// errors.Is as well.
+type EntryError struct {
+	// Path is the path of the segment file.
+	Path string
+
+	// Offset is the offset in bytes from the start of the segment file where the entry starts.
+	Offset int64
+
+	// SequenceNumber is the sequence number the entry would have received.
+	SequenceNumber uint64
+
+	// Kind is ErrEntryNotWritten, ErrEntryTorn or ErrEntryCorrupt. This is nil when the kind of the failure could not
+	// be determined.
+	Kind error
+
+	// Err is the error which occurred while reading the entry.
+	Err error
+}
+
+// Error implements the error interface.
+func (e *EntryError) Error() string {
+	kind := e.Kind
+	if kind == nil {
+		kind = ErrEntryNone
+	}
+	return fmt.Sprintf(
+		"%v: sequence number %d at offset %d of the WAL segment file %q: %v",
+		kind,
+		e.SequenceNumber,
+		e.Offset,
+		e.Path,
+		e.Err,
+	)
+}
+
+// Unwrap allows errors.Is and errors.As to match ErrEntryNone, the kind of the failure and the error which occurred.
+func (e *EntryError) Unwrap() []error {
+	if e.Kind == nil {
+		return []error{ErrEntryNone, e.Err}
+	}
+	return []error{ErrEntryNone, e.Kind, e.Err}
+}
+
+// entryProbeSize is the number of bytes we look at for deciding if an entry was not yet written. It is bigger than
+// the smallest possible entry, so that we always see parts of the checksum of an entry which was written.
+const entryProbeSize = 32
+
+// openMode describes how a segment file is opened for reading.
+type openMode int
+
+const (
+	// openModeReadWrite opens the segment file for reading and writing. This allows converting the reader into a
+	// writer.
+	openModeReadWrite openMode = iota
+
+	// openModeReadOnly opens the segment file read-only.
+	openModeReadOnly
+
+	// openModeMapped opens the segment file read-only and maps it into memory.
+	openModeMapped
+)
 
 // SegmentReaderFile is an interface which needs to be implemented by the file to read from.
 type SegmentReaderFile interface {
@@ -29,6 +102,12 @@ type SegmentReader struct {
 	// The segment file to read from.
 	file SegmentReaderFile
 
+	// The reader to read entries from. This is either the segment file itself or readBuffer.
+	reader io.Reader
+
+	// The buffer for reading the segment file. This is nil when reads are not buffered.
+	readBuffer *readBuffer
+
 	// The header of the segment file.
 	header encoding.Header
 
@@ -45,9 +124,49 @@ type SegmentReader struct {
 	// The reader to calculate and read the checksum.
 	entryChecksumReader encoding.EntryChecksumReader
 
+	// The chain of digests for the chained entry checksum types. This is nil for all other checksum types.
+	entryChain *encoding.EntryChain
+
+	// The key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain. It is needed for building the index
+	// file as well.
+	entryChecksumKey []byte
+
+	// The number of bytes the entry flags occupy for every entry. This is zero for segment files which do not support
+	// entry flags.
+	entryFlagsSize int
+
 	// The buffer to hold the entry data.
 	data []byte
 
+	// This is a temporary buffer for converting slices of bytes into integers while decoding entries of a batch or
+	// entries from the mapping. We can not use data for that, because data holds the batch itself.
+	scratchBuffer [max(encoding.MaxLengthBufferLen, encoding.MaxChecksumBufferLen)]byte
+
+	// The content of the segment file when it was mapped into memory. Entries are decoded from the mapping instead of
+	// being read from the file, and the entry data points into the mapping. This is nil when the segment file is not
+	// mapped.
+	mapping []byte
+
+	// The reader over mapping for decoding the length and the checksum of an entry.
+	mappingReader bytes.Reader
+
+	// The headers of the last typed entry. The slice is reused for every typed entry to avoid allocations.
+	headers []encoding.EntryHeader
+
+	// The decompressor for entries flagged as compressed. It is created for the first compressed entry.
+	decompressor *encoding.Decompressor
+
+	// The buffer to hold the decompressed data of the last compressed entry.
+	decompressed []byte
+
+	// The data of the batch entry we are currently yielding entries from. This points into data, the mapping or
+	// decompressed.
+	batch []byte
+
+	// The reader over batch which is positioned at the next entry of the batch to yield. There are no more entries
+	// left in the batch when its length is zero.
+	batchReader bytes.Reader
+
 	// The total size of the file in bytes. This is used together with offset to calculate the available data until
 	// the end of file. This helps with avoiding large memory allocations with malformed files.
 	fileSize int64
@@ -57,6 +176,12 @@ type SegmentReader struct {
 
 	// The error for the last operation. If this is nil, the content of value can be used.
 	err error
+
+	// Reports if the segment file was opened read-only. A read-only segment reader can not be converted into a writer.
+	readOnly bool
+
+	// The path of the segment file for locating the index file next to it. This is empty when there is no index file.
+	segmentFilePath string
 }
 
 // SegmentReaderValue is the value returned by the SegmentReader.
@@ -64,8 +189,20 @@ type SegmentReaderValue struct {
 	// The sequence number of the entry.
 	SequenceNumber uint64
 
-	// The data of the entry.
+	// The data of the entry. Compressed entries are decompressed.
 	Data []byte
+
+	// The type of the entry. This is encoding.EntryTypeData for entries which were appended without an entry type.
+	// Entry types starting with encoding.EntryTypeControl mark control records, which readers should skip when they
+	// do not know them.
+	Type encoding.EntryType
+
+	// The flags of the entry as defined by the application.
+	Flags uint8
+
+	// The headers of the entry. This is nil for entries without headers. The values point into the same buffer as
+	// Data and are only valid as long as Data is.
+	Headers []encoding.EntryHeader
 }
 
 // OpenSegment creates a new segment reader for the file path given as parameter.
@@ -74,16 +211,49 @@ type SegmentReaderValue struct {
 // Returns an error if the file cannot be opened, read from or the header is malformed.
 func OpenSegment(directory string, firstSequenceNumber uint64) (*SegmentReader, error) {
 	segmentFilePath := path.Join(directory, SegmentFileName(firstSequenceNumber))
-	segmentReader, err := openSegment(segmentFilePath, firstSequenceNumber)
+	segmentReader, err := openSegment(segmentFilePath, firstSequenceNumber, openModeReadWrite)
+	if err != nil {
+		return nil, fmt.Errorf("the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	return segmentReader, nil
+}
+
+// OpenSegmentReadOnly creates a new segment reader like OpenSegment, but opens the segment file read-only. This allows
+// reading segment files on read-only file systems or without write permissions. The returned SegmentReader can not be
+// converted into a writer.
+func OpenSegmentReadOnly(directory string, firstSequenceNumber uint64) (*SegmentReader, error) {
+	segmentFilePath := path.Join(directory, SegmentFileName(firstSequenceNumber))
+	segmentReader, err := openSegment(segmentFilePath, firstSequenceNumber, openModeReadOnly)
 	if err != nil {
 		return nil, fmt.Errorf("the WAL segment file %q: %w", segmentFilePath, err)
 	}
 	return segmentReader, nil
 }
 
-func openSegment(segmentFilePath string, firstSequenceNumber uint64) (*SegmentReader, error) {
-	file, err := os.OpenFile(segmentFilePath, os.O_RDWR, 0) //nolint:gosec // We can not validate paths in a library.
+// OpenSegmentMapped creates a new segment reader like OpenSegmentReadOnly, but maps the whole segment file into memory.
+// Entries are decoded from the mapping without any read calls, and the data of the entries points into the mapping
+// without being copied. Compressed entries are decompressed into a new buffer each. This is only useful for sealed
+// segment files, because entries appended after opening the segment file are not visible. The mapping is released
+// when the SegmentReader is closed.
+func OpenSegmentMapped(directory string, firstSequenceNumber uint64) (*SegmentReader, error) {
+	segmentFilePath := path.Join(directory, SegmentFileName(firstSequenceNumber))
+	segmentReader, err := openSegment(segmentFilePath, firstSequenceNumber, openModeMapped)
 	if err != nil {
+		return nil, fmt.Errorf("the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	return segmentReader, nil
+}
+
+func openSegment(segmentFilePath string, firstSequenceNumber uint64, mode openMode) (*SegmentReader, error) {
+	flag := os.O_RDWR
+	if mode != openModeReadWrite {
+		flag = os.O_RDONLY
+	}
+	file, err := os.OpenFile(segmentFilePath, flag, 0) //nolint:gosec // We can not validate paths in a library.
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			err = errors.Join(err, ErrSegmentNotFound)
+		}
 		return nil, fmt.Errorf("opening file: %w", err)
 	}
 
@@ -111,6 +281,8 @@ func openSegment(segmentFilePath string, firstSequenceNumber uint64) (*SegmentRe
 		FileSize:           fileInfo.Size(),
 		Offset:             currOffset,
 		NextSequenceNumber: firstSequenceNumber,
+		ReadOnly:           mode != openModeReadWrite,
+		SegmentFilePath:    segmentFilePath,
 	})
 	if err != nil {
 		if closeErr := file.Close(); closeErr != nil {
@@ -118,6 +290,14 @@ func openSegment(segmentFilePath string, firstSequenceNumber uint64) (*SegmentRe
 		}
 		return nil, err
 	}
+
+	if mode == openModeMapped {
+		mapping, err := mapFile(file, fileInfo.Size())
+		if err != nil {
+			return nil, errors.Join(fmt.Errorf("mapping file: %w", err), file.Close())
+		}
+		segmentReader.mapping = mapping
+	}
 	return segmentReader, nil
 }
 
@@ -134,6 +314,21 @@ type NewSegmentReaderConfig struct {
 
 	// FileSize is the total size in bytes of the segment file.
 	FileSize int64
+
+	// ReadOnly prevents the SegmentReader from being converted into a writer.
+	ReadOnly bool
+
+	// SegmentFilePath is the path of the segment file. When set, the index file next to the segment file is used for
+	// seeking.
+	SegmentFilePath string
+
+	// EntryChecksumKey is the key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain. Without the
+	// key, reading entries of such segment files fails with encoding.ErrEntryChecksumKeyRequired.
+	EntryChecksumKey []byte
+
+	// PreviousDigest is the digest of the entry in front of Offset for the chained entry checksum types. When empty,
+	// the previous digest stored in the header metadata is used.
+	PreviousDigest []byte
 }
 
 // NewSegmentReader creates a SegmentReader from a file which is already open.
@@ -143,23 +338,79 @@ func NewSegmentReader(file SegmentReaderFile, newSegmentReaderConfig NewSegmentR
 		return nil, err
 	}
 
-	entryChecksumReader, err := encoding.GetEntryChecksumReader(newSegmentReaderConfig.Header.EntryChecksumType)
-	if err != nil {
-		return nil, err
+	var entryChecksumReader encoding.EntryChecksumReader
+	var entryChain *encoding.EntryChain
+	if newSegmentReaderConfig.Header.EntryChecksumType.IsChained() {
+		previousDigest := newSegmentReaderConfig.PreviousDigest
+		if len(previousDigest) == 0 {
+			previousDigest = newSegmentReaderConfig.Header.Metadata.PreviousDigest
+		}
+		entryChain, err = encoding.NewEntryChain(newSegmentReaderConfig.Header.EntryChecksumType, newSegmentReaderConfig.EntryChecksumKey, previousDigest)
+		if err != nil {
+			return nil, err
+		}
+		entryChecksumReader = entryChain.ReadEntryChecksum
+	} else {
+		entryChecksumReader, err = encoding.GetEntryChecksumReader(newSegmentReaderConfig.Header.EntryChecksumType)
+		if err != nil {
+			return nil, err
+		}
 	}
 
 	return &SegmentReader{
 		file:                file,
+		reader:              file,
 		header:              newSegmentReaderConfig.Header,
 		offset:              newSegmentReaderConfig.Offset,
 		nextSequenceNumber:  newSegmentReaderConfig.NextSequenceNumber,
 		entryLengthReader:   entryLengthReader,
 		entryChecksumReader: entryChecksumReader,
+		entryChain:          entryChain,
+		entryChecksumKey:    newSegmentReaderConfig.EntryChecksumKey,
+		entryFlagsSize:      encoding.EntryFlagsSize(newSegmentReaderConfig.Header.Version),
 		data:                make([]byte, 4*1024), // Pre-allocate the data slice to reduce the number of allocations.
 		fileSize:            newSegmentReaderConfig.FileSize,
+		readOnly:            newSegmentReaderConfig.ReadOnly,
+		segmentFilePath:     newSegmentReaderConfig.SegmentFilePath,
 	}, nil
 }
 
+// SetReadBuffer reads the segment file in chunks of the given size instead of reading every part of an entry
+// separately. With read-ahead enabled, the next chunk is read on a separate Go routine while the entries of the current
+// chunk are decoded. A size of zero or less disables the buffer. Segment files mapped into memory are never buffered.
+// This needs to be called before the first call to Next.
+func (r *SegmentReader) SetReadBuffer(size int, readAhead bool) {
+	if r.readBuffer != nil {
+		r.readBuffer.discard()
+	}
+	if size <= 0 || r.mapping != nil {
+		r.reader = r.file
+		r.readBuffer = nil
+		return
+	}
+	r.readBuffer = newReadBuffer(r.file, size, readAhead)
+	r.reader = r.readBuffer
+}
+
+// SetEntryChecksumKey sets the key for segment files with the entry checksum type
+// encoding.EntryChecksumTypeHmacSha256Chain. The key is ignored for all other entry checksum types. This needs to be
+// called before the first call to Next.
+func (r *SegmentReader) SetEntryChecksumKey(key []byte) {
+	r.entryChecksumKey = key
+	if r.entryChain != nil {
+		r.entryChain.SetKey(key)
+	}
+}
+
+// Digest returns the digest of the last entry read for the chained entry checksum types. Before reading the first
+// entry, this is the digest stored in the header metadata. Returns nil for all other entry checksum types.
+func (r *SegmentReader) Digest() []byte {
+	if r.entryChain == nil {
+		return nil
+	}
+	return r.entryChain.Digest()
+}
+
 // FilePath returns the file path of the file this reader is reading from.
 func (r *SegmentReader) FilePath() string {
 	return r.file.Name()
@@ -180,20 +431,156 @@ func (r *SegmentReader) NextSequenceNumber() uint64 {
 	return r.nextSequenceNumber
 }
 
+// Index returns the records of the index file which belongs to the segment file. For a missing or invalid index file,
+// the records are collected from the segment file without writing the index file. Only the writer of a segment file
+// builds its index file again, as it is the only one appending to it. Returns no records when the segment reader was
+// created without an index file.
+func (r *SegmentReader) Index() ([]IndexRecord, error) {
+	if r.segmentFilePath == "" {
+		return nil, nil
+	}
+	records, err := ReadIndex(IndexFilePath(r.segmentFilePath), r.header.Size())
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrIndexInvalid) {
+		return collectIndex(r.segmentFilePath, r.header.FirstSequenceNumber, r.entryChecksumKey)
+	}
+	return records, err
+}
+
+// SeekIndex moves the reader forward to the closest entry recorded in the index file which has a sequence number of at
+// most the given sequence number. Call Next afterward to read the remaining entries up to the sequence number. The
+// reader does not move when there is no valid index file or no entry closer than the current position. The index file
+// is never built by a reader.
+func (r *SegmentReader) SeekIndex(sequenceNumber uint64) error {
+	if r.segmentFilePath == "" || r.batchReader.Len() > 0 || sequenceNumber <= r.nextSequenceNumber {
+		return nil
+	}
+
+	records, err := ReadIndex(IndexFilePath(r.segmentFilePath), r.header.Size())
+	if errors.Is(err, os.ErrNotExist) || errors.Is(err, ErrIndexInvalid) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// Find the last record with a sequence number of at most the desired sequence number.
+	index, _ := slices.BinarySearchFunc(records, sequenceNumber+1, func(record IndexRecord, sequenceNumber uint64) int {
+		return cmp.Compare(record.SequenceNumber, sequenceNumber)
+	})
+	if index == 0 {
+		return nil
+	}
+	record := records[index-1]
+	if record.SequenceNumber <= r.nextSequenceNumber || record.Offset <= r.offset || record.Offset >= r.fileSize {
+		// The record does not bring us any closer, or it points beyond the end of the file.
+		return nil
+	}
+	return r.seekVerified(record)
+}
+
+// seekVerified moves the reader to the entry described by the record, when a valid entry starts there. Otherwise, the
+// reader stays where it is. The index file is not flushed together with the segment file. After a crash, a record
+// might point to an entry which never made it to stable storage, like into the zeros of a pre-allocated segment file.
+func (r *SegmentReader) seekVerified(record IndexRecord) error {
+	current := IndexRecord{
+		SequenceNumber: r.nextSequenceNumber,
+		Offset:         r.offset,
+	}
+	if err := r.Seek(record); err != nil {
+		return err
+	}
+	if err := r.next(); err != nil {
+		return r.Seek(current)
+	}
+	return r.Seek(record)
+}
+
+// Seek moves the reader to the entry described by the record. The record needs to describe the start of an entry,
+// like the records returned by Index or the position of the reader before reading an entry. In contrast to SeekIndex,
+// this allows moving backward as well.
+func (r *SegmentReader) Seek(record IndexRecord) error {
+	if record.Offset < r.header.Size() || record.Offset > r.fileSize {
+		return fmt.Errorf("the offset %d is outside of the WAL segment file", record.Offset)
+	}
+	if err := r.seekDigest(record.Offset); err != nil {
+		return err
+	}
+	if err := r.seekFile(record.Offset); err != nil {
+		return err
+	}
+	r.offset = record.Offset
+	r.nextSequenceNumber = record.SequenceNumber
+	r.batch = nil
+	r.batchReader.Reset(nil)
+	r.err = nil
+	return nil
+}
+
+// seekDigest moves the entry chain to the digest of the entry in front of the given offset. The digest is the last part
+// of that entry. The entry chain continues from the previous digest in the header metadata at the first entry.
+func (r *SegmentReader) seekDigest(offset int64) error {
+	if r.entryChain == nil {
+		return nil
+	}
+	if offset == r.header.Size() {
+		r.entryChain.SetDigest(r.header.Metadata.PreviousDigest)
+		return nil
+	}
+	if offset-encoding.EntryChainDigestSize < r.header.Size() {
+		return fmt.Errorf("the offset %d does not follow an entry of the WAL segment file", offset)
+	}
+	if r.mapping != nil {
+		r.entryChain.SetDigest(r.mapping[offset-encoding.EntryChainDigestSize : offset])
+		return nil
+	}
+	if err := r.seekFile(offset - encoding.EntryChainDigestSize); err != nil {
+		return err
+	}
+	digest := r.scratchBuffer[:encoding.EntryChainDigestSize]
+	if _, err := io.ReadFull(r.file, digest); err != nil {
+		return fmt.Errorf("reading the digest of the previous WAL entry: %w", err)
+	}
+	r.entryChain.SetDigest(digest)
+	return nil
+}
+
 // Next reports if an entry has been successfully read. When it returns true, Err() returns nil and Value() contains
 // valid data. When it returns false, Err() contains the error and Value() contains invalid data.
 func (r *SegmentReader) Next() bool {
 	if r.err = r.next(); r.err != nil {
-		r.err = errors.Join(ErrEntryNone, r.err)
-
 		// In case of an error when reading the next entry, we move the file position back to where we were before.
 		// Otherwise, we could not reliably continue writing to a segment file which has not yet reached the desired
-		// maximum size.
-		if _, err := r.file.Seek(r.offset, io.SeekStart); err != nil {
-			r.err = errors.Join(r.err, err)
+		// maximum size. Bytes already buffered beyond the offset are dropped as well, as they might have changed until
+		// the next read.
+		entryErr := &EntryError{
+			Path:           r.file.Name(),
+			Offset:         r.offset,
+			SequenceNumber: r.nextSequenceNumber,
+			Err:            r.err,
 		}
+		if err := r.seekFile(r.offset); err != nil {
+			entryErr.Err = errors.Join(r.err, err)
+			r.err = entryErr
+			return false
+		}
+		if errors.Is(r.err, encoding.ErrEntryChecksumKeyRequired) {
+			// The entry could not be verified at all, so we must not treat it as torn.
+			r.err = entryErr
+			return false
+		}
+		switch kind := r.classifyEntryError(); kind {
+		case ErrEntryNotWritten, ErrEntryTorn:
+			entryErr.Kind = kind
+		default:
+			entryErr.Err = errors.Join(r.err, kind)
+		}
+		r.err = entryErr
 		return false
 	}
+	if r.entryChain != nil {
+		// Entries of a batch do not have a digest of their own. Committing again does not change the chain.
+		r.entryChain.Commit()
+	}
 
 	ReadEntryTotal.Inc()
 	ReadEntryBytes.Add(float64(len(r.value.Data)))
@@ -201,10 +588,18 @@ func (r *SegmentReader) Next() bool {
 }
 
 func (r *SegmentReader) next() error {
+	if r.batchReader.Len() > 0 {
+		// We are still yielding entries from a batch which was already read and validated.
+		return r.nextFromBatch()
+	}
+	if r.mapping != nil {
+		return r.nextFromMapping()
+	}
+
 	// Read the length of the entry.
 	// We use the data slice as scratch space for converting bytes to integers. We assume that the data slice can always
 	// hold at least the maximum length encoding. This is true for a pre-allocated data slice.
-	length, lengthBytes, err := r.entryLengthReader(r.file, r.data[:encoding.MaxLengthBufferLen])
+	length, lengthBytes, err := r.entryLengthReader(r.reader, r.data[:encoding.MaxLengthBufferLen])
 	if err != nil {
 		return err
 	}
@@ -214,10 +609,10 @@ func (r *SegmentReader) next() error {
 		return errors.New("the WAL entry data exceeds the maximum possible size")
 	}
 
-	// Read the data part of the entry.
+	// Read the flags and the data part of the entry.
 	// As we are using the data slice as scratch space as well, we need to make sure that we not only can hold the data
-	// itself, but length and checksum as well.
-	requiredDataSize := encoding.MaxLengthBufferLen + length + encoding.MaxChecksumBufferLen
+	// itself, but length, flags and checksum as well.
+	requiredDataSize := encoding.MaxLengthBufferLen + uint64(r.entryFlagsSize) + length + encoding.MaxChecksumBufferLen //nolint:gosec // entryFlagsSize cannot be negative
 	if uint64(len(r.data)) < requiredDataSize {
 		// We increase the data slice by a factor of 1.5 to amortise memory allocations over multiple calls. A naive
 		// implementation would do a "requiredDataSize * 3 / 2" to get the desired new size. But that approach runs
@@ -233,19 +628,216 @@ func (r *SegmentReader) next() error {
 		copy(newData, r.data[:lengthBytes])
 		r.data = newData
 	}
-	if _, err := io.ReadFull(r.file, r.data[lengthBytes:uint64(lengthBytes)+length]); err != nil { //nolint:gosec // lengthBytes cannot be negative
+	dataStart := uint64(lengthBytes) + uint64(r.entryFlagsSize) //nolint:gosec // lengthBytes and entryFlagsSize cannot be negative
+	if _, err := io.ReadFull(r.reader, r.data[lengthBytes:dataStart+length]); err != nil {
 		return fmt.Errorf("reading WAL entry data: %w", err)
 	}
 
 	// Read the checksum and validate against the data we read so far.
-	checksumBytes, err := r.entryChecksumReader(r.file, r.data[uint64(lengthBytes)+length:], r.data[:uint64(lengthBytes)+length]) //nolint:gosec // lengthBytes cannot be negative
+	checksumBytes, err := r.entryChecksumReader(r.reader, r.data[dataStart+length:], r.data[:dataStart+length])
 	if err != nil {
 		return err
 	}
-	r.value.Data = r.data[lengthBytes : uint64(lengthBytes)+length] //nolint:gosec // lengthBytes cannot be negative
+
+	var flags encoding.EntryFlags
+	if r.entryFlagsSize > 0 {
+		flags = encoding.EntryFlags(r.data[lengthBytes])
+	}
+	return r.entryRead(flags, r.data[dataStart:dataStart+length], int64(dataStart)+int64(length)+int64(checksumBytes)) //nolint:gosec // chances are low that length will overflow
+}
+
+// nextFromMapping decodes the next entry from the mapping. In contrast to next, the data of the entry is not copied
+// and points into the mapping.
+func (r *SegmentReader) nextFromMapping() error {
+	if r.offset >= int64(len(r.mapping)) {
+		return io.EOF
+	}
+	r.mappingReader.Reset(r.mapping[r.offset:])
+	length, lengthBytes, err := r.entryLengthReader(&r.mappingReader, r.scratchBuffer[:])
+	if err != nil {
+		return err
+	}
+
+	dataStart := uint64(lengthBytes) + uint64(r.entryFlagsSize)          //nolint:gosec // lengthBytes and entryFlagsSize cannot be negative
+	if uint64(r.mappingReader.Len()) < uint64(r.entryFlagsSize)+length { //nolint:gosec // entryFlagsSize cannot be negative
+		return fmt.Errorf("reading WAL entry data: %w", io.ErrUnexpectedEOF)
+	}
+	entry := r.mapping[r.offset:][:dataStart+length]
+
+	// Validate the checksum against the entry.
+	r.mappingReader.Reset(r.mapping[r.offset+int64(len(entry)):])
+	checksumBytes, err := r.entryChecksumReader(&r.mappingReader, r.scratchBuffer[:], entry)
+	if err != nil {
+		return err
+	}
+
+	var flags encoding.EntryFlags
+	if r.entryFlagsSize > 0 {
+		flags = encoding.EntryFlags(entry[lengthBytes])
+	}
+	return r.entryRead(flags, entry[dataStart:], int64(len(entry))+int64(checksumBytes))
+}
+
+// entryRead yields the entry which was read and validated. The entry size is the number of bytes the entry occupies
+// in the segment file.
+func (r *SegmentReader) entryRead(flags encoding.EntryFlags, data []byte, entrySize int64) error {
+	if err := flags.Validate(); err != nil {
+		return err
+	}
+
+	if flags&encoding.EntryFlagCompressed != 0 {
+		decompressed, err := r.decompress(data)
+		if err != nil {
+			return err
+		}
+		data = decompressed
+	}
+
+	if flags&encoding.EntryFlagBatch != 0 {
+		// Make sure that the whole batch is well-formed before we yield the first entry of it. Otherwise, we could end
+		// up yielding only some entries of the batch.
+		if err := r.validateBatch(data); err != nil {
+			return err
+		}
+		r.batch = data
+		r.batchReader.Reset(data)
+		r.offset += entrySize
+		return r.nextFromBatch()
+	}
+
+	r.value.Type = encoding.EntryTypeData
+	r.value.Flags = 0
+	r.value.Headers = nil
+	if flags&encoding.EntryFlagTyped != 0 {
+		entryType, entryFlags, headers, entryData, err := encoding.ReadEntryEnvelope(data, r.headers[:0])
+		if err != nil {
+			return err
+		}
+		r.headers = headers
+		r.value.Type = entryType
+		r.value.Flags = entryFlags
+		if len(headers) > 0 {
+			r.value.Headers = headers
+		}
+		data = entryData
+	}
+	r.value.Data = data
 	r.value.SequenceNumber = r.nextSequenceNumber
 
-	r.offset += int64(lengthBytes) + int64(length) + int64(checksumBytes) //nolint:gosec // chances are low that length will overflow
+	r.offset += entrySize
+	r.nextSequenceNumber++
+	return nil
+}
+
+// decompress returns the decompressed data of an entry. The returned data is only valid until the next compressed entry
+// is decompressed. For segment files mapped into memory, every entry is decompressed into a new buffer instead, so
+// that the data stays valid until the segment reader is closed, like the data pointing into the mapping.
+func (r *SegmentReader) decompress(data []byte) ([]byte, error) {
+	if r.header.Metadata.Compression == encoding.CompressionNone {
+		return nil, fmt.Errorf("the WAL segment file has no compression: %w", encoding.ErrCompressionUnsupported)
+	}
+	if r.decompressor == nil {
+		r.decompressor = &encoding.Decompressor{}
+	}
+	if r.mapping != nil {
+		return r.decompressor.AppendDecompressed(nil, data)
+	}
+	decompressed, err := r.decompressor.AppendDecompressed(r.decompressed[:0], data)
+	if err != nil {
+		return nil, err
+	}
+	r.decompressed = decompressed
+	return decompressed, nil
+}
+
+// classifyEntryError returns ErrEntryNotWritten when there is no data at the current offset. This is the case at the
+// end of the segment file or at the zeroed out space of a pre-allocated segment file. Otherwise, ErrEntryTorn is
+// returned, as there is data which is not a valid entry. The file position needs to be at the current offset and is
+// moved back there afterward. Any other error returned means that the kind of failure could not be determined.
+func (r *SegmentReader) classifyEntryError() error {
+	var probe [entryProbeSize]byte
+	n, err := io.ReadFull(r.file, probe[:])
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	if _, err := r.file.Seek(r.offset, io.SeekStart); err != nil {
+		return err
+	}
+
+	for _, b := range probe[:n] {
+		if b != 0 {
+			return ErrEntryTorn
+		}
+	}
+	return ErrEntryNotWritten
+}
+
+// UpdateFileSize reads the size of the segment file again. This is needed when reading from a segment file which is
+// still written to, as the size of the file might have grown since the segment file was opened.
+func (r *SegmentReader) UpdateFileSize() error {
+	if r.readBuffer != nil {
+		r.readBuffer.discard()
+	}
+	fileSize, err := r.file.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+	if err := r.seekFile(r.offset); err != nil {
+		return err
+	}
+	r.fileSize = fileSize
+	return nil
+}
+
+// seekFile moves the file position to the given offset. Buffered bytes are dropped, as they belong to the former file
+// position.
+func (r *SegmentReader) seekFile(offset int64) error {
+	if r.readBuffer != nil {
+		r.readBuffer.discard()
+	}
+	_, err := r.file.Seek(offset, io.SeekStart)
+	return err
+}
+
+// validateBatch checks that the data of a batch entry consists of at least one entry and that all entries are complete.
+func (r *SegmentReader) validateBatch(data []byte) error {
+	r.batchReader.Reset(data)
+	entryCount := 0
+	for r.batchReader.Len() > 0 {
+		length, _, err := r.entryLengthReader(&r.batchReader, r.scratchBuffer[:])
+		if err != nil {
+			return fmt.Errorf("reading WAL batch entry: %w", err)
+		}
+		if uint64(r.batchReader.Len()) < length {
+			return errors.New("the WAL batch entry data exceeds the batch size")
+		}
+		if _, err := r.batchReader.Seek(int64(length), io.SeekCurrent); err != nil { //nolint:gosec // length is bound by the batch size
+			return fmt.Errorf("reading WAL batch entry: %w", err)
+		}
+		entryCount++
+	}
+	if entryCount == 0 {
+		return errors.New("the WAL batch does not contain any entries")
+	}
+	return nil
+}
+
+// nextFromBatch yields the next entry from the current batch. The batch must have been validated before.
+func (r *SegmentReader) nextFromBatch() error {
+	length, _, err := r.entryLengthReader(&r.batchReader, r.scratchBuffer[:])
+	if err != nil {
+		return fmt.Errorf("reading WAL batch entry: %w", err)
+	}
+	dataStart := len(r.batch) - r.batchReader.Len()
+	if _, err := r.batchReader.Seek(int64(length), io.SeekCurrent); err != nil { //nolint:gosec // length is bound by the batch size
+		return fmt.Errorf("reading WAL batch entry: %w", err)
+	}
+
+	r.value.Data = r.batch[dataStart : dataStart+int(length)] //nolint:gosec // length is bound by the batch size
+	r.value.SequenceNumber = r.nextSequenceNumber
+	r.value.Type = encoding.EntryTypeData
+	r.value.Flags = 0
+	r.value.Headers = nil
 	r.nextSequenceNumber++
 	return nil
 }
@@ -256,23 +848,178 @@ func (r *SegmentReader) Value() SegmentReaderValue {
 	return r.value
 }
 
-// Err returns the error for the last call to Next().
+// Err returns the error for the last call to Next(). The error is an *EntryError which describes the position of the
+// entry which could not be read.
 // Returns ErrEntryNone when no entry could be read. This indicates either a corrupt entry or the end of the written
 // entries in the pre-allocated segment file.
 // Returns io.EOF when the end of the segment file was reached and no more data could be read. This error is still
 // wrapped in ErrEntryNone but can be checked for separately.
+// Returns ErrEntryNotWritten wrapped in ErrEntryNone when there is no more data. This is the case at the end of the
+// segment file or the pre-allocated space. Returns ErrEntryTorn wrapped in ErrEntryNone when there is data which is
+// not a valid entry. This is the case for entries which were only partially written or are corrupt.
 func (r *SegmentReader) Err() error {
 	return r.err
 }
 
+// SkipCorrupt moves the reader past the entry the last call to Next failed on, to the next entry recorded in the index
+// file. All entries in between are skipped, as there is no reliable way to find the start of the next entry within
+// corrupt data. It returns false when the index file does not record any entry after the failed one.
+func (r *SegmentReader) SkipCorrupt() (bool, error) {
+	records, err := r.Index()
+	if err != nil {
+		return false, err
+	}
+	index := slices.IndexFunc(records, func(record IndexRecord) bool {
+		return record.Offset > r.offset && record.SequenceNumber > r.nextSequenceNumber
+	})
+	if index == -1 || records[index].Offset >= r.fileSize {
+		return false, nil
+	}
+	return true, r.Seek(records[index])
+}
+
+// TruncateTail removes the torn entry the last call to Next failed on, together with all data after it. The segment
+// file keeps its size and the removed data is replaced with zeros, so that it looks the same as pre-allocated space.
+// Records of the index file which point into the removed data are removed as well.
+// Returns ErrReadOnly when the segment file was opened read-only. Returns ErrEntryCorrupt without touching the segment
+// file when a valid entry follows the torn entry, as the entry is not at the tail of the segment file then.
+func (r *SegmentReader) TruncateTail() error {
+	if r.readOnly {
+		return ErrReadOnly
+	}
+	if !errors.Is(r.err, ErrEntryTorn) {
+		return errors.New("the segment reader needs to stop at a torn entry before truncating")
+	}
+
+	file, ok := r.file.(SegmentWriterFile)
+	if !ok {
+		return errors.New("the segment file does not implement the interface for writing to it")
+	}
+
+	failed := IndexRecord{
+		SequenceNumber: r.nextSequenceNumber,
+		Offset:         r.offset,
+	}
+	failedErr := r.err
+	found, err := r.entryAfter(failed.Offset)
+	if err := errors.Join(err, r.Seek(failed)); err != nil {
+		r.err = failedErr
+		return err
+	}
+	r.err = failedErr
+	if found {
+		return fmt.Errorf("a valid entry follows the entry at offset %d: %w", failed.Offset, ErrEntryCorrupt)
+	}
+
+	// We truncate to the current offset first to get rid of all data after it. Extending the file afterward fills the
+	// remaining file with zeros.
+	if err := file.Truncate(r.offset); err != nil {
+		return fmt.Errorf("truncating file: %w", err)
+	}
+	if err := file.Truncate(r.fileSize); err != nil {
+		return fmt.Errorf("extending file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("flushing file: %w", err)
+	}
+	if r.segmentFilePath != "" {
+		if err := pruneIndex(IndexFilePath(r.segmentFilePath), r.header.Size(), r.offset); err != nil {
+			return err
+		}
+	}
+	if err := r.seekFile(r.offset); err != nil {
+		return err
+	}
+	r.err = nil
+	return nil
+}
+
+// entryAfter reports if a valid entry starts anywhere after the given offset. Entries recorded in the index file are
+// checked first. Otherwise, every offset close to data which is not zero is tried, as there is no other way to find the
+// start of an entry after damaged data. An entry always contains data which is not zero within its first
+// entryProbeSize bytes. The reader is moved to arbitrary positions and needs to be moved back afterward.
+// The writer records the first entry which starts at least IndexInterval bytes after the last recorded one. A valid
+// entry which is not recorded therefore starts within IndexInterval bytes after the given offset, so we only try the
+// offsets within that window. We also only accept entries which end within twice that distance. Otherwise, damaged data
+// which decodes to a big length would make us read up to the end of the segment file for every offset we try.
+func (r *SegmentReader) entryAfter(offset int64) (bool, error) {
+	// Most candidates are not the start of an entry. Filling the read buffer for each of them would be a waste.
+	reader := r.reader
+	r.reader = r.file
+	defer func() {
+		r.reader = reader
+	}()
+
+	if r.segmentFilePath != "" {
+		records, err := ReadIndex(IndexFilePath(r.segmentFilePath), r.header.Size())
+		if err != nil && !errors.Is(err, os.ErrNotExist) && !errors.Is(err, ErrIndexInvalid) {
+			return false, err
+		}
+		for _, record := range records {
+			if record.Offset > offset && r.entryAt(record.Offset) {
+				return true, nil
+			}
+		}
+	}
+
+	// Limiting the file size makes the reader reject every entry which does not end before the limit, before any data
+	// of the entry is read.
+	fileSize := r.fileSize
+	r.fileSize = min(fileSize, offset+2*IndexInterval)
+	defer func() {
+		r.fileSize = fileSize
+	}()
+
+	windowEnd := min(fileSize, offset+1+IndexInterval)
+	chunk := make([]byte, 64*1024)
+	tried := offset
+	for chunkOffset := offset + 1; chunkOffset < windowEnd; chunkOffset += int64(len(chunk)) {
+		if err := r.seekFile(chunkOffset); err != nil {
+			return false, err
+		}
+		n, err := io.ReadFull(r.file, chunk[:min(int64(len(chunk)), windowEnd-chunkOffset)])
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+			return false, err
+		}
+		for i, b := range chunk[:n] {
+			if b == 0 {
+				continue
+			}
+			position := chunkOffset + int64(i)
+			for candidate := max(tried+1, position-entryProbeSize+1); candidate <= position; candidate++ {
+				if r.entryAt(candidate) {
+					return true, nil
+				}
+			}
+			tried = position
+		}
+	}
+	return false, nil
+}
+
+// entryAt reports if a valid entry starts at the given offset. The reader is moved to the end of that entry.
+func (r *SegmentReader) entryAt(offset int64) bool {
+	if err := r.Seek(IndexRecord{Offset: offset}); err != nil {
+		return false
+	}
+	return r.next() == nil
+}
+
 // ToWriter returns a SegmentWriter to append to the open segment file. You must have read all entries of the segment
-// before you call this method. Otherwise, it will fail. After a call to ToWriter(), you cannot use the SegmentReader
-// anymore.
+// before you call this method. Otherwise, it will fail. Returns ErrReadOnly when the segment file was opened
+// read-only. After a call to ToWriter(), you cannot use the SegmentReader anymore.
 func (r *SegmentReader) ToWriter() (*SegmentWriter, error) {
+	if r.readOnly {
+		return nil, ErrReadOnly
+	}
 	if !errors.Is(r.err, ErrEntryNone) {
 		return nil, errors.New("segment needs to be read until the last entry is reached")
 	}
 
+	if r.readBuffer != nil {
+		r.readBuffer.discard()
+	}
+
 	writerFile, ok := r.file.(SegmentWriterFile)
 	if !ok {
 		return nil, errors.New("the segment file does not implement the interface for writing to it")
@@ -282,6 +1029,9 @@ func (r *SegmentReader) ToWriter() (*SegmentWriter, error) {
 		Header:             r.header,
 		Offset:             r.offset,
 		NextSequenceNumber: r.nextSequenceNumber,
+		SegmentFilePath:    r.segmentFilePath,
+		EntryChecksumKey:   r.entryChecksumKey,
+		PreviousDigest:     r.Digest(),
 	})
 	if err != nil {
 		return nil, err
@@ -292,8 +1042,18 @@ func (r *SegmentReader) ToWriter() (*SegmentWriter, error) {
 	return segmentWriter, nil
 }
 
-// Close closes the file the SegmentReader is reading from.
+// Close closes the file the SegmentReader is reading from. A mapping of the file is released as well.
 func (r *SegmentReader) Close() error {
+	if r.readBuffer != nil {
+		// Wait for a read in progress, so that it does not read from the closed file.
+		r.readBuffer.discard()
+	}
+	if r.mapping != nil {
+		if err := unmapFile(r.mapping); err != nil {
+			return errors.Join(err, r.file.Close())
+		}
+		r.mapping = nil
+	}
 	if err := r.file.Close(); err != nil {
 		return err
 	}
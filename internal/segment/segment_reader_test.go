@@ -1,11 +1,16 @@
 package segment_test
 
 import (
+	"bytes"
+	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"math"
 	"os"
+	"path"
 	"testing"
+	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
@@ -50,6 +55,46 @@ var _ = Describe("SegmentReader", func() {
 					Expect(reader.Err()).To(MatchError(io.EOF))
 				})
 
+				It("should distinguish entries not yet written from torn entries", func() {
+					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+						PreAllocationSize:   segment.DefaultPreAllocationSize,
+						EntryLengthEncoding: entryLengthEncoding,
+						EntryChecksumType:   entryChecksumType,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+					Expect(writer.AppendEntry(nil)).Error().ToNot(HaveOccurred())
+					offset := writer.Offset()
+					Expect(writer.AppendEntry([]byte("bar"))).Error().ToNot(HaveOccurred())
+					Expect(writer.Close()).To(Succeed())
+
+					By("reading into the pre-allocated space")
+					reader, err := segment.OpenSegment(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					for range 3 {
+						Expect(reader.Next()).To(BeTrue())
+					}
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+					Expect(reader.Err()).ToNot(MatchError(segment.ErrEntryTorn))
+					Expect(reader.Close()).To(Succeed())
+
+					By("reading a partially written entry")
+					Expect(os.Truncate(path.Join(dir, segment.SegmentFileName(0)), offset+2)).To(Succeed())
+					Expect(os.Truncate(path.Join(dir, segment.SegmentFileName(0)), segment.DefaultPreAllocationSize)).To(Succeed())
+					reader, err = segment.OpenSegment(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					for range 2 {
+						Expect(reader.Next()).To(BeTrue())
+					}
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+					Expect(reader.Err()).ToNot(MatchError(segment.ErrEntryNotWritten))
+					Expect(reader.Close()).To(Succeed())
+				})
+
 				It("should read a full segment file", func() {
 					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
 						PreAllocationSize:   0,
@@ -93,6 +138,197 @@ var _ = Describe("SegmentReader", func() {
 					Expect(reader.Err()).To(MatchError(io.EOF))
 				})
 
+				It("should read batches", func() {
+					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+						PreAllocationSize:   0,
+						EntryLengthEncoding: entryLengthEncoding,
+						EntryChecksumType:   entryChecksumType,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+					Expect(writer.AppendEntries([][]byte{[]byte("bar"), {}, []byte("baz")})).To(Equal(uint64(1)))
+					Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(4)))
+					Expect(writer.Close()).To(Succeed())
+
+					reader, err := segment.OpenSegment(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					defer func() {
+						Expect(reader.Close()).To(Succeed())
+					}()
+
+					for i, data := range [][]byte{[]byte("foo"), []byte("bar"), {}, []byte("baz"), []byte("qux")} {
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+						Expect(reader.Value().Data).To(Equal(data))
+					}
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(io.EOF))
+				})
+
+				for _, mapped := range []bool{false, true} {
+					It(fmt.Sprintf("should read typed entries with mapping %t", mapped), func() {
+						writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+							PreAllocationSize:   0,
+							EntryLengthEncoding: entryLengthEncoding,
+							EntryChecksumType:   entryChecksumType,
+						})
+						Expect(err).ToNot(HaveOccurred())
+						Expect(writer.AppendTypedEntry(segment.TypedEntry{
+							Type:  7,
+							Flags: 0x3,
+							Headers: []encoding.EntryHeader{
+								{Key: "content-type", Value: []byte("application/json")},
+								{Key: "empty"},
+							},
+							Data: []byte("foo"),
+						})).To(Equal(uint64(0)))
+						Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).To(Equal(uint64(1)))
+						Expect(writer.AppendTypedEntry(segment.TypedEntry{
+							Type: encoding.EntryTypeControl,
+						})).To(Equal(uint64(3)))
+						Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(4)))
+						Expect(writer.Close()).To(Succeed())
+
+						var reader *segment.SegmentReader
+						if mapped {
+							reader, err = segment.OpenSegmentMapped(dir, 0)
+						} else {
+							reader, err = segment.OpenSegment(dir, 0)
+						}
+						Expect(err).ToNot(HaveOccurred())
+						defer func() {
+							Expect(reader.Close()).To(Succeed())
+						}()
+
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().Type).To(Equal(encoding.EntryType(7)))
+						Expect(reader.Value().Type.IsControl()).To(BeFalse())
+						Expect(reader.Value().Flags).To(Equal(uint8(0x3)))
+						Expect(reader.Value().Headers).To(Equal([]encoding.EntryHeader{
+							{Key: "content-type", Value: []byte("application/json")},
+							{Key: "empty", Value: []byte{}},
+						}))
+						Expect(reader.Value().Data).To(Equal([]byte("foo")))
+						for _, data := range [][]byte{[]byte("bar"), []byte("baz")} {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().Type).To(Equal(encoding.EntryTypeData))
+							Expect(reader.Value().Flags).To(BeZero())
+							Expect(reader.Value().Headers).To(BeNil())
+							Expect(reader.Value().Data).To(Equal(data))
+						}
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().SequenceNumber).To(Equal(uint64(3)))
+						Expect(reader.Value().Type.IsControl()).To(BeTrue())
+						Expect(reader.Value().Headers).To(BeNil())
+						Expect(reader.Value().Data).To(BeEmpty())
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().Type).To(Equal(encoding.EntryTypeData))
+						Expect(reader.Value().Data).To(Equal([]byte("qux")))
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Err()).To(MatchError(io.EOF))
+					})
+				}
+
+				It("should read entries from a memory-mapped segment file", func() {
+					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+						PreAllocationSize:   0,
+						EntryLengthEncoding: entryLengthEncoding,
+						EntryChecksumType:   entryChecksumType,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+					Expect(writer.AppendEntries([][]byte{[]byte("bar"), {}, []byte("baz")})).To(Equal(uint64(1)))
+					Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(4)))
+					Expect(writer.Close()).To(Succeed())
+
+					reader, err := segment.OpenSegmentMapped(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					for i, data := range [][]byte{[]byte("foo"), []byte("bar"), {}, []byte("baz"), []byte("qux")} {
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+						Expect(reader.Value().Data).To(Equal(data))
+					}
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(io.EOF))
+					Expect(reader.ToWriter()).Error().To(MatchError(segment.ErrReadOnly))
+					Expect(reader.Close()).To(Succeed())
+				})
+
+				for _, readAhead := range []bool{false, true} {
+					It(fmt.Sprintf("should read entries through a read buffer with read-ahead %t and continue writing afterward", readAhead), func() {
+						writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+							PreAllocationSize:   segment.DefaultPreAllocationSize,
+							EntryLengthEncoding: entryLengthEncoding,
+							EntryChecksumType:   entryChecksumType,
+						})
+						Expect(err).ToNot(HaveOccurred())
+						entries := [][]byte{[]byte("foo"), make([]byte, 100), []byte("bar")}
+						for _, entry := range entries {
+							Expect(writer.AppendEntry(entry)).Error().ToNot(HaveOccurred())
+						}
+						Expect(writer.Close()).To(Succeed())
+
+						// A buffer smaller than an entry makes entries span multiple chunks.
+						reader, err := segment.OpenSegment(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						reader.SetReadBuffer(7, readAhead)
+						for i, entry := range entries {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+							Expect(reader.Value().Data).To(Equal(entry))
+						}
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+
+						// The buffered bytes beyond the last entry must not move the position the writer appends at.
+						writer, err = reader.ToWriter()
+						Expect(err).ToNot(HaveOccurred())
+						Expect(writer.AppendEntry([]byte("baz"))).To(Equal(uint64(3)))
+						Expect(writer.Close()).To(Succeed())
+
+						reader, err = segment.OpenSegment(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						reader.SetReadBuffer(segment.DefaultReadBufferSize, readAhead)
+						for _, entry := range append(entries, []byte("baz")) {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().Data).To(Equal(entry))
+						}
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+						Expect(reader.Close()).To(Succeed())
+					})
+				}
+
+				It("should read none of the entries of a partially written batch", func() {
+					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+						PreAllocationSize:   0,
+						EntryLengthEncoding: entryLengthEncoding,
+						EntryChecksumType:   entryChecksumType,
+					})
+					Expect(err).ToNot(HaveOccurred())
+					Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+					Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).Error().ToNot(HaveOccurred())
+					Expect(writer.Close()).To(Succeed())
+
+					// Simulate a crash in the middle of writing the batch.
+					filePath := path.Join(dir, segment.SegmentFileName(0))
+					fileInfo, err := os.Stat(filePath)
+					Expect(err).ToNot(HaveOccurred())
+					Expect(os.Truncate(filePath, fileInfo.Size()-1)).To(Succeed())
+
+					reader, err := segment.OpenSegment(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					defer func() {
+						Expect(reader.Close()).To(Succeed())
+					}()
+
+					Expect(reader.Next()).To(BeTrue())
+					Expect(reader.Value().Data).To(Equal([]byte("foo")))
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+					Expect(reader.NextSequenceNumber()).To(Equal(uint64(1)))
+				})
+
 				It("should read a pre-allocated segment file", func() {
 					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
 						PreAllocationSize:   segment.DefaultPreAllocationSize,
@@ -148,6 +384,54 @@ var _ = Describe("SegmentReader", func() {
 		Expect(reader.Next()).To(BeTrue())
 	})
 
+	It("should read segment files of version 1", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion1
+
+		var recorder utils.SegmentWriterFileRecorder
+		writer, err := segment.NewSegmentWriter(&recorder, segment.NewSegmentWriterConfig{
+			Header: header,
+			Offset: encoding.HeaderSize,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("bar"))).Error().ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		reader, err := segment.NewSegmentReader(&utils.SegmentReaderFileLoop{
+			Data: recorder.Bytes(),
+		}, segment.NewSegmentReaderConfig{
+			Header:   header,
+			Offset:   encoding.HeaderSize,
+			FileSize: math.MaxInt64,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(reader.Close()).To(Succeed())
+		}()
+
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Value().Data).To(Equal([]byte("foo")))
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Value().Data).To(Equal([]byte("bar")))
+		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 2*(4+3+4))))
+	})
+
+	It("should not write typed entries to segment files of version 1", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion1
+
+		var recorder utils.SegmentWriterFileRecorder
+		writer, err := segment.NewSegmentWriter(&recorder, segment.NewSegmentWriterConfig{
+			Header: header,
+			Offset: encoding.HeaderSize,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: 1})).Error().To(MatchError(segment.ErrTypedUnsupported))
+		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize)))
+		Expect(writer.Close()).To(Succeed())
+	})
+
 	It("should correctly report offsets", func() {
 		var recorder utils.SegmentWriterFileRecorder
 		writer, err := segment.NewSegmentWriter(&recorder, segment.NewSegmentWriterConfig{
@@ -174,12 +458,368 @@ var _ = Describe("SegmentReader", func() {
 
 		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize)))
 		Expect(reader.Next()).To(BeTrue())
-		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 1*(4+3+4))))
+		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 1*(4+1+3+4))))
 		Expect(reader.Next()).To(BeTrue())
-		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 2*(4+3+4))))
+		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 2*(4+1+3+4))))
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 3*(4+1+3+4))))
+	})
+
+	It("should report the position of torn entries and truncate them", func() {
+		dir, err := os.MkdirTemp("", "test-segment-reader-*")
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		}()
+
+		writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+			PreAllocationSize:   segment.DefaultPreAllocationSize,
+			EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+			EntryChecksumType:   encoding.DefaultEntryChecksumType,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+		tornOffset := writer.Offset()
+		Expect(writer.AppendEntry([]byte("bar"))).Error().ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		// Flip a bit of the data of the second entry, so that its checksum does not match anymore.
+		filePath := path.Join(dir, segment.SegmentFileName(0))
+		file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.WriteAt([]byte("baz"), tornOffset+4+1)).Error().ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		reader, err := segment.OpenSegment(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+		Expect(reader.Err()).To(MatchError(encoding.ErrEntryChecksumMismatch))
+		var entryErr *segment.EntryError
+		Expect(errors.As(reader.Err(), &entryErr)).To(BeTrue())
+		Expect(entryErr.Path).To(Equal(filePath))
+		Expect(entryErr.Offset).To(Equal(tornOffset))
+		Expect(entryErr.SequenceNumber).To(Equal(uint64(1)))
+
+		By("truncating the torn entry")
+		Expect(reader.TruncateTail()).To(Succeed())
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+		writer, err = reader.ToWriter()
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(1)))
+		Expect(writer.Close()).To(Succeed())
+
+		fileInfo, err := os.Stat(filePath)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(fileInfo.Size()).To(Equal(int64(segment.DefaultPreAllocationSize)))
+
+		reader, err = segment.OpenSegment(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Value().Data).To(Equal([]byte("foo")))
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Value().Data).To(Equal([]byte("qux")))
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.TruncateTail()).ToNot(Succeed())
+		Expect(reader.Close()).To(Succeed())
+	})
+
+	It("should truncate a torn entry of several MiB", func() {
+		dir, err := os.MkdirTemp("", "test-segment-reader-*")
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		}()
+
+		writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+			PreAllocationSize:   segment.DefaultPreAllocationSize,
+			EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+			EntryChecksumType:   encoding.DefaultEntryChecksumType,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+		tornOffset := writer.Offset()
+		data := make([]byte, 8*1024*1024)
+		Expect(rand.Read(data)).Error().ToNot(HaveOccurred())
+		Expect(writer.AppendEntry(data)).Error().ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		// Flip a byte at the end of the data of the second entry, so that its checksum does not match anymore.
+		file, err := os.OpenFile(path.Join(dir, segment.SegmentFileName(0)), os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.WriteAt([]byte{^data[len(data)-1]}, tornOffset+4+int64(len(data))-1)).Error().ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		reader, err := segment.OpenSegment(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
 		Expect(reader.Next()).To(BeTrue())
-		Expect(reader.Offset()).To(Equal(int64(encoding.HeaderSize + 3*(4+3+4))))
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+
+		By("truncating the torn entry without trying every offset up to the end of the file")
+		start := time.Now()
+		Expect(reader.TruncateTail()).To(Succeed())
+		Expect(time.Since(start)).To(BeNumerically("<", 5*time.Second))
+		Expect(reader.Offset()).To(Equal(tornOffset))
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+		Expect(reader.Close()).To(Succeed())
 	})
+
+	It("should not truncate a damaged entry which is followed by valid entries", func() {
+		dir, err := os.MkdirTemp("", "test-segment-reader-*")
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		}()
+
+		writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+			PreAllocationSize:   segment.DefaultPreAllocationSize,
+			EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+			EntryChecksumType:   encoding.DefaultEntryChecksumType,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+		damagedOffset := writer.Offset()
+		Expect(writer.AppendEntry([]byte("bar"))).Error().ToNot(HaveOccurred())
+		Expect(writer.AppendEntry([]byte("baz"))).Error().ToNot(HaveOccurred())
+		Expect(writer.Close()).To(Succeed())
+
+		filePath := path.Join(dir, segment.SegmentFileName(0))
+		file, err := os.OpenFile(filePath, os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.WriteAt([]byte("qux"), damagedOffset+4+1)).Error().ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+		content, err := os.ReadFile(filePath)
+		Expect(err).ToNot(HaveOccurred())
+
+		reader, err := segment.OpenSegment(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Next()).To(BeTrue())
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+		Expect(reader.TruncateTail()).To(MatchError(segment.ErrEntryCorrupt))
+		Expect(reader.Offset()).To(Equal(damagedOffset))
+		Expect(reader.NextSequenceNumber()).To(Equal(uint64(1)))
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.Err()).To(MatchError(segment.ErrEntryTorn))
+		Expect(reader.Close()).To(Succeed())
+		Expect(os.ReadFile(filePath)).To(Equal(content))
+	})
+
+	It("should skip corrupt entries up to the next entry in the index", func() {
+		dir, err := os.MkdirTemp("", "test-segment-reader-*")
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(os.RemoveAll(dir)).To(Succeed())
+		}()
+
+		writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+			PreAllocationSize:   0,
+			EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+			EntryChecksumType:   encoding.DefaultEntryChecksumType,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		corruptOffset := writer.Offset()
+		for range 200 {
+			Expect(writer.AppendEntry(make([]byte, 1024))).Error().ToNot(HaveOccurred())
+		}
+		headerSize := writer.Header().Size()
+		Expect(writer.Close()).To(Succeed())
+		records, err := segment.ReadIndex(segment.IndexFilePath(path.Join(dir, segment.SegmentFileName(0))), headerSize)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(records).ToNot(BeEmpty())
+
+		// Corrupt the length of the first entry.
+		file, err := os.OpenFile(path.Join(dir, segment.SegmentFileName(0)), os.O_RDWR, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, corruptOffset)).Error().ToNot(HaveOccurred())
+		Expect(file.Close()).To(Succeed())
+
+		reader, err := segment.OpenSegmentReadOnly(dir, 0)
+		Expect(err).ToNot(HaveOccurred())
+		Expect(reader.Next()).To(BeFalse())
+		Expect(reader.SkipCorrupt()).To(BeTrue())
+		Expect(reader.NextSequenceNumber()).To(Equal(records[0].SequenceNumber))
+		for reader.Next() {
+			// Read all remaining entries.
+		}
+		Expect(reader.Err()).To(MatchError(io.EOF))
+		Expect(reader.NextSequenceNumber()).To(Equal(uint64(200)))
+
+		By("not skipping beyond the last entry in the index")
+		Expect(reader.SkipCorrupt()).To(BeFalse())
+		Expect(reader.Close()).To(Succeed())
+	})
+
+	for _, compression := range encoding.Compressions {
+		for _, mapped := range []bool{false, true} {
+			It(fmt.Sprintf("should decompress entries with compression %s and mapping %t", compression, mapped), func() {
+				dir, err := os.MkdirTemp("", "test-segment-reader-*")
+				Expect(err).ToNot(HaveOccurred())
+				defer func() {
+					Expect(os.RemoveAll(dir)).To(Succeed())
+				}()
+				event := bytes.Repeat([]byte(`{"event":"order-created","amount":42}`), 50)
+				random := make([]byte, 1024)
+				Expect(rand.Read(random)).Error().ToNot(HaveOccurred())
+
+				writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+					PreAllocationSize:   0,
+					EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+					EntryChecksumType:   encoding.DefaultEntryChecksumType,
+					Compression:         compression,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(writer.Header().Metadata.Compression).To(Equal(compression))
+
+				By("compressing only entries above the threshold which get smaller")
+				offset := writer.Offset()
+				Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+				Expect(writer.Offset() - offset).To(BeNumerically(">", len("foo")))
+				offset = writer.Offset()
+				Expect(writer.AppendEntry(event)).To(Equal(uint64(1)))
+				if compression == encoding.CompressionNone {
+					Expect(writer.Offset() - offset).To(BeNumerically(">", len(event)))
+				} else {
+					Expect(writer.Offset() - offset).To(BeNumerically("<", len(event)/10))
+				}
+				offset = writer.Offset()
+				Expect(writer.AppendEntry(random)).To(Equal(uint64(2)))
+				Expect(writer.Offset() - offset).To(BeNumerically(">", len(random)))
+
+				By("compressing batches and typed entries as a whole")
+				Expect(writer.AppendEntries([][]byte{event, []byte("bar"), event})).To(Equal(uint64(3)))
+				Expect(writer.AppendTypedEntry(segment.TypedEntry{
+					Type:    7,
+					Headers: []encoding.EntryHeader{{Key: "content-type", Value: []byte("application/json")}},
+					Data:    event,
+				})).To(Equal(uint64(6)))
+
+				By("compressing every entry above a lower threshold")
+				writer.SetCompressionThreshold(0)
+				Expect(writer.AppendEntry(bytes.Repeat([]byte("x"), 100))).To(Equal(uint64(7)))
+				Expect(writer.Close()).To(Succeed())
+
+				var reader *segment.SegmentReader
+				if mapped {
+					reader, err = segment.OpenSegmentMapped(dir, 0)
+				} else {
+					reader, err = segment.OpenSegment(dir, 0)
+				}
+				Expect(err).ToNot(HaveOccurred())
+				defer func() {
+					Expect(reader.Close()).To(Succeed())
+				}()
+
+				var values []segment.SegmentReaderValue
+				for i, data := range [][]byte{[]byte("foo"), event, random, event, []byte("bar"), event, event, bytes.Repeat([]byte("x"), 100)} {
+					Expect(reader.Next()).To(BeTrue())
+					Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+					Expect(reader.Value().Data).To(Equal(data))
+					if i == 6 {
+						Expect(reader.Value().Type).To(Equal(encoding.EntryType(7)))
+						Expect(reader.Value().Headers).To(Equal([]encoding.EntryHeader{{Key: "content-type", Value: []byte("application/json")}}))
+					}
+					values = append(values, reader.Value())
+				}
+				Expect(reader.Next()).To(BeFalse())
+				Expect(reader.Err()).To(MatchError(io.EOF))
+
+				if mapped {
+					By("keeping the data of all entries valid until closing the mapped segment file")
+					Expect(values[1].Data).To(Equal(event))
+					Expect(values[3].Data).To(Equal(event))
+				}
+			})
+		}
+	}
+
+	for _, entryChecksumType := range encoding.ChainedEntryChecksumTypes {
+		It(fmt.Sprintf("should chain the entries with entry checksum %s", entryChecksumType), func() {
+			dir, err := os.MkdirTemp("", "test-segment-reader-*")
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(os.RemoveAll(dir)).To(Succeed())
+			}()
+			key := []byte("secret")
+
+			writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+				PreAllocationSize:   segment.DefaultPreAllocationSize,
+				EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+				EntryChecksumType:   entryChecksumType,
+				EntryChecksumKey:    key,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Header().Metadata.PreviousDigest).To(Equal(make([]byte, encoding.EntryChainDigestSize)))
+			for range 200 {
+				Expect(writer.AppendEntry(make([]byte, 1024))).Error().ToNot(HaveOccurred())
+			}
+			Expect(writer.AppendEntryParts(make([]byte, 64*1024), []byte("foo"))).To(Equal(uint64(200)))
+			Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).To(Equal(uint64(201)))
+			digest := writer.Digest()
+			headerSize := writer.Header().Size()
+			Expect(writer.Close()).To(Succeed())
+			records, err := segment.ReadIndex(segment.IndexFilePath(path.Join(dir, segment.SegmentFileName(0))), headerSize)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(records).ToNot(BeEmpty())
+
+			for _, openSegment := range []func(string, uint64) (*segment.SegmentReader, error){segment.OpenSegment, segment.OpenSegmentMapped} {
+				reader, err := openSegment(dir, 0)
+				Expect(err).ToNot(HaveOccurred())
+				reader.SetEntryChecksumKey(key)
+				for reader.Next() {
+					// Read all entries.
+				}
+				Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+				Expect(reader.NextSequenceNumber()).To(Equal(uint64(203)))
+				Expect(reader.Digest()).To(Equal(digest))
+
+				By("continuing the chain after seeking")
+				Expect(reader.Seek(records[len(records)-1])).To(Succeed())
+				for reader.Next() {
+					// Read all remaining entries.
+				}
+				Expect(reader.NextSequenceNumber()).To(Equal(uint64(203)))
+				Expect(reader.Digest()).To(Equal(digest))
+				Expect(reader.Close()).To(Succeed())
+			}
+
+			By("continuing the chain after truncation")
+			writer, err = segment.TruncateSegment(dir, 0, 201, segment.DefaultPreAllocationSize, key)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(201)))
+			digest = writer.Digest()
+			Expect(writer.Close()).To(Succeed())
+			reader, err := segment.OpenSegment(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			reader.SetEntryChecksumKey(key)
+			for reader.Next() {
+				// Read all entries.
+			}
+			Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+			Expect(reader.NextSequenceNumber()).To(Equal(uint64(202)))
+			Expect(reader.Digest()).To(Equal(digest))
+			Expect(reader.Close()).To(Succeed())
+
+			if entryChecksumType != encoding.EntryChecksumTypeHmacSha256Chain {
+				return
+			}
+			By("failing without the key")
+			reader, err = segment.OpenSegment(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Err()).To(MatchError(encoding.ErrEntryChecksumKeyRequired))
+			Expect(reader.Err()).ToNot(MatchError(segment.ErrEntryTorn))
+			Expect(reader.Close()).To(Succeed())
+			Expect(segment.BuildIndex(dir, 0, nil)).Error().To(MatchError(encoding.ErrEntryChecksumKeyRequired))
+			Expect(segment.TruncateSegment(dir, 0, 1, 0, nil)).Error().To(MatchError(encoding.ErrEntryChecksumKeyRequired))
+		})
+	}
 })
 
 func BenchmarkSegmentReader_Next(b *testing.B) {
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"errors"
 	"fmt"
+	"hash"
 	"io"
 	"log"
 	"os"
@@ -14,9 +15,26 @@ import (
 	"github.com/backbone81/write-ahead-log/internal/utils"
 )
 
+var (
+	ErrBatchEmpty       = errors.New("the WAL batch does not contain any entries")
+	ErrBatchUnsupported = errors.New("the WAL segment file version does not support batches")
+	ErrTypedUnsupported = errors.New("the WAL segment file version does not support typed entries")
+	ErrEntrySizeInvalid = errors.New("the WAL entry size must not be negative")
+	ErrTruncateInBatch  = errors.New("the WAL segment can not be truncated in the middle of a batch")
+)
+
+// streamingThreshold is the entry size in bytes above which the data of an entry is written to the segment file
+// directly instead of being copied into the write buffer first.
+const streamingThreshold = 32 * 1024
+
+// DefaultCompressionThreshold is the entry size in bytes above which entries are compressed by default. Smaller
+// entries rarely get any smaller with compression.
+const DefaultCompressionThreshold = 256
+
 // SegmentWriterFile is an interface which needs to be implemented by the file to write to.
 type SegmentWriterFile interface {
 	io.WriteCloser
+	io.Seeker
 	Name() string
 	Sync() error
 	Truncate(size int64) error
@@ -46,12 +64,34 @@ type SegmentWriter struct {
 	// The writer to calculate and write the checksum.
 	entryChecksumWriter encoding.EntryChecksumWriter
 
+	// The hash to calculate the checksum incrementally for entries which are not copied into the write buffer.
+	entryChecksumHash hash.Hash
+
+	// The chain of digests for the chained entry checksum types. This is nil for all other checksum types.
+	entryChain *encoding.EntryChain
+
+	// The number of bytes the entry flags occupy for every entry. This is zero for segment files which do not support
+	// entry flags.
+	entryFlagsSize int
+
 	// This is a temporary buffer for converting integers into slices of bytes. This helps us with reducing the amount
 	// of memory allocations.
 	scratchBuffer [max(encoding.MaxLengthBufferLen, encoding.MaxChecksumBufferLen)]byte
 
 	// This buffer is used to combine multiple individual file write commands into a single one to improve performance.
 	writeBuffer *bytes.Buffer
+
+	// This buffer is used to encode all entries of a batch before they are written as the data of a single entry.
+	batchBuffer *bytes.Buffer
+
+	// This buffer is used to copy big entries from a reader to the segment file in chunks.
+	chunkBuffer []byte
+
+	// The index file which records the offsets of some entries. This is nil when no index is maintained.
+	index *indexWriter
+
+	// The compressor for the compression stored in the header metadata. It also encodes the envelope of typed entries.
+	entryCompressor *EntryCompressor
 }
 
 // CreateSegmentConfig is the configuration required for a call to CreateSegment.
@@ -65,6 +105,18 @@ type CreateSegmentConfig struct {
 
 	// EntryChecksumType is the type of entry checksum to use.
 	EntryChecksumType encoding.EntryChecksumType
+
+	// Compression is the compression of entries bigger than the compression threshold. It is stored in the header
+	// metadata and overwrites the compression given with Metadata.
+	Compression encoding.Compression
+
+	// Metadata is stored in the header of the new segment. The creation time is set to the current time when it is
+	// the zero time. For the chained entry checksum types, the previous digest should be the digest of the last entry
+	// of the previous segment. An empty previous digest starts a new chain.
+	Metadata encoding.HeaderMetadata
+
+	// EntryChecksumKey is the key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain.
+	EntryChecksumKey []byte
 }
 
 // DefaultPreAllocationSize is a segment size which should work well for most use cases.
@@ -95,8 +147,14 @@ func CreateSegment(directory string, firstSequenceNumber uint64, createSegmentCo
 		)
 	}
 
-	// Rename the temporary segment file to the final one.
+	// Create the empty index file before the segment file becomes visible. Otherwise, a reader could build an index
+	// file for the new segment file at the same time.
 	segmentFilePath := path.Join(directory, SegmentFileName(firstSequenceNumber))
+	if err := createIndex(IndexFilePath(segmentFilePath)); err != nil {
+		return nil, errors.Join(err, file.Close())
+	}
+
+	// Rename the temporary segment file to the final one.
 	file, err = renameSegment(file, offset, segmentFilePath)
 	if err != nil {
 		return nil, err
@@ -106,6 +164,8 @@ func CreateSegment(directory string, firstSequenceNumber uint64, createSegmentCo
 		Header:             header,
 		Offset:             offset,
 		NextSequenceNumber: firstSequenceNumber,
+		SegmentFilePath:    segmentFilePath,
+		EntryChecksumKey:   createSegmentConfig.EntryChecksumKey,
 	})
 }
 
@@ -133,6 +193,16 @@ func createNewSegment(filePath string, firstSequenceNumber uint64, createSegment
 		EntryLengthEncoding: createSegmentConfig.EntryLengthEncoding,
 		EntryChecksumType:   createSegmentConfig.EntryChecksumType,
 		FirstSequenceNumber: firstSequenceNumber,
+		Metadata:            createSegmentConfig.Metadata,
+	}
+	header.Metadata.Compression = createSegmentConfig.Compression
+	if header.Metadata.CreatedAt.IsZero() {
+		// We strip the monotonic clock reading, as it is not stored in the header.
+		header.Metadata.CreatedAt = time.Now().Round(0)
+	}
+	if header.EntryChecksumType.IsChained() && len(header.Metadata.PreviousDigest) == 0 {
+		// We always store the previous digest, so that the start of a new chain is visible in the header.
+		header.Metadata.PreviousDigest = make([]byte, encoding.EntryChainDigestSize)
 	}
 	var buffer [encoding.HeaderSize]byte
 	if err := encoding.WriteHeader(file, buffer[:], header); err != nil {
@@ -144,6 +214,113 @@ func createNewSegment(filePath string, firstSequenceNumber uint64, createSegment
 	return file, header, nil
 }
 
+// TruncateSegment removes all entries with a sequence number of nextSequenceNumber and above from an existing segment
+// file. It returns a writer which continues appending at nextSequenceNumber.
+//
+// directory is the directory all segment files are located in.
+// firstSequenceNumber is the first sequence number of the segment to truncate.
+// nextSequenceNumber is the sequence number the next entry written to the segment will receive.
+// preAllocationSize is the size the segment file is extended to again after truncation. Zero disables pre-allocation.
+// entryChecksumKey is the key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain.
+// Returns ErrTruncateInBatch when nextSequenceNumber points into the middle of a batch.
+func TruncateSegment(directory string, firstSequenceNumber uint64, nextSequenceNumber uint64, preAllocationSize int64, entryChecksumKey []byte) (*SegmentWriter, error) {
+	segmentReader, err := OpenSegment(directory, firstSequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	segmentReader.SetEntryChecksumKey(entryChecksumKey)
+
+	segmentWriter, err := truncateSegment(segmentReader, nextSequenceNumber, preAllocationSize)
+	if err != nil {
+		closeErr := segmentReader.Close()
+		return nil, errors.Join(
+			fmt.Errorf("truncating the WAL segment file %q: %w", segmentReader.FilePath(), err),
+			closeErr,
+		)
+	}
+	return segmentWriter, nil
+}
+
+// CheckTruncateSegment reports if TruncateSegment would be able to truncate the segment file at nextSequenceNumber,
+// without modifying the segment file. This allows validating the truncation point before touching any files.
+// Returns ErrTruncateInBatch when nextSequenceNumber points into the middle of a batch.
+func CheckTruncateSegment(directory string, firstSequenceNumber uint64, nextSequenceNumber uint64, entryChecksumKey []byte) error {
+	segmentReader, err := OpenSegmentReadOnly(directory, firstSequenceNumber)
+	if err != nil {
+		return err
+	}
+	segmentReader.SetEntryChecksumKey(entryChecksumKey)
+
+	if err := seekTruncation(segmentReader, nextSequenceNumber); err != nil {
+		return errors.Join(
+			fmt.Errorf("truncating the WAL segment file %q: %w", segmentReader.FilePath(), err),
+			segmentReader.Close(),
+		)
+	}
+	return segmentReader.Close()
+}
+
+func truncateSegment(segmentReader *SegmentReader, nextSequenceNumber uint64, preAllocationSize int64) (*SegmentWriter, error) {
+	if err := seekTruncation(segmentReader, nextSequenceNumber); err != nil {
+		return nil, err
+	}
+
+	file, ok := segmentReader.file.(SegmentWriterFile)
+	if !ok {
+		return nil, errors.New("the segment file does not implement the interface for writing to it")
+	}
+
+	// We truncate to the current offset first to get rid of all entries after it. Extending the file afterward fills
+	// the remaining file with zeros which looks the same as a freshly pre-allocated segment file.
+	offset := segmentReader.Offset()
+	if err := file.Truncate(offset); err != nil {
+		return nil, fmt.Errorf("truncating file: %w", err)
+	}
+	if preAllocationSize > offset {
+		if err := file.Truncate(preAllocationSize); err != nil {
+			return nil, fmt.Errorf("pre-allocating file: %w", err)
+		}
+	}
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("seeking file: %w", err)
+	}
+	if err := file.Sync(); err != nil {
+		return nil, fmt.Errorf("flushing file: %w", err)
+	}
+
+	return NewSegmentWriter(file, NewSegmentWriterConfig{
+		Header:             segmentReader.Header(),
+		Offset:             offset,
+		NextSequenceNumber: nextSequenceNumber,
+		SegmentFilePath:    segmentReader.segmentFilePath,
+		EntryChecksumKey:   segmentReader.entryChecksumKey,
+		PreviousDigest:     segmentReader.Digest(),
+	})
+}
+
+// seekTruncation moves the segment reader to the position where the segment file needs to be truncated for the next
+// entry to receive nextSequenceNumber.
+func seekTruncation(segmentReader *SegmentReader, nextSequenceNumber uint64) error {
+	if err := segmentReader.SeekIndex(nextSequenceNumber); err != nil {
+		return err
+	}
+	for segmentReader.NextSequenceNumber() < nextSequenceNumber && segmentReader.Next() {
+		// Skip entries until we have reached the entry to truncate at.
+	}
+	if segmentReader.NextSequenceNumber() != nextSequenceNumber {
+		return fmt.Errorf(
+			"expected to reach sequence number %d but instead reached %d: %w",
+			nextSequenceNumber,
+			segmentReader.NextSequenceNumber(),
+			segmentReader.Err(),
+		)
+	}
+	if segmentReader.batchReader.Len() > 0 {
+		return ErrTruncateInBatch
+	}
+	return nil
+}
+
 // NewSegmentWriterConfig is the configuration required for a call to NewSegmentWriter.
 type NewSegmentWriterConfig struct {
 	// Header is the segment file header.
@@ -154,6 +331,18 @@ type NewSegmentWriterConfig struct {
 
 	// NextSequenceNumber is the sequence number the next entry will receive.
 	NextSequenceNumber uint64
+
+	// SegmentFilePath is the path of the segment file. When set, the index file next to the segment file is maintained.
+	// Records in the index file at or after Offset are removed. A missing index file is built again.
+	SegmentFilePath string
+
+	// EntryChecksumKey is the key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain. It is required
+	// for writing to such segment files.
+	EntryChecksumKey []byte
+
+	// PreviousDigest is the digest of the entry in front of Offset for the chained entry checksum types. When empty,
+	// the previous digest stored in the header metadata is used.
+	PreviousDigest []byte
 }
 
 // NewSegmentWriter creates a SegmentWriter from a file which is already open.
@@ -163,11 +352,48 @@ func NewSegmentWriter(file SegmentWriterFile, newSegmentWriterConfig NewSegmentW
 		return nil, err
 	}
 
-	entryChecksumWriter, err := encoding.GetEntryChecksumWriter(newSegmentWriterConfig.Header.EntryChecksumType)
+	var entryChecksumWriter encoding.EntryChecksumWriter
+	var entryChecksumHash hash.Hash
+	var entryChain *encoding.EntryChain
+	if newSegmentWriterConfig.Header.EntryChecksumType.IsChained() {
+		previousDigest := newSegmentWriterConfig.PreviousDigest
+		if len(previousDigest) == 0 {
+			previousDigest = newSegmentWriterConfig.Header.Metadata.PreviousDigest
+		}
+		entryChain, err = encoding.NewEntryChain(newSegmentWriterConfig.Header.EntryChecksumType, newSegmentWriterConfig.EntryChecksumKey, previousDigest)
+		if err != nil {
+			return nil, err
+		}
+		if err := entryChain.Ready(); err != nil {
+			return nil, err
+		}
+		entryChecksumWriter = entryChain.WriteEntryChecksum
+		entryChecksumHash = entryChain.Hash()
+	} else {
+		entryChecksumWriter, err = encoding.GetEntryChecksumWriter(newSegmentWriterConfig.Header.EntryChecksumType)
+		if err != nil {
+			return nil, err
+		}
+
+		entryChecksumHash, err = encoding.NewEntryChecksumHash(newSegmentWriterConfig.Header.EntryChecksumType)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	entryCompressor, err := NewEntryCompressor(newSegmentWriterConfig.Header.Metadata.Compression, DefaultCompressionThreshold)
 	if err != nil {
 		return nil, err
 	}
 
+	var index *indexWriter
+	if newSegmentWriterConfig.SegmentFilePath != "" {
+		index, err = openIndexWriter(newSegmentWriterConfig.SegmentFilePath, newSegmentWriterConfig.Header, newSegmentWriterConfig.Offset, newSegmentWriterConfig.EntryChecksumKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &SegmentWriter{
 		file:                file,
 		header:              newSegmentWriterConfig.Header,
@@ -175,10 +401,23 @@ func NewSegmentWriter(file SegmentWriterFile, newSegmentWriterConfig NewSegmentW
 		nextSequenceNumber:  newSegmentWriterConfig.NextSequenceNumber,
 		entryLengthWriter:   entryLengthWriter,
 		entryChecksumWriter: entryChecksumWriter,
+		entryChecksumHash:   entryChecksumHash,
+		entryChain:          entryChain,
+		entryFlagsSize:      encoding.EntryFlagsSize(newSegmentWriterConfig.Header.Version),
 		writeBuffer:         bytes.NewBuffer(make([]byte, 0, 4*1024)),
+		batchBuffer:         bytes.NewBuffer(make([]byte, 0, 4*1024)),
+		index:               index,
+		entryCompressor:     entryCompressor,
 	}, nil
 }
 
+// SetCompressionThreshold sets the entry size in bytes above which entries are compressed. This only has an effect
+// when the header metadata of the segment file has a compression. Entries which do not get smaller are always stored
+// uncompressed.
+func (w *SegmentWriter) SetCompressionThreshold(compressionThreshold int) {
+	w.entryCompressor.compressionThreshold = compressionThreshold
+}
+
 // FilePath returns the file path of the file this writer is writing to.
 func (w *SegmentWriter) FilePath() string {
 	return w.file.Name()
@@ -199,35 +438,380 @@ func (w *SegmentWriter) NextSequenceNumber() uint64 {
 	return w.nextSequenceNumber
 }
 
-// AppendEntry adds the given entry to the segment.
+// Digest returns the digest of the last entry written for the chained entry checksum types. Before writing the first
+// entry, this is the digest stored in the header metadata. Returns nil for all other entry checksum types.
+func (w *SegmentWriter) Digest() []byte {
+	if w.entryChain == nil {
+		return nil
+	}
+	return w.entryChain.Digest()
+}
+
+// AppendEntry adds the given entry to the segment. Entries bigger than the compression threshold are compressed when
+// the segment file has a compression.
 func (w *SegmentWriter) AppendEntry(data []byte) (uint64, error) {
 	AppendEntryTotal.Inc()
 	AppendEntryBytes.Add(float64(len(data)))
 
-	w.writeBuffer.Reset()
-	if err := w.entryLengthWriter(w.writeBuffer, w.scratchBuffer[:], uint64(len(data))); err != nil {
+	if err := w.writeEntry(0, data); err != nil {
 		return 0, err
 	}
-	if len(data) > 0 {
-		if _, err := w.writeBuffer.Write(data); err != nil {
+	sequenceNumber := w.nextSequenceNumber
+	w.nextSequenceNumber++
+
+	return sequenceNumber, nil
+}
+
+// TypedEntry is an entry which carries an entry type, flags and headers next to its data.
+type TypedEntry struct {
+	// Type describes what kind of data the entry holds. Entry types starting with encoding.EntryTypeControl are
+	// reserved for control records.
+	Type encoding.EntryType
+
+	// Flags are defined by the application. They are not interpreted by the library.
+	Flags uint8
+
+	// Headers are key values attached to the entry. See encoding.MaxEntryHeaders and encoding.MaxEntryHeaderLen for
+	// their limits.
+	Headers []encoding.EntryHeader
+
+	// Data is the data of the entry.
+	Data []byte
+}
+
+// AppendTypedEntry adds the given entry together with its entry type, flags and headers to the segment. The entry
+// types reserved for control records are accepted as well, so that control records can be written with it.
+// Returns ErrTypedUnsupported when the segment file version does not support entry flags.
+func (w *SegmentWriter) AppendTypedEntry(entry TypedEntry) (uint64, error) {
+	if w.entryFlagsSize == 0 {
+		return 0, ErrTypedUnsupported
+	}
+	compressedEntry, err := w.entryCompressor.CompressTypedEntry(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	AppendEntryTotal.Inc()
+	AppendEntryBytes.Add(float64(len(entry.Data)))
+
+	if err := w.writeCompressedEntry(compressedEntry); err != nil {
+		return 0, err
+	}
+	sequenceNumber := w.nextSequenceNumber
+	w.nextSequenceNumber++
+
+	return sequenceNumber, nil
+}
+
+// AppendEntries adds all given entries to the segment as a single batch. The batch is written as one entry which is
+// protected by a single checksum. This guarantees that a reader either sees all entries of the batch or none of them.
+// Every entry in the batch still receives its own sequence number. The return value is the sequence number of the
+// first entry in the batch.
+// Returns ErrBatchUnsupported when the segment file version does not support batches with more than one entry.
+func (w *SegmentWriter) AppendEntries(entries [][]byte) (uint64, error) {
+	if len(entries) == 0 {
+		return 0, ErrBatchEmpty
+	}
+	if len(entries) == 1 {
+		// A batch with a single entry is atomic by itself, so there is no need for the batch overhead.
+		return w.AppendEntry(entries[0])
+	}
+	if w.entryFlagsSize == 0 {
+		return 0, ErrBatchUnsupported
+	}
+
+	w.batchBuffer.Reset()
+	for _, data := range entries {
+		AppendEntryBytes.Add(float64(len(data)))
+		if err := w.entryLengthWriter(w.batchBuffer, w.scratchBuffer[:], uint64(len(data))); err != nil {
+			return 0, err
+		}
+		if _, err := w.batchBuffer.Write(data); err != nil {
 			return 0, err
 		}
 	}
+	AppendEntryTotal.Add(float64(len(entries)))
 
-	if err := w.entryChecksumWriter(w.writeBuffer, w.scratchBuffer[:], w.writeBuffer.Bytes()); err != nil {
+	if err := w.writeEntry(encoding.EntryFlagBatch, w.batchBuffer.Bytes()); err != nil {
 		return 0, err
 	}
+	sequenceNumber := w.nextSequenceNumber
+	w.nextSequenceNumber += uint64(len(entries))
 
-	if _, err := w.file.Write(w.writeBuffer.Bytes()); err != nil {
-		return 0, fmt.Errorf("writing WAL entry to segment file: %w", err)
+	return sequenceNumber, nil
+}
+
+// AppendEntryParts adds a single entry to the segment which consists of the concatenation of all given parts. This
+// avoids copying the parts into a contiguous slice of bytes before appending them.
+func (w *SegmentWriter) AppendEntryParts(parts ...[]byte) (uint64, error) {
+	AppendEntryTotal.Inc()
+	for _, part := range parts {
+		AppendEntryBytes.Add(float64(len(part)))
+	}
+
+	if err := w.writeEntry(0, parts...); err != nil {
+		return 0, err
 	}
 	sequenceNumber := w.nextSequenceNumber
 	w.nextSequenceNumber++
-	w.offset += int64(w.writeBuffer.Len())
 
 	return sequenceNumber, nil
 }
 
+// AppendEntryFrom adds a single entry to the segment which consists of exactly size bytes read from the reader. Entries
+// of up to 32 KiB are read into memory and compressed the same way as with AppendEntry. Bigger entries are streamed to
+// the segment file in chunks without holding the whole entry in memory and are never compressed.
+// When the reader returns fewer bytes than announced or fails, nothing of the entry is left in the segment file and the
+// segment can still be appended to.
+func (w *SegmentWriter) AppendEntryFrom(reader io.Reader, size int64) (uint64, error) {
+	if size < 0 {
+		return 0, ErrEntrySizeInvalid
+	}
+	AppendEntryTotal.Inc()
+	AppendEntryBytes.Add(float64(size))
+
+	if size <= streamingThreshold {
+		w.batchBuffer.Reset()
+		w.batchBuffer.Grow(int(size))
+		data := w.batchBuffer.AvailableBuffer()[:size]
+		if err := readEntryData(reader, data); err != nil {
+			return 0, err
+		}
+		if err := w.writeEntry(0, data); err != nil {
+			return 0, err
+		}
+	} else {
+		if err := w.streamEntry(reader, size); err != nil {
+			return 0, err
+		}
+	}
+	sequenceNumber := w.nextSequenceNumber
+	w.nextSequenceNumber++
+
+	return sequenceNumber, nil
+}
+
+// AppendCompressedEntry adds the entry which was prepared by an EntryCompressor to the segment. Entries which were
+// compressed with a different compression than the one stored in the header metadata are compressed again.
+// Returns ErrTypedUnsupported for typed entries when the segment file version does not support entry flags.
+func (w *SegmentWriter) AppendCompressedEntry(entry CompressedEntry) (uint64, error) {
+	if entry.flags&encoding.EntryFlagTyped != 0 && w.entryFlagsSize == 0 {
+		return 0, ErrTypedUnsupported
+	}
+	if entry.compression != w.header.Metadata.Compression {
+		// This happens when a segment file with a different compression is continued.
+		var err error
+		entry, err = w.entryCompressor.compress(entry.flags&^encoding.EntryFlagCompressed, entry.size, entry.parts)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	AppendEntryTotal.Inc()
+	AppendEntryBytes.Add(float64(entry.size))
+
+	if err := w.writeCompressedEntry(entry); err != nil {
+		return 0, err
+	}
+	sequenceNumber := w.nextSequenceNumber
+	w.nextSequenceNumber++
+
+	return sequenceNumber, nil
+}
+
+// writeEntry encodes the entry with the given flags and data parts and writes it to the segment file. Entries bigger
+// than the compression threshold are compressed first.
+func (w *SegmentWriter) writeEntry(flags encoding.EntryFlags, parts ...[]byte) error {
+	flags, compressed, err := w.entryCompressor.compressParts(flags, parts)
+	if err != nil {
+		return err
+	}
+	return w.writeEncodedEntry(flags, parts, compressed)
+}
+
+// writeCompressedEntry writes the entry which was prepared by an EntryCompressor to the segment file.
+func (w *SegmentWriter) writeCompressedEntry(entry CompressedEntry) error {
+	return w.writeEncodedEntry(entry.flags, entry.parts, entry.compressed)
+}
+
+// writeEncodedEntry writes the entry with the given flags and data parts to the segment file. When the compressed data
+// is not nil, it is written instead of the parts. Small entries are written with a single write. Big entries are
+// written part by part to avoid copying them.
+func (w *SegmentWriter) writeEncodedEntry(flags encoding.EntryFlags, parts [][]byte, compressed []byte) error {
+	var compressedParts [1][]byte
+	if compressed != nil {
+		compressedParts[0] = compressed
+		parts = compressedParts[:]
+	}
+
+	var length int
+	for _, part := range parts {
+		length += len(part)
+	}
+
+	if err := w.writeEntryPrefix(flags, uint64(length)); err != nil {
+		return err
+	}
+	if length > streamingThreshold {
+		return w.writeEntryParts(parts)
+	}
+	for _, part := range parts {
+		if _, err := w.writeBuffer.Write(part); err != nil {
+			return err
+		}
+	}
+
+	if err := w.entryChecksumWriter(w.writeBuffer, w.scratchBuffer[:], w.writeBuffer.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err := w.file.Write(w.writeBuffer.Bytes()); err != nil {
+		return w.rollback(fmt.Errorf("writing WAL entry to segment file: %w", err))
+	}
+	w.entryWritten(int64(w.writeBuffer.Len()))
+	return nil
+}
+
+// writeEntryPrefix resets the write buffer and encodes the length and the flags of an entry into it.
+func (w *SegmentWriter) writeEntryPrefix(flags encoding.EntryFlags, length uint64) error {
+	w.writeBuffer.Reset()
+	if err := w.entryLengthWriter(w.writeBuffer, w.scratchBuffer[:], length); err != nil {
+		return err
+	}
+	if w.entryFlagsSize > 0 {
+		if err := w.writeBuffer.WriteByte(byte(flags)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEntryParts writes the prefix in the write buffer, the parts and the checksum to the segment file with
+// individual writes. The checksum is calculated incrementally.
+func (w *SegmentWriter) writeEntryParts(parts [][]byte) error {
+	written, err := w.writeEntryChunk(0, w.writeBuffer.Bytes())
+	if err != nil {
+		return err
+	}
+	for _, part := range parts {
+		written, err = w.writeEntryChunk(written, part)
+		if err != nil {
+			return err
+		}
+	}
+	return w.writeEntryChecksum(written)
+}
+
+// streamEntry writes the prefix in the write buffer, size bytes from the reader and the checksum to the segment file
+// in chunks. The checksum is calculated incrementally.
+func (w *SegmentWriter) streamEntry(reader io.Reader, size int64) error {
+	if err := w.writeEntryPrefix(0, uint64(size)); err != nil {
+		return err
+	}
+	written, err := w.writeEntryChunk(0, w.writeBuffer.Bytes())
+	if err != nil {
+		return err
+	}
+
+	if w.chunkBuffer == nil {
+		w.chunkBuffer = make([]byte, streamingThreshold)
+	}
+	for remaining := size; remaining > 0; {
+		chunk := w.chunkBuffer[:min(remaining, int64(len(w.chunkBuffer)))]
+		if err := readEntryData(reader, chunk); err != nil {
+			return w.rollback(err)
+		}
+		written, err = w.writeEntryChunk(written, chunk)
+		if err != nil {
+			return err
+		}
+		remaining -= int64(len(chunk))
+	}
+	return w.writeEntryChecksum(written)
+}
+
+// readEntryData fills the data with bytes from the reader. Running out of bytes is always reported as
+// io.ErrUnexpectedEOF, because the caller announced more bytes than the reader provided.
+func readEntryData(reader io.Reader, data []byte) error {
+	if _, err := io.ReadFull(reader, data); err != nil {
+		if errors.Is(err, io.EOF) {
+			err = io.ErrUnexpectedEOF
+		}
+		return fmt.Errorf("reading WAL entry: %w", err)
+	}
+	return nil
+}
+
+// writeEntryChunk writes the chunk to the segment file and adds it to the checksum. The written argument is the number
+// of bytes of the current entry which were already written. The first chunk of an entry resets the checksum.
+func (w *SegmentWriter) writeEntryChunk(written int64, chunk []byte) (int64, error) {
+	if written == 0 {
+		w.entryChecksumHash.Reset()
+	}
+	w.entryChecksumHash.Write(chunk) //nolint:errcheck // Writing to a hash never returns an error.
+	if _, err := w.file.Write(chunk); err != nil {
+		return 0, w.rollback(fmt.Errorf("writing WAL entry to segment file: %w", err))
+	}
+	return written + int64(len(chunk)), nil
+}
+
+// writeEntryChecksum writes the incrementally calculated checksum to the segment file and completes the entry.
+func (w *SegmentWriter) writeEntryChecksum(written int64) error {
+	w.writeBuffer.Reset()
+	if err := encoding.WriteEntryChecksumHash(w.writeBuffer, w.scratchBuffer[:], w.entryChecksumHash); err != nil {
+		return w.rollback(err)
+	}
+	if _, err := w.file.Write(w.writeBuffer.Bytes()); err != nil {
+		return w.rollback(fmt.Errorf("writing WAL entry to segment file: %w", err))
+	}
+	w.entryWritten(written + int64(w.writeBuffer.Len()))
+	return nil
+}
+
+// entryWritten moves the offset past the entry which was just written and records the entry in the index file. Failing
+// to write to the index file is not fatal, as the index only speeds up seeking. No more records are written to the
+// index file in that case, because a partially written record would break all records after it.
+func (w *SegmentWriter) entryWritten(entrySize int64) {
+	if w.index != nil && !w.index.entryWritten(w.nextSequenceNumber, w.offset) {
+		if err := w.index.Close(); err != nil {
+			log.Printf("WARNING: Closing the WAL segment index file failed: %v\n", err)
+		}
+		w.index = nil
+	}
+	if w.entryChain != nil {
+		w.entryChain.Commit()
+	}
+	w.offset += entrySize
+}
+
+// rollback removes everything which was written to the segment file after the last complete entry. This is needed
+// when an entry could only be written partially. When the entry was written into the pre-allocated space, the segment
+// file keeps its size and the partially written entry is replaced with zeros. The given error is returned, extended by
+// any error which happened during the rollback.
+func (w *SegmentWriter) rollback(err error) error {
+	position, seekErr := w.file.Seek(0, io.SeekCurrent)
+	if seekErr != nil {
+		return errors.Join(err, fmt.Errorf("seeking to the end of the partially written WAL entry: %w", seekErr))
+	}
+	fileSize, seekErr := w.file.Seek(0, io.SeekEnd)
+	if seekErr != nil {
+		return errors.Join(err, fmt.Errorf("seeking to the end of the WAL segment file: %w", seekErr))
+	}
+	if _, seekErr := w.file.Seek(w.offset, io.SeekStart); seekErr != nil {
+		return errors.Join(err, fmt.Errorf("seeking to the end of the last WAL entry: %w", seekErr))
+	}
+	if truncateErr := w.file.Truncate(w.offset); truncateErr != nil {
+		return errors.Join(err, fmt.Errorf("truncating the partially written WAL entry: %w", truncateErr))
+	}
+	if position < fileSize {
+		// Extending the file again fills the partially written entry with zeros.
+		if truncateErr := w.file.Truncate(fileSize); truncateErr != nil {
+			return errors.Join(err, fmt.Errorf("restoring the pre-allocated space: %w", truncateErr))
+		}
+	}
+	return err
+}
+
 // Sync flushes the content of the segment to stable storage.
 func (w *SegmentWriter) Sync() error {
 	SyncTotal.Inc()
@@ -254,8 +838,13 @@ func (w *SegmentWriter) Truncate() error {
 	return nil
 }
 
-// Close flushes all pending changes to disk and closes the file.
+// Close flushes all pending changes to disk and closes the file. The index file is closed as well.
 func (w *SegmentWriter) Close() error {
+	if w.index != nil {
+		if err := w.index.Close(); err != nil {
+			return errors.Join(err, w.file.Close())
+		}
+	}
 	if err := w.file.Close(); err != nil {
 		return err
 	}
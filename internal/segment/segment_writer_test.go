@@ -1,9 +1,13 @@
 package segment_test
 
 import (
+	"bytes"
 	"crypto/rand"
 	"fmt"
+	"io"
+	"math"
 	"os"
+	"path"
 	"testing"
 
 	. "github.com/onsi/ginkgo/v2"
@@ -30,7 +34,7 @@ var _ = Describe("SegmentWriter", func() {
 					Expect(os.RemoveAll(dir)).To(Succeed())
 				})
 
-				It("should create a new segment file", func() {
+				It("should create a new segment file and its index file", func() {
 					entriesBefore, err := os.ReadDir(dir)
 					Expect(err).ToNot(HaveOccurred())
 
@@ -46,7 +50,7 @@ var _ = Describe("SegmentWriter", func() {
 
 					entriesAfter, err := os.ReadDir(dir)
 					Expect(err).ToNot(HaveOccurred())
-					Expect(entriesAfter).To(HaveLen(len(entriesBefore) + 1))
+					Expect(entriesAfter).To(HaveLen(len(entriesBefore) + 2))
 				})
 
 				It("should write to the segment file", func() {
@@ -66,6 +70,45 @@ var _ = Describe("SegmentWriter", func() {
 						Expect(writer.AppendEntry(data[:])).Error().ToNot(HaveOccurred())
 					}
 				})
+
+				It("should write entries from parts and readers", func() {
+					writer, err := segment.CreateSegment(dir, 0, segment.CreateSegmentConfig{
+						PreAllocationSize:   segment.DefaultPreAllocationSize,
+						EntryLengthEncoding: entryLengthEncoding,
+						EntryChecksumType:   entryChecksumType,
+					})
+					Expect(err).ToNot(HaveOccurred())
+
+					bigData := make([]byte, math.MaxUint16)
+					Expect(rand.Read(bigData)).Error().ToNot(HaveOccurred())
+
+					Expect(writer.AppendEntryParts([]byte("foo"), nil, []byte("bar"))).To(Equal(uint64(0)))
+					Expect(writer.AppendEntryParts(bigData[:1000], bigData[1000:])).To(Equal(uint64(1)))
+					Expect(writer.AppendEntryFrom(bytes.NewReader([]byte("baz")), 3)).To(Equal(uint64(2)))
+					Expect(writer.AppendEntryFrom(bytes.NewReader(bigData), int64(len(bigData)))).To(Equal(uint64(3)))
+
+					offset := writer.Offset()
+					Expect(writer.AppendEntryFrom(bytes.NewReader([]byte("baz")), 4)).Error().To(MatchError(io.ErrUnexpectedEOF))
+					Expect(writer.AppendEntryFrom(bytes.NewReader(bigData[:1000]), int64(len(bigData)))).Error().To(MatchError(io.ErrUnexpectedEOF))
+					Expect(writer.AppendEntryFrom(bytes.NewReader(nil), -1)).Error().To(MatchError(segment.ErrEntrySizeInvalid))
+					Expect(writer.Offset()).To(Equal(offset))
+					fileInfo, err := os.Stat(path.Join(dir, segment.SegmentFileName(0)))
+					Expect(err).ToNot(HaveOccurred())
+					Expect(fileInfo.Size()).To(Equal(int64(segment.DefaultPreAllocationSize)))
+
+					Expect(writer.AppendEntry([]byte("qux"))).To(Equal(uint64(4)))
+					Expect(writer.Close()).To(Succeed())
+
+					reader, err := segment.OpenSegment(dir, 0)
+					Expect(err).ToNot(HaveOccurred())
+					for _, data := range [][]byte{[]byte("foobar"), bigData, []byte("baz"), bigData, []byte("qux")} {
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().Data).To(Equal(data))
+					}
+					Expect(reader.Next()).To(BeFalse())
+					Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+					Expect(reader.Close()).To(Succeed())
+				})
 			})
 		}
 	}
@@ -89,6 +132,53 @@ var _ = Describe("SegmentWriter", func() {
 		Expect(writer.NextSequenceNumber()).To(Equal(uint64(3)))
 	})
 
+	It("should assign a sequence number to every entry of a batch", func() {
+		writer, err := segment.NewSegmentWriter(&utils.SegmentWriterFileDiscard{}, segment.NewSegmentWriterConfig{
+			Header: encoding.DefaultHeader,
+			Offset: encoding.HeaderSize,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(writer.Close()).To(Succeed())
+		}()
+
+		Expect(writer.AppendEntries([][]byte{[]byte("foo"), []byte("bar"), []byte("baz")})).To(Equal(uint64(0)))
+		Expect(writer.NextSequenceNumber()).To(Equal(uint64(3)))
+		Expect(writer.AppendEntries([][]byte{[]byte("foo")})).To(Equal(uint64(3)))
+		Expect(writer.NextSequenceNumber()).To(Equal(uint64(4)))
+	})
+
+	It("should fail to append an empty batch", func() {
+		writer, err := segment.NewSegmentWriter(&utils.SegmentWriterFileDiscard{}, segment.NewSegmentWriterConfig{
+			Header: encoding.DefaultHeader,
+			Offset: encoding.HeaderSize,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(writer.Close()).To(Succeed())
+		}()
+
+		Expect(writer.AppendEntries(nil)).Error().To(MatchError(segment.ErrBatchEmpty))
+		Expect(writer.NextSequenceNumber()).To(Equal(uint64(0)))
+	})
+
+	It("should fail to append a batch to a segment file without batch support", func() {
+		header := encoding.DefaultHeader
+		header.Version = encoding.HeaderVersion1
+		writer, err := segment.NewSegmentWriter(&utils.SegmentWriterFileDiscard{}, segment.NewSegmentWriterConfig{
+			Header: header,
+			Offset: encoding.HeaderSize,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		defer func() {
+			Expect(writer.Close()).To(Succeed())
+		}()
+
+		Expect(writer.AppendEntries([][]byte{[]byte("foo"), []byte("bar")})).Error().To(MatchError(segment.ErrBatchUnsupported))
+		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + (4 + 3 + 4))))
+	})
+
 	It("should correctly report offsets", func() {
 		writer, err := segment.NewSegmentWriter(&utils.SegmentWriterFileDiscard{}, segment.NewSegmentWriterConfig{
 			Header: encoding.DefaultHeader,
@@ -101,11 +191,11 @@ var _ = Describe("SegmentWriter", func() {
 
 		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize)))
 		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
-		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 1*(4+3+4))))
+		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 1*(4+1+3+4))))
 		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
-		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 2*(4+3+4))))
+		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 2*(4+1+3+4))))
 		Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
-		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 3*(4+3+4))))
+		Expect(writer.Offset()).To(Equal(int64(encoding.HeaderSize + 3*(4+1+3+4))))
 	})
 })
 
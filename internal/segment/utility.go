@@ -1,16 +1,29 @@
 package segment
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"path"
 	"regexp"
 	"slices"
 	"strconv"
 	"strings"
 )
 
+const (
+	// segmentFileExtension is the file extension of segment files.
+	segmentFileExtension = ".wal"
+
+	// indexFileExtension is the file extension of the index files which belong to segment files.
+	indexFileExtension = ".idx"
+)
+
+// ErrSegmentNotFound is returned when a segment file does not exist, like when it was removed by a retention policy.
+var ErrSegmentNotFound = errors.New("the WAL segment does not exist")
+
 // segmentFileNamePattern is the file pattern all segment files need to follow.
-var segmentFileNamePattern = regexp.MustCompile(`\d{20}\.wal`)
+var segmentFileNamePattern = regexp.MustCompile(`^\d{20}\.wal$`)
 
 // GetSegments returns a list of sequence numbers representing the start of the corresponding segment. The sequence
 // numbers are sorted in ascending order.
@@ -30,7 +43,7 @@ func GetSegments(directory string) ([]uint64, error) {
 			// We are not interested in files not matching our naming pattern.
 			continue
 		}
-		sequenceNumber, err := strconv.ParseUint(strings.TrimSuffix(dirEntry.Name(), ".wal"), 10, 64)
+		sequenceNumber, err := strconv.ParseUint(strings.TrimSuffix(dirEntry.Name(), segmentFileExtension), 10, 64)
 		if err != nil {
 			// This error should never occur when our file name pattern is correct.
 			return nil, fmt.Errorf("parsing the sequence number from the file name: %w", err)
@@ -59,11 +72,48 @@ func SegmentFromSequenceNumber(directory string, sequenceNumber uint64) (uint64,
 	}
 	if index < 0 {
 		// The sequence number is in a segment which does not exist in the directory.
-		return 0, fmt.Errorf("no segment available for sequence number %q", sequenceNumber)
+		return 0, fmt.Errorf("no segment available for sequence number %d: %w", sequenceNumber, ErrSegmentNotFound)
 	}
 	return segments[index], nil
 }
 
 func SegmentFileName(sequenceNumber uint64) string {
-	return fmt.Sprintf("%020d.wal", sequenceNumber)
+	return fmt.Sprintf("%020d", sequenceNumber) + segmentFileExtension
+}
+
+// RemoveSegment removes the segment file with the given first sequence number from the directory. The index file
+// belonging to the segment file is removed as well.
+func RemoveSegment(directory string, firstSequenceNumber uint64) error {
+	segmentFilePath := path.Join(directory, SegmentFileName(firstSequenceNumber))
+	if err := os.Remove(segmentFilePath); err != nil {
+		return fmt.Errorf("removing the WAL segment file %q: %w", segmentFilePath, err)
+	}
+	indexFilePath := IndexFilePath(segmentFilePath)
+	if err := os.Remove(indexFilePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("removing the WAL segment index file %q: %w", indexFilePath, err)
+	}
+	return nil
+}
+
+// RemoveBefore removes all segment files from the directory which only contain entries with a sequence number below
+// the given sequence number. This allows reclaiming disk space for entries which are not needed anymore, like after
+// taking a checkpoint. The newest segment file and the segment file containing the sequence number are never removed.
+// Segment files are removed from oldest to newest. This keeps the remaining segment files without gaps, when removing
+// fails in between.
+//
+// RemoveBefore is safe to call while a writer is appending to the segment files in the same directory, because the
+// writer only ever appends to the newest segment file.
+func RemoveBefore(directory string, sequenceNumber uint64) error {
+	segments, err := GetSegments(directory)
+	if err != nil {
+		return err
+	}
+
+	// Every segment ends where the next segment starts. The newest segment is never removed.
+	for i := 0; i+1 < len(segments) && segments[i+1] <= sequenceNumber; i++ {
+		if err := RemoveSegment(directory, segments[i]); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -1,8 +1,63 @@
 package segment_test
 
 import (
+	"os"
+	"path"
+
 	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+	"github.com/backbone81/write-ahead-log/internal/segment"
 )
 
 var _ = Describe("Utility", func() {
+	var dir string
+
+	BeforeEach(func() {
+		var err error
+		dir, err = os.MkdirTemp("", "test-utility-*")
+		Expect(err).ToNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		Expect(os.RemoveAll(dir)).To(Succeed())
+	})
+
+	createSegments := func(firstSequenceNumbers ...uint64) {
+		for _, firstSequenceNumber := range firstSequenceNumbers {
+			writer, err := segment.CreateSegment(dir, firstSequenceNumber, segment.CreateSegmentConfig{
+				EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+				EntryChecksumType:   encoding.DefaultEntryChecksumType,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Close()).To(Succeed())
+		}
+	}
+
+	It("should ignore files which are no segment files", func() {
+		createSegments(0, 10)
+		Expect(os.WriteFile(path.Join(dir, segment.SegmentFileName(20)+".new"), nil, 0o600)).To(Succeed())
+		Expect(os.WriteFile(path.Join(dir, "foo.wal"), nil, 0o600)).To(Succeed())
+		Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 10}))
+	})
+
+	DescribeTable("Removing segments before a sequence number",
+		func(sequenceNumber uint64, wantSegments []uint64) {
+			createSegments(0, 10, 20, 30)
+			Expect(segment.RemoveBefore(dir, sequenceNumber)).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal(wantSegments))
+
+			// Every remaining segment file keeps its index file, the index files of removed segment files are gone.
+			Expect(os.ReadDir(dir)).To(HaveLen(2 * len(wantSegments)))
+			for _, firstSequenceNumber := range wantSegments {
+				Expect(segment.IndexFilePath(path.Join(dir, segment.SegmentFileName(firstSequenceNumber)))).To(BeAnExistingFile())
+			}
+		},
+		Entry("When the sequence number is in the first segment", uint64(5), []uint64{0, 10, 20, 30}),
+		Entry("When the sequence number is the first of a segment", uint64(20), []uint64{20, 30}),
+		Entry("When the sequence number is in a middle segment", uint64(25), []uint64{20, 30}),
+		Entry("When the sequence number is in the newest segment", uint64(35), []uint64{30}),
+		Entry("When the sequence number is beyond the newest segment", uint64(100), []uint64{30}),
+	)
 })
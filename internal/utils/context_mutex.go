@@ -0,0 +1,55 @@
+package utils
+
+import (
+	"context"
+	"sync"
+)
+
+// ContextMutex is a mutual exclusion lock which allows giving up on acquiring the lock when a context is done. This is
+// not possible with sync.Mutex. The zero value is an unlocked mutex.
+type ContextMutex struct {
+	initOnce sync.Once
+
+	// The semaphore holds a single element while the mutex is locked.
+	semaphore chan struct{}
+}
+
+// ContextMutex implements sync.Locker.
+var _ sync.Locker = (*ContextMutex)(nil)
+
+// Lock locks the mutex. If the mutex is already locked, Lock blocks until the mutex is available.
+func (m *ContextMutex) Lock() {
+	m.init()
+	m.semaphore <- struct{}{}
+}
+
+// LockContext locks the mutex. If the mutex is already locked, LockContext blocks until the mutex is available or the
+// context is done. The error of the context is returned when the mutex could not be locked.
+func (m *ContextMutex) LockContext(ctx context.Context) error {
+	m.init()
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	select {
+	case m.semaphore <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Unlock unlocks the mutex. It panics if the mutex is not locked.
+func (m *ContextMutex) Unlock() {
+	m.init()
+	select {
+	case <-m.semaphore:
+	default:
+		panic("unlock of unlocked mutex")
+	}
+}
+
+func (m *ContextMutex) init() {
+	m.initOnce.Do(func() {
+		m.semaphore = make(chan struct{}, 1)
+	})
+}
@@ -0,0 +1,36 @@
+package utils_test
+
+import (
+	"context"
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/utils"
+)
+
+var _ = Describe("ContextMutex", func() {
+	It("should lock and unlock", func() {
+		var mutex utils.ContextMutex
+		mutex.Lock()
+		mutex.Unlock()
+		Expect(mutex.LockContext(context.Background())).To(Succeed())
+		mutex.Unlock()
+	})
+
+	It("should give up locking when the context is done", func() {
+		var mutex utils.ContextMutex
+		mutex.Lock()
+		defer mutex.Unlock()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		Expect(mutex.LockContext(ctx)).To(MatchError(context.DeadlineExceeded))
+	})
+
+	It("should panic when unlocking an unlocked mutex", func() {
+		var mutex utils.ContextMutex
+		Expect(mutex.Unlock).To(Panic())
+	})
+})
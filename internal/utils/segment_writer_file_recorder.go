@@ -2,6 +2,8 @@ package utils
 
 import (
 	"bytes"
+	"errors"
+	"io"
 )
 
 // SegmentWriterFileRecorder provides a stub for a segment file which records what is written to it in memory. It allows
@@ -25,3 +27,12 @@ func (s *SegmentWriterFileRecorder) Name() string {
 func (s *SegmentWriterFileRecorder) Truncate(offset int64) error {
 	return nil
 }
+
+// Seek only supports seeking backwards from the start. All recorded data after the offset is discarded.
+func (s *SegmentWriterFileRecorder) Seek(offset int64, whence int) (int64, error) {
+	if whence != io.SeekStart || offset < 0 || offset > int64(s.Len()) {
+		return 0, errors.New("unsupported seek")
+	}
+	s.Buffer.Truncate(int(offset))
+	return offset, nil
+}
@@ -0,0 +1,55 @@
+package wal
+
+import (
+	"errors"
+
+	"github.com/backbone81/write-ahead-log/internal/segment"
+)
+
+// CorruptionPolicy describes how a reader deals with entries which can not be read.
+type CorruptionPolicy int
+
+const (
+	// CorruptionPolicyStrict stops reading at the first entry which can not be read. Entries which can not be read in
+	// a sealed segment are reported as segment.ErrEntryCorrupt, as only the newest segment can have a torn entry at the
+	// end.
+	CorruptionPolicyStrict CorruptionPolicy = iota
+
+	// CorruptionPolicySkipCorrupt skips corrupt entries and continues with the next entry recorded in the segment
+	// index, or with the next segment when there is no such entry. A warning with the skipped sequence numbers is
+	// logged. A torn entry at the end of the newest segment can not be skipped and is reported like with
+	// CorruptionPolicyStrict.
+	CorruptionPolicySkipCorrupt
+
+	// CorruptionPolicyTruncateTail removes a torn entry together with all data after it, when it is found in the
+	// newest segment. This allows appending to the write-ahead log after a crash in the middle of writing an entry.
+	// Entries which can not be read in sealed segments are reported like with CorruptionPolicyStrict. When valid entries
+	// follow the entry which can not be read, nothing is removed and the entry is reported as segment.ErrEntryCorrupt.
+	CorruptionPolicyTruncateTail
+)
+
+// String returns a string representation of the corruption policy.
+func (c CorruptionPolicy) String() string {
+	switch c {
+	case CorruptionPolicyStrict:
+		return "strict"
+	case CorruptionPolicySkipCorrupt:
+		return "skip-corrupt"
+	case CorruptionPolicyTruncateTail:
+		return "truncate-tail"
+	default:
+		return "unknown"
+	}
+}
+
+// corruptEntryError turns the error of a segment reader into an error with segment.ErrEntryCorrupt as the kind of
+// failure. This is needed for entries in sealed segments, which can never be torn or not yet written.
+func corruptEntryError(err error) error {
+	var entryErr *segment.EntryError
+	if !errors.As(err, &entryErr) {
+		return errors.Join(segment.ErrEntryNone, segment.ErrEntryCorrupt, err)
+	}
+	corruptErr := *entryErr
+	corruptErr.Kind = segment.ErrEntryCorrupt
+	return &corruptErr
+}
@@ -18,12 +18,16 @@ func IsInitialized(directory string) (bool, error) {
 func Init(directory string, options ...WriterOption) error {
 	// We use a writer here, to reuse its options. But we do not work with that writer.
 	newWriter := Writer{
-		preAllocationSize:   segment.DefaultPreAllocationSize,
-		maxSegmentSize:      segment.DefaultPreAllocationSize,
-		entryLengthEncoding: encoding.DefaultEntryLengthEncoding,
-		entryChecksumType:   encoding.DefaultEntryChecksumType,
-		syncPolicy:          NewSyncPolicyImmediate(),
-		rolloverCallback:    DefaultRolloverCallback,
+		preAllocationSize:    segment.DefaultPreAllocationSize,
+		maxSegmentSize:       segment.DefaultPreAllocationSize,
+		entryLengthEncoding:  encoding.DefaultEntryLengthEncoding,
+		entryChecksumType:    encoding.DefaultEntryChecksumType,
+		compression:          encoding.DefaultCompression,
+		compressionThreshold: segment.DefaultCompressionThreshold,
+		syncPolicy:           NewSyncPolicyImmediate(),
+		rolloverCallback:     DefaultRolloverCallback,
+		retentionPolicy:      NewRetentionPolicyNone(),
+		retentionFloor:       DefaultRetentionFloor,
 	}
 	for _, option := range options {
 		option(&newWriter)
@@ -32,6 +36,9 @@ func Init(directory string, options ...WriterOption) error {
 		PreAllocationSize:   newWriter.preAllocationSize,
 		EntryLengthEncoding: newWriter.entryLengthEncoding,
 		EntryChecksumType:   newWriter.entryChecksumType,
+		Compression:         newWriter.compression,
+		Metadata:            newWriter.metadata,
+		EntryChecksumKey:    newWriter.entryChecksumKey,
 	})
 	if err != nil {
 		return err
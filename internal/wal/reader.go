@@ -1,9 +1,13 @@
 package wal
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
+	"iter"
+	"log"
+	"slices"
 	"time"
 
 	"github.com/backbone81/write-ahead-log/internal/encoding"
@@ -29,11 +33,136 @@ type Reader struct {
 
 	// The directory the reader is reading segments from.
 	directory string
+
+	// The interval in which NextWait checks for new entries.
+	pollInterval time.Duration
+
+	// Reports if the segment files are opened read-only.
+	readOnly bool
+
+	// Reports if sealed segment files are mapped into memory.
+	mapped bool
+
+	// The size in bytes of the buffer for reading segment files. Reads are not buffered when this is zero.
+	readBufferSize int
+
+	// Reports if the next chunk of the segment file is read ahead while the current chunk is decoded.
+	readAhead bool
+
+	// Describes how entries which can not be read are dealt with.
+	corruptionPolicy CorruptionPolicy
+
+	// Reports if control records are returned instead of skipped.
+	controlRecords bool
+
+	// The key for segment files with the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain.
+	entryChecksumKey []byte
+
+	// The sync tracker of the writer appending to the segment files in the same process. Only entries reported as
+	// flushed by it are returned. This is nil when the writer is looked up by liveSyncTrackerKey instead.
+	syncTracker *SyncTracker
+
+	// The key for looking up the writer appending to the segment files in the same process.
+	liveSyncTrackerKey string
+
+	// The segment the sealed state was last checked for, the result and the time of the check. A sealed segment never
+	// becomes unsealed again, so only segments which were not sealed are checked again, at most once per poll interval.
+	sealedSegment   uint64
+	sealed          bool
+	sealedCheckedAt time.Time
+}
+
+// ReaderOption describes the function signature which all reader options need to implement.
+type ReaderOption func(r *Reader)
+
+// DefaultPollInterval is the interval in which NextWait checks for new entries by default.
+const DefaultPollInterval = 10 * time.Millisecond
+
+// WithPollInterval overwrites the default interval in which NextWait checks for new entries. It only applies when the
+// writer is running in a different process. Otherwise, NextWait is woken up by the writer.
+func WithPollInterval(pollInterval time.Duration) ReaderOption {
+	return func(r *Reader) {
+		r.pollInterval = max(pollInterval, time.Millisecond)
+	}
+}
+
+// WithReadOnly opens all segment files read-only. This allows reading the write-ahead log on read-only file systems or
+// without write permissions. A read-only reader can not be converted into a writer.
+func WithReadOnly() ReaderOption {
+	return func(r *Reader) {
+		r.readOnly = true
+	}
+}
+
+// WithMmap maps sealed segment files into memory instead of reading them. Entries are decoded from the mapping without
+// any read calls, and the data of the entries points into the mapping without being copied. This speeds up replaying
+// big write-ahead logs considerably. The newest segment file is read as usual, because it might still be written to.
+// As with every reader, the data of an entry is only valid until the next call to Next.
+func WithMmap() ReaderOption {
+	return func(r *Reader) {
+		r.mapped = true
+	}
+}
+
+// WithReadBufferSize overwrites the default size of the buffer for reading segment files. Reading a segment file in
+// chunks of that size needs far fewer read calls than reading every part of an entry separately. A size of zero
+// disables the buffer.
+func WithReadBufferSize(size int) ReaderOption {
+	return func(r *Reader) {
+		r.readBufferSize = max(size, 0)
+	}
+}
+
+// WithReadAhead reads the next chunk of the segment file on a separate Go routine while the entries of the current
+// chunk are decoded. This overlaps waiting for the disk with decoding entries. It has no effect when the read buffer is
+// disabled.
+func WithReadAhead() ReaderOption {
+	return func(r *Reader) {
+		r.readAhead = true
+	}
+}
+
+// WithCorruptionPolicy overwrites the default corruption policy CorruptionPolicyStrict. Using
+// CorruptionPolicyTruncateTail together with WithReadOnly fails with segment.ErrReadOnly when a torn entry is found.
+func WithCorruptionPolicy(corruptionPolicy CorruptionPolicy) ReaderOption {
+	return func(r *Reader) {
+		r.corruptionPolicy = corruptionPolicy
+	}
+}
+
+// WithControlRecords returns control records like checkpoints written with Writer.AppendCheckpoint from Next instead of
+// skipping them. Check Value().Type.IsControl() for telling them apart from entries. The Entries iterators always skip
+// control records.
+func WithControlRecords() ReaderOption {
+	return func(r *Reader) {
+		r.controlRecords = true
+	}
+}
+
+// WithReaderEntryChecksumKey provides the key for reading segment files with the entry checksum type
+// encoding.EntryChecksumTypeHmacSha256Chain. Without the key, reading entries of such segment files fails with
+// encoding.ErrEntryChecksumKeyRequired. The key is used for the writer returned by ToWriter as well.
+func WithReaderEntryChecksumKey(key []byte) ReaderOption {
+	return func(r *Reader) {
+		r.entryChecksumKey = key
+	}
+}
+
+// withSyncTracker only returns entries reported as flushed by the sync tracker. This is needed for reading from segment
+// files which are still written to by a writer in the same process.
+func withSyncTracker(syncTracker *SyncTracker) ReaderOption {
+	return func(r *Reader) {
+		r.syncTracker = syncTracker
+	}
 }
 
 // NewReader creates a new Reader starting at the given sequence number. It will find the segment the sequence number
 // belongs to and read all entries up until the requested sequence number.
-func NewReader(directory string, sequenceNumber uint64) (*Reader, error) {
+// While a writer in the same process appends to the directory, the reader only returns entries which that writer
+// flushed to stable storage, the same way as a reader created with Writer.NewReader. Entries which are written but not
+// yet flushed are reported as not written. This is not possible for a writer in a different process, so the reader
+// returns all entries written by it.
+func NewReader(directory string, sequenceNumber uint64, options ...ReaderOption) (*Reader, error) {
 	// Identify which segment contains the requested sequence number. The segment itself is the first sequence number
 	// in the segment.
 	segmentNumber, err := segment.SegmentFromSequenceNumber(directory, sequenceNumber)
@@ -41,17 +170,28 @@ func NewReader(directory string, sequenceNumber uint64) (*Reader, error) {
 		return nil, err
 	}
 
+	newReader := Reader{
+		directory:          directory,
+		pollInterval:       DefaultPollInterval,
+		readBufferSize:     segment.DefaultReadBufferSize,
+		liveSyncTrackerKey: liveSyncTrackerKey(directory),
+	}
+	for _, option := range options {
+		option(&newReader)
+	}
+
 	// Create a segment reader for the given segment and make sure that the segment file name actually matches to the
 	// first sequence number as documented in the segment header.
-	segmentReader, err := segment.OpenSegment(directory, segmentNumber)
+	segmentReader, err := newReader.openSegment(segmentNumber)
 	if err != nil {
 		return nil, err
 	}
+	newReader.segmentReader = segmentReader
 
-	// Move the WAL reader forward until we have reached the desired sequence number.
-	newReader := Reader{
-		segmentReader: segmentReader,
-		directory:     directory,
+	// Jump close to the desired sequence number with the help of the segment index and move the WAL reader forward
+	// until we have reached the desired sequence number.
+	if err := segmentReader.SeekIndex(sequenceNumber); err != nil {
+		return nil, errors.Join(err, segmentReader.Close())
 	}
 	for newReader.NextSequenceNumber() < sequenceNumber && newReader.Next() {
 		// Skip entry until we have reached our target sequence number.
@@ -68,6 +208,44 @@ func NewReader(directory string, sequenceNumber uint64) (*Reader, error) {
 	return &newReader, nil
 }
 
+// Entries returns an iterator over all entries with a sequence number from "from" up to but excluding "to". The
+// iteration ends at "to" or at the end of the written entries, whichever comes first. The segment files are closed when
+// the iteration ends, also when breaking out of the loop early. Control records are skipped. The data of an entry is
+// only valid until the next iteration.
+// The returned function reports the error which ended the iteration before reaching "to". It returns nil when the
+// iteration reached "to" or the end of the written entries.
+func Entries(directory string, from uint64, to uint64, options ...ReaderOption) (iter.Seq2[uint64, []byte], func() error) {
+	var err error
+	entries := func(yield func(uint64, []byte) bool) {
+		err = nil
+		if from >= to {
+			return
+		}
+
+		reader, newErr := NewReader(directory, from, options...)
+		if newErr != nil {
+			err = newErr
+			return
+		}
+		defer func() {
+			if closeErr := reader.Close(); closeErr != nil {
+				err = errors.Join(err, closeErr)
+			}
+		}()
+
+		readerEntries, readerErr := reader.Entries(to)
+		for sequenceNumber, data := range readerEntries {
+			if !yield(sequenceNumber, data) {
+				return
+			}
+		}
+		err = readerErr()
+	}
+	return entries, func() error {
+		return err
+	}
+}
+
 // FilePath returns the file path of the file this reader is reading from.
 func (r *Reader) FilePath() string {
 	return r.segmentReader.FilePath()
@@ -90,7 +268,42 @@ func (r *Reader) NextSequenceNumber() uint64 {
 
 // Next reports if an entry has been successfully read. When it returns true, Err() returns nil and Value() contains
 // valid data. When it returns false, Err() returns an error. Value() contains invalid data in that situation.
+// While a writer in the same process appends to the segment files, entries which were not yet flushed to stable storage
+// are reported as not yet written. Control records are skipped unless the reader was created with WithControlRecords.
 func (r *Reader) Next() bool {
+	for r.nextEntry() {
+		if r.controlRecords || !r.Value().Type.IsControl() {
+			return true
+		}
+	}
+	return false
+}
+
+// nextEntry reads the next entry or control record.
+func (r *Reader) nextEntry() bool {
+	syncTracker := r.liveSyncTracker()
+	if syncTracker == nil {
+		return r.next()
+	}
+
+	if r.NextSequenceNumber() >= syncTracker.SyncedSequenceNumber() {
+		r.err = errors.Join(segment.ErrEntryNone, segment.ErrEntryNotWritten)
+		return false
+	}
+	if r.next() {
+		return true
+	}
+
+	// The entry was flushed to stable storage, but we could not read it. The segment file might have grown beyond the
+	// pre-allocated size or was truncated on rollover since we read its size.
+	if err := r.segmentReader.UpdateFileSize(); err != nil {
+		r.err = fmt.Errorf("reading the size of the segment file: %w", err)
+		return false
+	}
+	return r.next()
+}
+
+func (r *Reader) next() bool {
 	// Forward to our active segment reader first.
 	next := r.segmentReader.Next()
 	r.err = r.segmentReader.Err()
@@ -101,11 +314,15 @@ func (r *Reader) Next() bool {
 	}
 
 	if !errors.Is(r.err, io.EOF) {
-		// Any error other than end of file results in an early exit. In case of end of file, we want to replace
-		// the current segment reader with the next segment reader.
-		return false
+		// Any error other than end of file is handled according to the corruption policy. In case of end of file, we
+		// want to replace the current segment reader with the next segment reader.
+		return r.entryFailed()
 	}
+	return r.nextSegment()
+}
 
+// nextSegment moves on to the next segment after reaching the end of the current segment file.
+func (r *Reader) nextSegment() bool {
 	// When we did hit EOF, we only want to move to the next segment when we did in fact read at least one entry.
 	// Otherwise, we are caught in an endless loop, where we try to re-open the same file again and again.
 	if r.segmentReader.NextSequenceNumber() == r.segmentReader.Header().FirstSequenceNumber {
@@ -113,11 +330,23 @@ func (r *Reader) Next() bool {
 		return false
 	}
 
-	nextSegmentReader, err := segment.OpenSegment(r.directory, r.segmentReader.NextSequenceNumber())
+	nextSegmentReader, err := r.openSegment(r.segmentReader.NextSequenceNumber())
 	if err != nil {
+		if errors.Is(err, segment.ErrSegmentNotFound) {
+			// The next segment is usually not yet created. But when the current segment is already followed by a newer
+			// segment, the next segment was removed, like by a retention policy.
+			if sealed, sealedErr := r.segmentSealed(false); sealedErr == nil && sealed {
+				r.err = err
+				return false
+			}
+		}
 		// We keep the old error in r.err because this wil still signal that no entry could be read.
 		return false
 	}
+	if err := verifyChainLink(r.segmentReader.Digest(), nextSegmentReader); err != nil {
+		r.err = errors.Join(err, nextSegmentReader.Close())
+		return false
+	}
 
 	// We are ready to move on to the next segment reader, so close our active one.
 	if err := r.segmentReader.Close(); err != nil {
@@ -129,7 +358,279 @@ func (r *Reader) Next() bool {
 	// Replace our current segment reader with the next segment reader and call recursively into Next() to deal with
 	// potential errors with the next segment reader there.
 	r.segmentReader = nextSegmentReader
-	return r.Next()
+	return r.next()
+}
+
+// Entries returns an iterator over all entries from the current position up to but excluding the sequence number "to".
+// The iteration ends at "to" or at the end of the written entries, whichever comes first. In contrast to the Entries
+// function, the reader is not closed when the iteration ends. Control records are skipped. The data of an entry is only
+// valid until the next iteration.
+// The returned function reports the error which ended the iteration before reaching "to". It returns nil when the
+// iteration reached "to" or the end of the written entries.
+func (r *Reader) Entries(to uint64) (iter.Seq2[uint64, []byte], func() error) {
+	var err error
+	entries := func(yield func(uint64, []byte) bool) {
+		err = nil
+		for r.NextSequenceNumber() < to {
+			// We can not use Next, as it might skip a control record and read the entry at "to".
+			if !r.nextEntry() {
+				if !errors.Is(r.Err(), segment.ErrEntryNotWritten) {
+					err = r.Err()
+				}
+				return
+			}
+			if r.Value().Type.IsControl() {
+				continue
+			}
+			if !yield(r.Value().SequenceNumber, r.Value().Data) {
+				return
+			}
+		}
+	}
+	return entries, func() error {
+		return err
+	}
+}
+
+// NextWait behaves like Next, but blocks when there are no more entries until the next entry was appended and flushed
+// to stable storage. This allows following the write-ahead log while it is written to, including rollovers into new
+// segments. It returns false when the context is done or an entry is torn or corrupt. Err() returns the error of the
+// context in the first case.
+// When the writer is running in the same process, NextWait is woken up by the writer as soon as more entries were
+// flushed. Otherwise, NextWait checks for new entries in the interval configured with WithPollInterval. As there is no
+// way to know what a writer in a different process flushed, entries are returned as soon as they are written in that
+// case. A torn entry in the newest segment is treated like an entry which is not yet written, because the writer might
+// still be in the middle of writing it.
+func (r *Reader) NextWait(ctx context.Context) bool {
+	for {
+		// We need to get the channel before calling Next. Otherwise, we could miss a flush happening in between.
+		var syncChanged <-chan struct{}
+		if syncTracker := r.liveSyncTracker(); syncTracker != nil {
+			syncChanged = syncTracker.syncChanged()
+		}
+		if r.Next() {
+			return true
+		}
+		if !r.mightBeWritten() {
+			return false
+		}
+		if err := r.wait(ctx, syncChanged); err != nil {
+			r.err = err
+			return false
+		}
+
+		// The segment file might have grown when it was not pre-allocated or the pre-allocated size was exceeded.
+		if err := r.segmentReader.UpdateFileSize(); err != nil {
+			r.err = fmt.Errorf("reading the size of the segment file: %w", err)
+			return false
+		}
+	}
+}
+
+// wait blocks until the writer in the same process closes the given channel, or for one poll interval when the channel
+// is nil. It returns the error of the context when the context is done before.
+func (r *Reader) wait(ctx context.Context, syncChanged <-chan struct{}) error {
+	if syncChanged != nil {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-syncChanged:
+			return nil
+		}
+	}
+
+	timer := time.NewTimer(r.pollInterval)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// liveSyncTracker returns the sync tracker of the writer appending to the segment files in the same process. It returns
+// nil when there is no such writer.
+func (r *Reader) liveSyncTracker() *SyncTracker {
+	if r.syncTracker != nil {
+		return r.syncTracker
+	}
+	return lookupSyncTracker(r.liveSyncTrackerKey)
+}
+
+// entryFailed deals with an entry which could not be read for another reason than reaching the end of the segment file.
+// Entries in sealed segments are reported as corrupt, and the corruption policy decides how to continue.
+func (r *Reader) entryFailed() bool {
+	sealed, err := r.segmentSealed(false)
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return false
+	}
+	if sealed {
+		// A writer in a different process might have truncated the segment file on rollover after we read its size. We
+		// read the size again and retry, before we report the entry as corrupt.
+		if err := r.segmentReader.UpdateFileSize(); err != nil {
+			r.err = errors.Join(r.err, fmt.Errorf("reading the size of the segment file: %w", err))
+			return false
+		}
+		if r.segmentReader.Next() {
+			r.err = nil
+			return true
+		}
+		r.err = r.segmentReader.Err()
+		if errors.Is(r.err, io.EOF) {
+			return r.nextSegment()
+		}
+		r.err = corruptEntryError(r.err)
+	}
+
+	switch r.corruptionPolicy {
+	case CorruptionPolicySkipCorrupt:
+		return r.skipCorrupt(sealed)
+	case CorruptionPolicyTruncateTail:
+		if sealed || !errors.Is(r.err, segment.ErrEntryTorn) {
+			return false
+		}
+		// Truncating removes data, so we do not rely on a sealed state which might be outdated.
+		if sealed, err := r.segmentSealed(true); err != nil || sealed {
+			r.err = errors.Join(corruptEntryError(r.err), err)
+			return false
+		}
+		if err := r.segmentReader.TruncateTail(); errors.Is(err, segment.ErrEntryCorrupt) {
+			// Valid entries follow the torn entry. Truncating would remove them as well.
+			r.err = corruptEntryError(r.err)
+			return false
+		} else if err != nil {
+			r.err = errors.Join(r.err, fmt.Errorf("truncating the torn entry: %w", err))
+			return false
+		}
+		log.Printf(
+			"WARNING: Truncated the torn entry with sequence number %d and all data after it in the WAL segment file %q.\n",
+			r.segmentReader.NextSequenceNumber(),
+			r.segmentReader.FilePath(),
+		)
+		return r.next()
+	default:
+		return false
+	}
+}
+
+// skipCorrupt moves past the entry which could not be read, either to the next entry recorded in the segment index or
+// to the next segment.
+func (r *Reader) skipCorrupt(sealed bool) bool {
+	from := r.segmentReader.NextSequenceNumber()
+	skipped, err := r.segmentReader.SkipCorrupt()
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return false
+	}
+	if skipped {
+		log.Printf(
+			"WARNING: Skipped the corrupt entries with sequence numbers %d to %d in the WAL segment file %q: %v\n",
+			from,
+			r.segmentReader.NextSequenceNumber()-1,
+			r.segmentReader.FilePath(),
+			r.err,
+		)
+		return r.next()
+	}
+	if !sealed {
+		// There is no way to tell the torn entry at the end of the newest segment apart from a corrupt one.
+		return false
+	}
+
+	segments, err := segment.GetSegments(r.directory)
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return false
+	}
+	index, _ := slices.BinarySearch(segments, r.segmentReader.Header().FirstSequenceNumber+1)
+	if index == len(segments) {
+		// The newer segment was removed in the meantime.
+		return false
+	}
+	nextSegmentReader, err := r.openSegment(segments[index])
+	if err != nil {
+		r.err = errors.Join(r.err, err)
+		return false
+	}
+	if err := r.segmentReader.Close(); err != nil {
+		_ = nextSegmentReader.Close()
+		r.err = fmt.Errorf("closing the segment reader: %w", err)
+		return false
+	}
+	log.Printf(
+		"WARNING: Skipped the corrupt entries with sequence numbers %d to %d at the end of the WAL segment file %q: %v\n",
+		from,
+		segments[index]-1,
+		r.segmentReader.FilePath(),
+		r.err,
+	)
+	r.segmentReader = nextSegmentReader
+	return r.next()
+}
+
+// segmentSealed reports if the current segment is followed by a newer segment. Sealed segments are not written to
+// anymore. Listing the segment files is expensive, so the last result is used for up to one poll interval unless fresh
+// is set.
+func (r *Reader) segmentSealed(fresh bool) (bool, error) {
+	currentSegment := r.segmentReader.Header().FirstSequenceNumber
+	if !r.sealedCheckedAt.IsZero() && r.sealedSegment == currentSegment &&
+		(r.sealed || (!fresh && time.Since(r.sealedCheckedAt) < r.pollInterval)) {
+		return r.sealed, nil
+	}
+
+	segments, err := segment.GetSegments(r.directory)
+	if err != nil {
+		return false, err
+	}
+	r.sealedSegment = currentSegment
+	r.sealed = len(segments) > 0 && segments[len(segments)-1] != currentSegment
+	r.sealedCheckedAt = time.Now()
+	return r.sealed, nil
+}
+
+// mightBeWritten reports if the last call to Next() failed because the next entry was not yet written.
+func (r *Reader) mightBeWritten() bool {
+	if errors.Is(r.err, segment.ErrEntryNotWritten) {
+		return true
+	}
+	if !errors.Is(r.err, segment.ErrEntryTorn) {
+		return false
+	}
+
+	// A torn entry is only expected in the newest segment. In every other segment it indicates a corrupt entry.
+	sealed, err := r.segmentSealed(false)
+	return err == nil && !sealed
+}
+
+// openSegment opens the segment with the given first sequence number for reading.
+func (r *Reader) openSegment(firstSequenceNumber uint64) (*segment.SegmentReader, error) {
+	segmentReader, err := r.openSegmentFile(firstSequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	segmentReader.SetReadBuffer(r.readBufferSize, r.readAhead)
+	segmentReader.SetEntryChecksumKey(r.entryChecksumKey)
+	return segmentReader, nil
+}
+
+// openSegmentFile opens the segment file with the given first sequence number in the mode configured for the reader.
+func (r *Reader) openSegmentFile(firstSequenceNumber uint64) (*segment.SegmentReader, error) {
+	if r.mapped {
+		segments, err := segment.GetSegments(r.directory)
+		if err != nil {
+			return nil, err
+		}
+		if len(segments) > 0 && segments[len(segments)-1] != firstSequenceNumber {
+			// Only segments followed by a newer segment are sealed and can be mapped.
+			return segment.OpenSegmentMapped(r.directory, firstSequenceNumber)
+		}
+	}
+	if r.readOnly {
+		return segment.OpenSegmentReadOnly(r.directory, firstSequenceNumber)
+	}
+	return segment.OpenSegment(r.directory, firstSequenceNumber)
 }
 
 // Value returns the last entry read from the segment file. The values are only valid after the first call to Next()
@@ -145,31 +646,52 @@ func (r *Reader) Err() error {
 
 // ToWriter returns a writer to append entries to the write-ahead log. This is the only way to create a writer, because
 // we can only know if we have reached the end of the segment, when we read all elements from it. Creating a writer
-// will fail, when not all entries were read.
+// will fail, when not all entries were read. Returns segment.ErrReadOnly for a reader created with WithReadOnly.
 // The reader must not be used any more after a call to this function.
 func (r *Reader) ToWriter(options ...WriterOption) (*Writer, error) {
 	newWriter := Writer{
-		preAllocationSize:   segment.DefaultPreAllocationSize,
-		maxSegmentSize:      segment.DefaultPreAllocationSize,
-		entryLengthEncoding: r.segmentReader.Header().EntryLengthEncoding,
-		entryChecksumType:   r.segmentReader.Header().EntryChecksumType,
-		rolloverCallback:    DefaultRolloverCallback,
-		syncPolicy:          NewSyncPolicyGrouped(10 * time.Millisecond),
+		preAllocationSize:    segment.DefaultPreAllocationSize,
+		maxSegmentSize:       segment.DefaultPreAllocationSize,
+		entryLengthEncoding:  r.segmentReader.Header().EntryLengthEncoding,
+		entryChecksumType:    r.segmentReader.Header().EntryChecksumType,
+		entryChecksumKey:     r.entryChecksumKey,
+		compression:          r.segmentReader.Header().Metadata.Compression,
+		compressionThreshold: segment.DefaultCompressionThreshold,
+		rolloverCallback:     DefaultRolloverCallback,
+		retentionPolicy:      NewRetentionPolicyNone(),
+		retentionFloor:       DefaultRetentionFloor,
+		syncPolicy:           NewSyncPolicyGrouped(10 * time.Millisecond),
 	}
 	for _, option := range options {
 		option(&newWriter)
 	}
+	entryCompressor, err := segment.NewEntryCompressor(newWriter.compression, newWriter.compressionThreshold)
+	if err != nil {
+		return nil, err
+	}
+	newWriter.entryCompressors.New = func() any {
+		// The compression was checked above, so this cannot fail.
+		entryCompressor, _ := segment.NewEntryCompressor(newWriter.compression, newWriter.compressionThreshold)
+		return entryCompressor
+	}
+	newWriter.entryCompressors.Put(entryCompressor)
 
+	r.segmentReader.SetEntryChecksumKey(newWriter.entryChecksumKey)
 	newSegmentWriter, err := r.segmentReader.ToWriter()
 	if err != nil {
 		return nil, err
 	}
 
 	newWriter.segmentWriter = newSegmentWriter
+	newWriter.segmentWriter.SetCompressionThreshold(newWriter.compressionThreshold)
+	newWriter.syncTracker = NewSyncTracker(newSegmentWriter.NextSequenceNumber())
 
-	if err := newWriter.syncPolicy.Startup(newWriter.segmentWriter); err != nil {
+	if err := newWriter.syncPolicy.Startup(newWriter.segmentWriter, newWriter.syncTracker); err != nil {
 		return nil, err
 	}
+	newWriter.liveSyncTrackerKey = r.liveSyncTrackerKey
+	registerSyncTracker(newWriter.liveSyncTrackerKey, newWriter.syncTracker)
+	newWriter.startRetention()
 	return &newWriter, nil
 }
 
@@ -0,0 +1,416 @@
+package wal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"iter"
+	"log"
+	"math"
+	"os"
+	"runtime"
+	"slices"
+	"sync"
+
+	"github.com/backbone81/write-ahead-log/internal/segment"
+)
+
+// replayChunkSize is the minimum number of bytes of a sealed segment file a replay worker decodes at once. Chunks start
+// at entries recorded in the index of the segment file, so they are usually a bit bigger than that. The last chunk of a
+// segment file ends at the end of the segment file and can be smaller.
+const replayChunkSize = 16 * segment.IndexInterval
+
+// replayJob describes a chunk of a sealed segment which is decoded by a replay worker.
+type replayJob struct {
+	// The position of the segment in the list of sealed segments.
+	segmentIndex int
+
+	// The position of the first entry of the chunk.
+	start segment.IndexRecord
+
+	// The sequence number following the last entry of the chunk. This is math.MaxUint64 for the last chunk of a
+	// segment, which ends at the end of the segment file.
+	end uint64
+
+	// Reports if this is the first chunk of the segment which is replayed.
+	first bool
+
+	// The decoded chunk is delivered on this channel. It is buffered, so that workers never block on delivering it.
+	result chan decodedChunk
+}
+
+// last reports if this is the last chunk of the segment.
+func (j replayJob) last() bool {
+	return j.end == math.MaxUint64
+}
+
+// decodedChunk is a chunk of a sealed segment whose entries were decoded and verified by a replay worker.
+type decodedChunk struct {
+	// The segment reader the entries were read with. The data of the entries points into its mapping, so it must only
+	// be closed after all entries were yielded. This is nil when the segment could not be opened.
+	segmentReader *segment.SegmentReader
+
+	// The entries of the chunk in sequence order.
+	entries []segment.SegmentReaderValue
+
+	// The error which ended reading the chunk. This is nil when the whole chunk was read.
+	err error
+
+	// Reports if the remaining entries of the segment were skipped, because they are corrupt. The replay continues with
+	// the next segment in that case.
+	skipped bool
+}
+
+// Replay returns an iterator over all entries starting at the sequence number "from" up to the end of the written
+// entries. In contrast to Entries, the sealed segments are decoded and their checksums are verified concurrently on the
+// given number of worker Go routines, while the entries are still yielded strictly in sequence order. This speeds up
+// replaying the write-ahead log on recovery when verifying the checksums is the bottleneck. A number of workers of zero
+// or less uses one worker per CPU. Control records are skipped like with Entries.
+//
+// The sealed segments are mapped into memory and decoded in chunks of about 1 MiB, which start at the entries recorded
+// in the segment index. At most twice the number of workers chunks are decoded ahead of the chunk the entries are
+// yielded from. The memory needed is therefore about two times the number of workers times 1 MiB of mapped segment
+// files, plus the decompressed data of compressed entries in those chunks. A sealed segment without a valid index file
+// is decoded as a single chunk and needs memory for the whole segment file. The newest segment is read like with
+// Entries after all sealed segments, because it might still be written to. The data of an entry is only valid until the
+// next iteration.
+// All options apply to the newest segment. For the sealed segments, WithReaderEntryChecksumKey and WithCorruptionPolicy
+// apply as well. WithMmap, WithReadBufferSize and WithReadAhead do not, because the sealed segments are always mapped
+// into memory. WithControlRecords has no effect, as control records are always skipped.
+// When a retention policy removes segment files before they are decoded, the iteration ends with
+// segment.ErrSegmentNotFound. Use WithRetentionFloor for keeping the segment files until the replay is done.
+// The returned function reports the error which ended the iteration. It returns nil when the iteration reached the end
+// of the written entries or was stopped early.
+func Replay(directory string, from uint64, workers int, options ...ReaderOption) (iter.Seq2[uint64, []byte], func() error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	var err error
+	entries := func(yield func(uint64, []byte) bool) {
+		err = replay(directory, from, workers, options, yield)
+	}
+	return entries, func() error {
+		return err
+	}
+}
+
+func replay(directory string, from uint64, workers int, options []ReaderOption, yield func(uint64, []byte) bool) error {
+	segments, err := segment.GetSegments(directory)
+	if err != nil {
+		return err
+	}
+	firstSegment, err := segment.SegmentFromSequenceNumber(directory, from)
+	if err != nil {
+		return err
+	}
+	index, _ := slices.BinarySearch(segments, firstSegment)
+
+	var readerOptions Reader
+	for _, option := range options {
+		option(&readerOptions)
+	}
+	nextSequenceNumber, lastDigest, ok, err := replaySealedSegments(directory, from, workers, &readerOptions, segments[index:], yield)
+	if err != nil || !ok {
+		return err
+	}
+
+	reader, err := NewReader(directory, max(from, nextSequenceNumber), options...)
+	if err != nil {
+		return err
+	}
+	if err := verifyChainLink(lastDigest, reader.segmentReader); err != nil {
+		return errors.Join(err, reader.Close())
+	}
+	readerEntries, readerErr := reader.Entries(math.MaxUint64)
+	for sequenceNumber, data := range readerEntries {
+		if !yield(sequenceNumber, data) {
+			break
+		}
+	}
+	return errors.Join(readerErr(), reader.Close())
+}
+
+// replaySealedSegments yields the entries of all segments except the newest one, which are decoded concurrently in
+// chunks. It returns the sequence number following the last entry of the sealed segments, the digest of that entry for
+// the chained entry checksum types, and if the newest segment should be replayed afterward.
+//
+//nolint:cyclop // Splitting up the coordination of the workers would make it harder to follow.
+func replaySealedSegments(
+	directory string,
+	from uint64,
+	workers int,
+	readerOptions *Reader,
+	segments []uint64,
+	yield func(uint64, []byte) bool,
+) (uint64, []byte, bool, error) {
+	sealedSegments := segments[:len(segments)-1]
+	if len(sealedSegments) == 0 {
+		return from, nil, true, nil
+	}
+
+	// The dispatcher hands out the chunks to the workers in order. Every chunk is queued for being yielded before it is
+	// handed out, so that we can consume the chunks in order no matter which worker finishes first. The capacity of
+	// the queue limits the number of chunks decoded ahead.
+	jobs := make(chan replayJob)
+	pending := make(chan replayJob, 2*workers)
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(pending)
+		defer close(jobs)
+		dispatchReplayJobs(directory, from, sealedSegments, jobs, pending, done)
+	}()
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for job := range jobs {
+				job.result <- decodeChunk(directory, sealedSegments[job.segmentIndex], job, from, readerOptions)
+			}
+		}()
+	}
+
+	// Release all chunks which were decoded but not consumed, when we stop early.
+	defer func() {
+		close(done)
+		wg.Wait()
+		for job := range pending {
+			select {
+			case result := <-job.result:
+				if result.segmentReader != nil {
+					_ = result.segmentReader.Close()
+				}
+			default:
+				// The chunk was never handed out to a worker.
+			}
+		}
+	}()
+
+	nextSequenceNumber := from
+	var lastDigest []byte
+	for job := range pending {
+		result := <-job.result
+		if result.segmentReader != nil {
+			if job.first {
+				if err := verifyChainLink(lastDigest, result.segmentReader); err != nil {
+					return 0, nil, false, errors.Join(err, result.segmentReader.Close())
+				}
+			}
+			if job.last() {
+				lastDigest = result.segmentReader.Digest()
+			}
+		}
+		ok, err := yieldDecodedChunk(result, yield)
+		if err != nil || !ok {
+			return 0, nil, false, err
+		}
+		if !job.last() {
+			continue
+		}
+
+		nextSegment := segments[job.segmentIndex+1]
+		if result.skipped {
+			log.Printf(
+				"WARNING: Skipped the corrupt entries with sequence numbers %d to %d at the end of the WAL segment file %q.\n",
+				result.segmentReader.NextSequenceNumber(),
+				nextSegment-1,
+				result.segmentReader.FilePath(),
+			)
+			nextSequenceNumber = nextSegment
+			// The chain is broken by the skipped entries, so there is nothing to check for the next segment.
+			lastDigest = nil
+			continue
+		}
+		nextSequenceNumber = result.segmentReader.NextSequenceNumber()
+		if nextSegment != nextSequenceNumber {
+			return 0, nil, false, fmt.Errorf("expected the segment %d to follow, but found segment %d", nextSequenceNumber, nextSegment)
+		}
+	}
+	return nextSequenceNumber, lastDigest, true, nil
+}
+
+// dispatchReplayJobs splits the sealed segments into chunks and hands them out to the workers. Every chunk is put into
+// the pending queue before it is handed out. When the chunks of a segment can not be determined, a chunk with the error
+// is put into the pending queue and nothing is handed out after it.
+func dispatchReplayJobs(
+	directory string,
+	from uint64,
+	sealedSegments []uint64,
+	jobs chan<- replayJob,
+	pending chan<- replayJob,
+	done <-chan struct{},
+) {
+	for i, firstSequenceNumber := range sealedSegments {
+		starts, err := chunkStarts(directory, firstSequenceNumber, from)
+		if err != nil {
+			job := replayJob{
+				segmentIndex: i,
+				result:       make(chan decodedChunk, 1),
+			}
+			job.result <- decodedChunk{err: err}
+			select {
+			case pending <- job:
+			case <-done:
+			}
+			return
+		}
+
+		for chunk, start := range starts {
+			job := replayJob{
+				segmentIndex: i,
+				start:        start,
+				end:          math.MaxUint64,
+				first:        chunk == 0,
+				result:       make(chan decodedChunk, 1),
+			}
+			if chunk+1 < len(starts) {
+				job.end = starts[chunk+1].SequenceNumber
+			}
+			select {
+			case pending <- job:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- job:
+			case <-done:
+				return
+			}
+		}
+	}
+}
+
+// chunkStarts returns the positions of the entries the chunks of the sealed segment start at. Chunks which only contain
+// entries with a sequence number below "from" are left out. A segment without a valid index file is a single chunk.
+func chunkStarts(directory string, firstSequenceNumber uint64, from uint64) ([]segment.IndexRecord, error) {
+	segmentReader, err := segment.OpenSegmentReadOnly(directory, firstSequenceNumber)
+	if err != nil {
+		return nil, err
+	}
+	starts := []segment.IndexRecord{
+		{
+			SequenceNumber: segmentReader.NextSequenceNumber(),
+			Offset:         segmentReader.Offset(),
+		},
+	}
+	records, err := segment.ReadIndex(segment.IndexFilePath(segmentReader.FilePath()), segmentReader.Header().Size())
+	if err := errors.Join(err, segmentReader.Close()); err != nil {
+		if errors.Is(err, os.ErrNotExist) || errors.Is(err, segment.ErrIndexInvalid) {
+			return starts, nil
+		}
+		return nil, err
+	}
+
+	for _, record := range records {
+		if record.Offset-starts[len(starts)-1].Offset < replayChunkSize {
+			continue
+		}
+		if record.SequenceNumber <= from {
+			// The chunk in front of the record only contains entries we are not interested in.
+			starts[len(starts)-1] = record
+			continue
+		}
+		starts = append(starts, record)
+	}
+	return starts, nil
+}
+
+// yieldDecodedChunk yields the entries of the decoded chunk and closes its segment reader afterward. It reports if the
+// replay should continue with the next chunk.
+func yieldDecodedChunk(result decodedChunk, yield func(uint64, []byte) bool) (bool, error) {
+	if result.segmentReader == nil {
+		return false, result.err
+	}
+
+	ok := true
+	for _, entry := range result.entries {
+		if !yield(entry.SequenceNumber, entry.Data) {
+			ok = false
+			break
+		}
+	}
+	if err := result.segmentReader.Close(); err != nil {
+		return false, fmt.Errorf("closing the segment reader: %w", err)
+	}
+	if !ok {
+		return false, nil
+	}
+	return result.err == nil, result.err
+}
+
+// decodeChunk reads and verifies all entries of the chunk of the sealed segment with a sequence number of at least
+// "from". Entries which can not be read are reported as corrupt, or skipped with CorruptionPolicySkipCorrupt.
+//
+//nolint:cyclop // Dealing with corrupt entries needs a few more branches.
+func decodeChunk(directory string, firstSequenceNumber uint64, job replayJob, from uint64, readerOptions *Reader) decodedChunk {
+	segmentReader, err := segment.OpenSegmentMapped(directory, firstSequenceNumber)
+	if err != nil {
+		return decodedChunk{err: err}
+	}
+	segmentReader.SetEntryChecksumKey(readerOptions.entryChecksumKey)
+	if job.start.Offset != segmentReader.Offset() {
+		if err := segmentReader.Seek(job.start); err != nil {
+			return decodedChunk{err: errors.Join(err, segmentReader.Close())}
+		}
+	}
+	if err := segmentReader.SeekIndex(from); err != nil {
+		return decodedChunk{err: errors.Join(err, segmentReader.Close())}
+	}
+
+	result := decodedChunk{
+		segmentReader: segmentReader,
+	}
+	for {
+		for segmentReader.NextSequenceNumber() < job.end && segmentReader.Next() {
+			if segmentReader.Value().SequenceNumber >= from && !segmentReader.Value().Type.IsControl() {
+				// The data points into the mapping of the segment file, so it stays valid until the segment reader is
+				// closed.
+				result.entries = append(result.entries, segmentReader.Value())
+			}
+		}
+		if !job.last() && segmentReader.NextSequenceNumber() >= job.end {
+			if segmentReader.NextSequenceNumber() != job.end {
+				result.err = fmt.Errorf(
+					"expected to reach sequence number %d but instead reached %d: %w",
+					job.end,
+					segmentReader.NextSequenceNumber(),
+					corruptEntryError(nil),
+				)
+			}
+			return result
+		}
+		if job.last() && errors.Is(segmentReader.Err(), io.EOF) {
+			return result
+		}
+		result.err = corruptEntryError(segmentReader.Err())
+		if readerOptions.corruptionPolicy != CorruptionPolicySkipCorrupt {
+			return result
+		}
+
+		skippedFrom := segmentReader.NextSequenceNumber()
+		skipped, err := segmentReader.SkipCorrupt()
+		if err != nil {
+			result.err = errors.Join(result.err, err)
+			return result
+		}
+		if !skipped {
+			if job.last() {
+				// The replay continues with the next segment.
+				result.err = nil
+				result.skipped = true
+			}
+			return result
+		}
+		log.Printf(
+			"WARNING: Skipped the corrupt entries with sequence numbers %d to %d in the WAL segment file %q: %v\n",
+			skippedFrom,
+			segmentReader.NextSequenceNumber()-1,
+			segmentReader.FilePath(),
+			result.err,
+		)
+		result.err = nil
+	}
+}
@@ -0,0 +1,67 @@
+package wal
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"time"
+
+	"github.com/backbone81/write-ahead-log/internal/segment"
+)
+
+// RetentionPolicy is the interface every retention policy needs to implement. The writer consults the retention policy
+// after every rollover and removes the segment files which are not needed anymore.
+type RetentionPolicy interface {
+	// RetainFrom returns the lowest sequence number which needs to be kept. All segments which only contain entries
+	// with lower sequence numbers are removed. The segments are sorted from oldest to newest. The last segment is the
+	// one the writer is currently writing to. It is never removed, regardless of the sequence number returned.
+	RetainFrom(segments []SegmentInfo) uint64
+
+	// String returns the name of the retention policy. This is useful for logging or error messages.
+	String() string
+}
+
+// SegmentInfo describes a segment file for the retention policy to decide on.
+type SegmentInfo struct {
+	// FirstSequenceNumber is the sequence number of the first entry in the segment.
+	FirstSequenceNumber uint64
+
+	// Size is the size of the segment file in bytes. When the writer consults the retention policy, the size of the
+	// segment it is currently writing to is the number of bytes written so far, without the pre-allocated space.
+	Size int64
+
+	// ModTime is the time the segment file was last written to.
+	ModTime time.Time
+}
+
+// RetentionFloor returns the lowest sequence number which is still needed by the caller. Retention policies never
+// remove segments containing entries at or above that sequence number.
+type RetentionFloor func() uint64
+
+// DefaultRetentionFloor provides a retention floor which does not restrict the retention policy.
+var DefaultRetentionFloor RetentionFloor = func() uint64 {
+	return ^uint64(0)
+}
+
+// GetSegmentInfos returns the information about all segments in the directory sorted from oldest to newest.
+func GetSegmentInfos(directory string) ([]SegmentInfo, error) {
+	segments, err := segment.GetSegments(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]SegmentInfo, 0, len(segments))
+	for _, firstSequenceNumber := range segments {
+		segmentFilePath := path.Join(directory, segment.SegmentFileName(firstSequenceNumber))
+		fileInfo, err := os.Stat(segmentFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("reading the WAL segment file %q: %w", segmentFilePath, err)
+		}
+		result = append(result, SegmentInfo{
+			FirstSequenceNumber: firstSequenceNumber,
+			Size:                fileInfo.Size(),
+			ModTime:             fileInfo.ModTime(),
+		})
+	}
+	return result, nil
+}
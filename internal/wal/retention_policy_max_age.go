@@ -0,0 +1,38 @@
+package wal
+
+import "time"
+
+// RetentionPolicyMaxAge is removing segments which were last written to longer ago than some duration.
+type RetentionPolicyMaxAge struct {
+	maxAge time.Duration
+}
+
+// RetentionPolicyMaxAge implements RetentionPolicy.
+var _ RetentionPolicy = (*RetentionPolicyMaxAge)(nil)
+
+// NewRetentionPolicyMaxAge creates a new RetentionPolicyMaxAge.
+func NewRetentionPolicyMaxAge(maxAge time.Duration) *RetentionPolicyMaxAge {
+	return &RetentionPolicyMaxAge{
+		maxAge: max(maxAge, 0),
+	}
+}
+
+func (r *RetentionPolicyMaxAge) RetainFrom(segments []SegmentInfo) uint64 {
+	if len(segments) == 0 {
+		return 0
+	}
+
+	// As segments are written one after the other, we keep everything starting with the first segment which is young
+	// enough.
+	oldestModTime := time.Now().Add(-r.maxAge)
+	for _, segmentInfo := range segments[:len(segments)-1] {
+		if !segmentInfo.ModTime.Before(oldestModTime) {
+			return segmentInfo.FirstSequenceNumber
+		}
+	}
+	return segments[len(segments)-1].FirstSequenceNumber
+}
+
+func (r *RetentionPolicyMaxAge) String() string {
+	return "max-age"
+}
@@ -0,0 +1,38 @@
+package wal
+
+// RetentionPolicyMaxBytes is removing the oldest segments when the total size of all segments exceeds some number of
+// bytes. The segment currently written to is always kept, even when it exceeds the number of bytes on its own. Only the
+// bytes written to that segment count, its pre-allocated space does not.
+type RetentionPolicyMaxBytes struct {
+	maxBytes int64
+}
+
+// RetentionPolicyMaxBytes implements RetentionPolicy.
+var _ RetentionPolicy = (*RetentionPolicyMaxBytes)(nil)
+
+// NewRetentionPolicyMaxBytes creates a new RetentionPolicyMaxBytes.
+func NewRetentionPolicyMaxBytes(maxBytes int64) *RetentionPolicyMaxBytes {
+	return &RetentionPolicyMaxBytes{
+		maxBytes: max(maxBytes, 0),
+	}
+}
+
+func (r *RetentionPolicyMaxBytes) RetainFrom(segments []SegmentInfo) uint64 {
+	if len(segments) == 0 {
+		return 0
+	}
+
+	// We walk from newest to oldest and keep segments as long as they fit.
+	totalBytes := segments[len(segments)-1].Size
+	for i := len(segments) - 2; i >= 0; i-- {
+		totalBytes += segments[i].Size
+		if totalBytes > r.maxBytes {
+			return segments[i+1].FirstSequenceNumber
+		}
+	}
+	return segments[0].FirstSequenceNumber
+}
+
+func (r *RetentionPolicyMaxBytes) String() string {
+	return "max-bytes"
+}
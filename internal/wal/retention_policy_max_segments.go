@@ -0,0 +1,28 @@
+package wal
+
+// RetentionPolicyMaxSegments is removing the oldest segments when there are more than some number of segments. The
+// segment currently written to is always kept.
+type RetentionPolicyMaxSegments struct {
+	maxSegments int
+}
+
+// RetentionPolicyMaxSegments implements RetentionPolicy.
+var _ RetentionPolicy = (*RetentionPolicyMaxSegments)(nil)
+
+// NewRetentionPolicyMaxSegments creates a new RetentionPolicyMaxSegments.
+func NewRetentionPolicyMaxSegments(maxSegments int) *RetentionPolicyMaxSegments {
+	return &RetentionPolicyMaxSegments{
+		maxSegments: max(maxSegments, 1),
+	}
+}
+
+func (r *RetentionPolicyMaxSegments) RetainFrom(segments []SegmentInfo) uint64 {
+	if len(segments) == 0 {
+		return 0
+	}
+	return segments[max(len(segments)-r.maxSegments, 0)].FirstSequenceNumber
+}
+
+func (r *RetentionPolicyMaxSegments) String() string {
+	return "max-segments"
+}
@@ -0,0 +1,20 @@
+package wal
+
+// RetentionPolicyNone is never removing any segments. This is the default.
+type RetentionPolicyNone struct{}
+
+// RetentionPolicyNone implements RetentionPolicy.
+var _ RetentionPolicy = (*RetentionPolicyNone)(nil)
+
+// NewRetentionPolicyNone creates a new RetentionPolicyNone.
+func NewRetentionPolicyNone() *RetentionPolicyNone {
+	return &RetentionPolicyNone{}
+}
+
+func (r *RetentionPolicyNone) RetainFrom(segments []SegmentInfo) uint64 {
+	return 0
+}
+
+func (r *RetentionPolicyNone) String() string {
+	return "none"
+}
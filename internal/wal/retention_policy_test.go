@@ -0,0 +1,37 @@
+package wal_test
+
+import (
+	"time"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+
+	"github.com/backbone81/write-ahead-log/internal/wal"
+)
+
+var _ = Describe("RetentionPolicy", func() {
+	now := time.Now()
+	segments := []wal.SegmentInfo{
+		{FirstSequenceNumber: 0, Size: 100, ModTime: now.Add(-3 * time.Hour)},
+		{FirstSequenceNumber: 10, Size: 100, ModTime: now.Add(-2 * time.Hour)},
+		{FirstSequenceNumber: 20, Size: 100, ModTime: now.Add(-1 * time.Hour)},
+		{FirstSequenceNumber: 30, Size: 100, ModTime: now},
+	}
+
+	DescribeTable("Deciding which segments to retain",
+		func(retentionPolicy wal.RetentionPolicy, want uint64) {
+			Expect(retentionPolicy.RetainFrom(segments)).To(Equal(want))
+			Expect(retentionPolicy.RetainFrom(nil)).To(Equal(uint64(0)))
+		},
+		Entry("When using none", wal.NewRetentionPolicyNone(), uint64(0)),
+		Entry("When using max bytes with all segments fitting", wal.NewRetentionPolicyMaxBytes(400), uint64(0)),
+		Entry("When using max bytes with some segments fitting", wal.NewRetentionPolicyMaxBytes(250), uint64(20)),
+		Entry("When using max bytes with no segments fitting", wal.NewRetentionPolicyMaxBytes(0), uint64(30)),
+		Entry("When using max age with all segments young enough", wal.NewRetentionPolicyMaxAge(4*time.Hour), uint64(0)),
+		Entry("When using max age with some segments young enough", wal.NewRetentionPolicyMaxAge(90*time.Minute), uint64(20)),
+		Entry("When using max age with no segments young enough", wal.NewRetentionPolicyMaxAge(0), uint64(30)),
+		Entry("When using max segments with all segments fitting", wal.NewRetentionPolicyMaxSegments(10), uint64(0)),
+		Entry("When using max segments with some segments fitting", wal.NewRetentionPolicyMaxSegments(2), uint64(20)),
+		Entry("When using max segments with no segments fitting", wal.NewRetentionPolicyMaxSegments(0), uint64(30)),
+	)
+})
@@ -0,0 +1,339 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"slices"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+	"github.com/backbone81/write-ahead-log/internal/segment"
+	"github.com/backbone81/write-ahead-log/internal/utils"
+)
+
+// ReverseReader provides functionality to read the write-ahead log backward, from the newest entry to the oldest entry.
+// It abstracts away the fact that the write-ahead log is split into multiple segments.
+//
+// Entries can only be decoded forward. The reverse reader therefore reads all entries between two records of the
+// segment index forward and yields them in reverse order afterward. This needs memory for about segment.IndexInterval
+// bytes of entries.
+//
+// Instances of this struct are NOT safe for concurrent use. Either use it on a single Go routine or provide your own
+// external synchronization.
+type ReverseReader struct {
+	noCopy utils.NoCopy
+
+	// The options the reader was created with. Segment files are opened the same way as with NewReader.
+	readerOptions *Reader
+
+	// The sequence number of the newest entry to yield. Newer entries are skipped.
+	lastSequenceNumber uint64
+
+	// The first sequence numbers of the segments not yet read, oldest first.
+	segments []uint64
+
+	// The segment reader of the segment we are currently reading windows from. This is nil when the next segment
+	// needs to be opened.
+	segmentReader *segment.SegmentReader
+
+	// The sequence number the newest segment not yet read ends at. This is math.MaxUint64 for the newest segment, as
+	// its end is not known before reading it.
+	segmentEnd uint64
+
+	// The positions the windows of the current segment start at which are not yet read, oldest first.
+	windowStarts []segment.IndexRecord
+
+	// The sequence number the next window to read ends at. This is math.MaxUint64 when the end is not known.
+	windowEnd uint64
+
+	// The entries of the current window in the order they are stored in the segment file. Entries are yielded from the
+	// end of the slice and removed afterward.
+	window []segment.SegmentReaderValue
+
+	// The data of all entries of the current window. The data of the entries in window points into it.
+	windowData []byte
+
+	// The offsets into windowData where the data of the entries in window ends.
+	windowDataEnds []int
+
+	// The value the reader returns. Only contains useful data if err is nil.
+	value segment.SegmentReaderValue
+
+	// The error for the last operation. If this is nil, the value can be used.
+	err error
+}
+
+// NewReverseReader creates a new ReverseReader starting at the given sequence number. The first call to Next yields the
+// entry with that sequence number, every other call yields the entry before. Use math.MaxUint64 to start at the newest
+// entry. The same options as for NewReader can be used. WithPollInterval has no effect, as there is nothing to wait
+// for.
+// The corruption policy applies to the entries of a window which can not be read. CorruptionPolicySkipCorrupt skips
+// the remaining entries of the window, including a torn entry at the end of the newest segment.
+// CorruptionPolicyTruncateTail removes a torn entry at the end of the newest segment.
+// While a writer in the same process appends to the directory, the reader starts at the newest entry flushed by that
+// writer at the latest, the same way as a Reader only returns the entries flushed by it.
+func NewReverseReader(directory string, sequenceNumber uint64, options ...ReaderOption) (*ReverseReader, error) {
+	readerOptions := &Reader{
+		directory:          directory,
+		readBufferSize:     segment.DefaultReadBufferSize,
+		liveSyncTrackerKey: liveSyncTrackerKey(directory),
+	}
+	for _, option := range options {
+		option(readerOptions)
+	}
+
+	if syncTracker := readerOptions.liveSyncTracker(); syncTracker != nil {
+		syncedSequenceNumber := syncTracker.SyncedSequenceNumber()
+		if syncedSequenceNumber == 0 {
+			// The writer did not flush any entry yet, so there is nothing to yield.
+			return &ReverseReader{
+				readerOptions: readerOptions,
+			}, nil
+		}
+		sequenceNumber = min(sequenceNumber, syncedSequenceNumber-1)
+	}
+
+	segments, err := segment.GetSegments(directory)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only segments which start at or before the sequence number can contain entries we are interested in.
+	index, exact := slices.BinarySearch(segments, sequenceNumber)
+	if exact {
+		index++
+	}
+	if index == 0 {
+		return nil, fmt.Errorf("no segment available for sequence number %d", sequenceNumber)
+	}
+
+	return &ReverseReader{
+		readerOptions:      readerOptions,
+		lastSequenceNumber: sequenceNumber,
+		segments:           segments[:index],
+		segmentEnd:         math.MaxUint64,
+		windowData:         make([]byte, 0, 4*1024),
+	}, nil
+}
+
+// Next reports if an entry has been successfully read. When it returns true, Err() returns nil and Value() contains
+// valid data. When it returns false, there are either no older entries, or Err() returns the error which occurred.
+func (r *ReverseReader) Next() bool {
+	for len(r.window) == 0 {
+		if !r.readWindow() {
+			return false
+		}
+	}
+
+	r.value = r.window[len(r.window)-1]
+	r.window = r.window[:len(r.window)-1]
+	return true
+}
+
+// readWindow reads the entries of the next older window. It returns false when there are no more windows or an error
+// occurred.
+func (r *ReverseReader) readWindow() bool {
+	for len(r.windowStarts) == 0 {
+		if r.segmentReader != nil {
+			if err := r.segmentReader.Close(); err != nil {
+				r.err = fmt.Errorf("closing the segment reader: %w", err)
+				return false
+			}
+			r.segmentReader = nil
+		}
+		if len(r.segments) == 0 {
+			return false
+		}
+		if err := r.openSegment(); err != nil {
+			r.err = err
+			return false
+		}
+	}
+
+	windowStart := r.windowStarts[len(r.windowStarts)-1]
+	r.windowStarts = r.windowStarts[:len(r.windowStarts)-1]
+	windowEnd := r.windowEnd
+	r.windowEnd = windowStart.SequenceNumber
+	if windowStart.SequenceNumber > r.lastSequenceNumber {
+		// All entries of this window are newer than the entries we are interested in.
+		return true
+	}
+
+	if err := r.segmentReader.Seek(windowStart); err != nil {
+		r.err = err
+		return false
+	}
+	r.windowData = r.windowData[:0]
+	r.windowDataEnds = r.windowDataEnds[:0]
+	for r.segmentReader.NextSequenceNumber() < windowEnd && r.segmentReader.NextSequenceNumber() <= r.lastSequenceNumber {
+		if !r.segmentReader.Next() {
+			if windowEnd == math.MaxUint64 && endOfEntries(r.segmentReader.Err()) {
+				// We reached the end of the newest segment.
+				break
+			}
+			if !r.entryFailed(windowEnd) {
+				return false
+			}
+			break
+		}
+		if !r.readerOptions.controlRecords && r.segmentReader.Value().Type.IsControl() {
+			continue
+		}
+		r.window = append(r.window, segment.SegmentReaderValue{
+			SequenceNumber: r.segmentReader.Value().SequenceNumber,
+			Type:           r.segmentReader.Value().Type,
+			Flags:          r.segmentReader.Value().Flags,
+			Headers:        cloneHeaders(r.segmentReader.Value().Headers),
+		})
+		r.windowData = append(r.windowData, r.segmentReader.Value().Data...)
+		r.windowDataEnds = append(r.windowDataEnds, len(r.windowData))
+	}
+
+	// The data of the entries can only point into windowData after all data was appended, because appending might
+	// move windowData to a new memory location.
+	dataStart := 0
+	for i, dataEnd := range r.windowDataEnds {
+		r.window[i].Data = r.windowData[dataStart:dataEnd]
+		dataStart = dataEnd
+	}
+	return true
+}
+
+// endOfEntries reports if the error signals the end of the written entries.
+func endOfEntries(err error) bool {
+	return errors.Is(err, io.EOF) || errors.Is(err, segment.ErrEntryNotWritten)
+}
+
+// entryFailed deals with an entry of the window ending at windowEnd which could not be read. The corruption policy
+// decides if the entries read so far are yielded. It returns false when the reader needs to stop.
+func (r *ReverseReader) entryFailed(windowEnd uint64) bool {
+	err := r.segmentReader.Err()
+	newest := windowEnd == math.MaxUint64
+	if !newest || !errors.Is(err, segment.ErrEntryTorn) {
+		// Only the newest segment can end with a torn entry. In every other place it indicates a corrupt entry.
+		err = corruptEntryError(err)
+	}
+
+	switch r.readerOptions.corruptionPolicy {
+	case CorruptionPolicySkipCorrupt:
+		skippedTo := "the end"
+		if !newest {
+			skippedTo = fmt.Sprintf("sequence number %d", windowEnd-1)
+		}
+		log.Printf(
+			"WARNING: Skipped the corrupt entries from sequence number %d to %s in the WAL segment file %q: %v\n",
+			r.segmentReader.NextSequenceNumber(),
+			skippedTo,
+			r.segmentReader.FilePath(),
+			err,
+		)
+		return true
+	case CorruptionPolicyTruncateTail:
+		if newest && errors.Is(err, segment.ErrEntryTorn) {
+			if truncateErr := r.truncateTail(); truncateErr != nil {
+				r.err = errors.Join(err, truncateErr)
+				return false
+			}
+			return true
+		}
+	default:
+	}
+
+	if newest {
+		r.err = err
+		return false
+	}
+	r.err = fmt.Errorf(
+		"expected to reach sequence number %d but instead reached %d: %w",
+		windowEnd,
+		r.segmentReader.NextSequenceNumber(),
+		err,
+	)
+	return false
+}
+
+// truncateTail removes the torn entry at the end of the newest segment. The segment must still be the newest segment,
+// as a writer might have rolled over in the meantime.
+func (r *ReverseReader) truncateTail() error {
+	segments, err := segment.GetSegments(r.readerOptions.directory)
+	if err != nil {
+		return err
+	}
+	if len(segments) == 0 || segments[len(segments)-1] != r.segmentReader.Header().FirstSequenceNumber {
+		return segment.ErrEntryCorrupt
+	}
+	if err := r.segmentReader.TruncateTail(); err != nil {
+		return fmt.Errorf("truncating the torn entry: %w", err)
+	}
+	log.Printf(
+		"WARNING: Truncated the torn entry with sequence number %d and all data after it in the WAL segment file %q.\n",
+		r.segmentReader.NextSequenceNumber(),
+		r.segmentReader.FilePath(),
+	)
+	return nil
+}
+
+// cloneHeaders returns a copy of the headers which does not point into the buffers of the segment reader anymore.
+func cloneHeaders(headers []encoding.EntryHeader) []encoding.EntryHeader {
+	if headers == nil {
+		return nil
+	}
+	clonedHeaders := make([]encoding.EntryHeader, len(headers))
+	for i, header := range headers {
+		clonedHeaders[i] = encoding.EntryHeader{
+			Key:   header.Key,
+			Value: bytes.Clone(header.Value),
+		}
+	}
+	return clonedHeaders
+}
+
+// openSegment opens the newest segment not yet read and prepares its windows.
+func (r *ReverseReader) openSegment() error {
+	firstSequenceNumber := r.segments[len(r.segments)-1]
+	r.segments = r.segments[:len(r.segments)-1]
+
+	segmentReader, err := r.readerOptions.openSegment(firstSequenceNumber)
+	if err != nil {
+		return err
+	}
+
+	records, err := segmentReader.Index()
+	if err != nil {
+		return errors.Join(err, segmentReader.Close())
+	}
+
+	// The first window starts right after the segment header.
+	r.windowStarts = append(r.windowStarts[:0], segment.IndexRecord{
+		SequenceNumber: segmentReader.NextSequenceNumber(),
+		Offset:         segmentReader.Offset(),
+	})
+	r.windowStarts = append(r.windowStarts, records...)
+	r.windowEnd = r.segmentEnd
+	r.segmentEnd = firstSequenceNumber
+	r.segmentReader = segmentReader
+	return nil
+}
+
+// Value returns the last entry read. The values are only valid after the first call to Next() and while Err() is nil.
+// The data is only valid until the next call to Next().
+func (r *ReverseReader) Value() segment.SegmentReaderValue {
+	return r.value
+}
+
+// Err returns the error for the last call to Next(). It returns nil when Next() returned false because there are no
+// older entries.
+func (r *ReverseReader) Err() error {
+	return r.err
+}
+
+// Close closes the underlying segment reader.
+func (r *ReverseReader) Close() error {
+	if r.segmentReader == nil {
+		return nil
+	}
+	return r.segmentReader.Close()
+}
@@ -8,13 +8,30 @@ type SyncPolicy interface {
 	// go routines.
 	// The segmentWriter is the segment which the sync policy is expected to flush. The policy is expected to store the
 	// segmentWriter internally for later use.
-	Startup(segmentWriter *segment.SegmentWriter) error
+	// The syncTracker is the tracker the policy needs to report every flush to. The policy is expected to store the
+	// syncTracker internally for later use.
+	Startup(segmentWriter *segment.SegmentWriter, syncTracker *SyncTracker) error
 
 	// EntryAppended is called after every entry has been written to the segment file. The sequence number is the number
 	// of the entry which was written. The policy can decide if it wants to flush immediately or start some timer for
 	// an asynchronous flush.
 	EntryAppended(sequenceNumber uint64) error
 
+	// EntryAppendedAsync is called instead of EntryAppended for entries which were appended asynchronously. In contrast
+	// to EntryAppended, the policy must not block until the entry was flushed. The policy reports the flush to the
+	// sync tracker instead.
+	EntryAppendedAsync(sequenceNumber uint64) error
+
+	// Flush is called when the writer needs all entries with a sequence number below nextSequenceNumber to be flushed
+	// right away, like when the limit of entries not yet flushed is reached. The policy must flush before returning and
+	// report the flush to the sync tracker.
+	Flush(nextSequenceNumber uint64) error
+
+	// Reset is called between Shutdown and Startup when the entries starting at nextSequenceNumber were removed from
+	// the end of the WAL. The sequence numbers are handed out again, so the policy needs to forget about the removed
+	// entries.
+	Reset(nextSequenceNumber uint64)
+
 	// Shutdown is always called before the segment file is closed for writing. The policy should shut down any go
 	// routines it started during Startup.
 	Shutdown() error
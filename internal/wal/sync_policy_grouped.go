@@ -18,14 +18,19 @@ type SyncPolicyGrouped struct {
 
 	syncAfter         time.Duration
 	segmentWriter     *segment.SegmentWriter
+	syncTracker       *SyncTracker
 	syncTimer         *time.Timer
 	shutdown          chan struct{}
 	shutdownWaitGroup sync.WaitGroup
 	backgroundSync    sync.Cond
 
+	// All entries with a sequence number below this one have been appended and are flushed with the next sync.
 	pendingSequenceNumber uint64
-	syncedSequenceNumber  uint64
-	syncTimerActive       bool
+
+	// All entries with a sequence number below this one have been flushed.
+	syncedSequenceNumber uint64
+
+	syncTimerActive bool
 }
 
 // SyncPolicyGrouped implements SyncPolicy.
@@ -37,11 +42,12 @@ func NewSyncPolicyGrouped(syncAfter time.Duration) *SyncPolicyGrouped {
 	}
 }
 
-func (s *SyncPolicyGrouped) Startup(segmentWriter *segment.SegmentWriter) error {
+func (s *SyncPolicyGrouped) Startup(segmentWriter *segment.SegmentWriter, syncTracker *SyncTracker) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.segmentWriter = segmentWriter
+	s.syncTracker = syncTracker
 
 	// Note that we start the sync timer during startup, even though we do not yet have an append pending. This is
 	// necessary to avoid a deadlock during rollover, which is caused by missed appends while the sync policy was
@@ -61,18 +67,37 @@ func (s *SyncPolicyGrouped) EntryAppended(sequenceNumber uint64) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
-	if !s.syncTimerActive {
-		s.syncTimer.Reset(s.syncAfter)
-		s.syncTimerActive = true
-	}
-
-	s.pendingSequenceNumber = max(s.pendingSequenceNumber, sequenceNumber)
-	for s.syncedSequenceNumber < sequenceNumber {
+	s.entryAppended(sequenceNumber)
+	for s.syncedSequenceNumber <= sequenceNumber {
 		s.backgroundSync.Wait()
 	}
 	return nil
 }
 
+func (s *SyncPolicyGrouped) EntryAppendedAsync(sequenceNumber uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.entryAppended(sequenceNumber)
+	return nil
+}
+
+func (s *SyncPolicyGrouped) Flush(nextSequenceNumber uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pendingSequenceNumber = max(s.pendingSequenceNumber, nextSequenceNumber)
+	return s.syncNow()
+}
+
+func (s *SyncPolicyGrouped) Reset(nextSequenceNumber uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pendingSequenceNumber = nextSequenceNumber
+	s.syncedSequenceNumber = nextSequenceNumber
+}
+
 func (s *SyncPolicyGrouped) Shutdown() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -95,6 +120,16 @@ func (s *SyncPolicyGrouped) String() string {
 	return "grouped"
 }
 
+// entryAppended makes sure that the entry is flushed with the next timed sync. The caller must hold the mutex.
+func (s *SyncPolicyGrouped) entryAppended(sequenceNumber uint64) {
+	if !s.syncTimerActive {
+		s.syncTimer.Reset(s.syncAfter)
+		s.syncTimerActive = true
+	}
+
+	s.pendingSequenceNumber = max(s.pendingSequenceNumber, sequenceNumber+1)
+}
+
 func (s *SyncPolicyGrouped) backgroundTask() {
 	defer s.shutdownWaitGroup.Done()
 	for {
@@ -125,9 +160,12 @@ func (s *SyncPolicyGrouped) syncNow() error {
 	}
 
 	if err := s.segmentWriter.Sync(); err != nil {
-		return fmt.Errorf("flushing WAL segment file: %w", err)
+		err = fmt.Errorf("flushing WAL segment file: %w", err)
+		s.syncTracker.Failed(err)
+		return err
 	}
 	s.syncedSequenceNumber = s.pendingSequenceNumber
 	s.backgroundSync.Broadcast()
+	s.syncTracker.Synced(s.syncedSequenceNumber)
 	return nil
 }
@@ -10,6 +10,7 @@ import (
 // data loss because of hardware failure, but it has a negative impact on performance.
 type SyncPolicyImmediate struct {
 	segmentWriter *segment.SegmentWriter
+	syncTracker   *SyncTracker
 }
 
 // SyncPolicyImmediate implements SyncPolicy.
@@ -20,18 +21,34 @@ func NewSyncPolicyImmediate() *SyncPolicyImmediate {
 	return &SyncPolicyImmediate{}
 }
 
-func (s *SyncPolicyImmediate) Startup(segmentWriter *segment.SegmentWriter) error {
+func (s *SyncPolicyImmediate) Startup(segmentWriter *segment.SegmentWriter, syncTracker *SyncTracker) error {
 	s.segmentWriter = segmentWriter
+	s.syncTracker = syncTracker
 	return nil
 }
 
 func (s *SyncPolicyImmediate) EntryAppended(sequenceNumber uint64) error {
+	return s.Flush(sequenceNumber + 1)
+}
+
+// EntryAppendedAsync flushes the entry before returning, the same way EntryAppended does. This means that the future
+// of the entry is already resolved when the asynchronous append returns.
+func (s *SyncPolicyImmediate) EntryAppendedAsync(sequenceNumber uint64) error {
+	return s.EntryAppended(sequenceNumber)
+}
+
+func (s *SyncPolicyImmediate) Flush(nextSequenceNumber uint64) error {
 	if err := s.segmentWriter.Sync(); err != nil {
-		return fmt.Errorf("flushing WAL segment file: %w", err)
+		err = fmt.Errorf("flushing WAL segment file: %w", err)
+		s.syncTracker.Failed(err)
+		return err
 	}
+	s.syncTracker.Synced(nextSequenceNumber)
 	return nil
 }
 
+func (s *SyncPolicyImmediate) Reset(nextSequenceNumber uint64) {}
+
 func (s *SyncPolicyImmediate) Shutdown() error {
 	return nil
 }
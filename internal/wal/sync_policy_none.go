@@ -4,7 +4,9 @@ import "github.com/backbone81/write-ahead-log/internal/segment"
 
 // SyncPolicyNone is never flushing the content of the segment to disk. This might improve performance but increases
 // the risk of data loss in case of a hardware failure.
-type SyncPolicyNone struct{}
+type SyncPolicyNone struct {
+	syncTracker *SyncTracker
+}
 
 // SyncPolicyNone implements SyncPolicy.
 var _ SyncPolicy = (*SyncPolicyNone)(nil)
@@ -14,14 +16,28 @@ func NewSyncPolicyNone() *SyncPolicyNone {
 	return &SyncPolicyNone{}
 }
 
-func (s *SyncPolicyNone) Startup(segmentWriter *segment.SegmentWriter) error {
+func (s *SyncPolicyNone) Startup(segmentWriter *segment.SegmentWriter, syncTracker *SyncTracker) error {
+	s.syncTracker = syncTracker
 	return nil
 }
 
 func (s *SyncPolicyNone) EntryAppended(sequenceNumber uint64) error {
+	// As we never flush, we consider every entry to be as durable as it gets right after it was written.
+	s.syncTracker.Synced(sequenceNumber + 1)
 	return nil
 }
 
+func (s *SyncPolicyNone) EntryAppendedAsync(sequenceNumber uint64) error {
+	return s.EntryAppended(sequenceNumber)
+}
+
+func (s *SyncPolicyNone) Flush(nextSequenceNumber uint64) error {
+	s.syncTracker.Synced(nextSequenceNumber)
+	return nil
+}
+
+func (s *SyncPolicyNone) Reset(nextSequenceNumber uint64) {}
+
 func (s *SyncPolicyNone) Shutdown() error {
 	return nil
 }
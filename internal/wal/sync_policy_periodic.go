@@ -20,11 +20,15 @@ type SyncPolicyPeriodic struct {
 	syncEvery           time.Duration
 
 	segmentWriter     *segment.SegmentWriter
+	syncTracker       *SyncTracker
 	syncTicker        *time.Ticker
 	shutdown          chan struct{}
 	shutdownWaitGroup sync.WaitGroup
 
 	unsyncedEntryCount int
+
+	// All entries with a sequence number below this one have been appended and are flushed with the next sync.
+	pendingSequenceNumber uint64
 }
 
 // SyncPolicyPeriodic implements SyncPolicy.
@@ -38,11 +42,12 @@ func NewSyncPolicyPeriodic(syncAfterEntryCount int, syncEvery time.Duration) *Sy
 	}
 }
 
-func (s *SyncPolicyPeriodic) Startup(segmentWriter *segment.SegmentWriter) error {
+func (s *SyncPolicyPeriodic) Startup(segmentWriter *segment.SegmentWriter, syncTracker *SyncTracker) error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	s.segmentWriter = segmentWriter
+	s.syncTracker = syncTracker
 	s.syncTicker = time.NewTicker(s.syncEvery)
 	s.shutdown = make(chan struct{})
 	s.shutdownWaitGroup.Add(1)
@@ -55,6 +60,7 @@ func (s *SyncPolicyPeriodic) EntryAppended(sequenceNumber uint64) error {
 	defer s.mutex.Unlock()
 
 	s.unsyncedEntryCount++
+	s.pendingSequenceNumber = max(s.pendingSequenceNumber, sequenceNumber+1)
 	if s.unsyncedEntryCount < s.syncAfterEntryCount {
 		return nil
 	}
@@ -65,6 +71,28 @@ func (s *SyncPolicyPeriodic) EntryAppended(sequenceNumber uint64) error {
 	return nil
 }
 
+// EntryAppendedAsync behaves the same way as EntryAppended, because the periodic sync policy does not block until the
+// entry was flushed anyway.
+func (s *SyncPolicyPeriodic) EntryAppendedAsync(sequenceNumber uint64) error {
+	return s.EntryAppended(sequenceNumber)
+}
+
+func (s *SyncPolicyPeriodic) Flush(nextSequenceNumber uint64) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.pendingSequenceNumber = max(s.pendingSequenceNumber, nextSequenceNumber)
+	return s.sync()
+}
+
+func (s *SyncPolicyPeriodic) Reset(nextSequenceNumber uint64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.unsyncedEntryCount = 0
+	s.pendingSequenceNumber = nextSequenceNumber
+}
+
 func (s *SyncPolicyPeriodic) Shutdown() error {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
@@ -113,10 +141,17 @@ func (s *SyncPolicyPeriodic) syncNow() error {
 	if s.unsyncedEntryCount == 0 {
 		return nil
 	}
+	return s.sync()
+}
 
+// sync flushes the segment file regardless of the number of unsynced entries. The caller must hold the mutex.
+func (s *SyncPolicyPeriodic) sync() error {
 	if err := s.segmentWriter.Sync(); err != nil {
-		return fmt.Errorf("flushing WAL segment file: %w", err)
+		err = fmt.Errorf("flushing WAL segment file: %w", err)
+		s.syncTracker.Failed(err)
+		return err
 	}
 	s.unsyncedEntryCount = 0
+	s.syncTracker.Synced(s.pendingSequenceNumber)
 	return nil
 }
@@ -0,0 +1,287 @@
+package wal
+
+import (
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// SyncFuture is handed out for entries which were appended asynchronously. It is resolved as soon as the sync policy
+// has flushed the entry to stable storage.
+type SyncFuture struct {
+	done chan struct{}
+	err  error
+}
+
+// Done returns a channel which is closed when the future is resolved. Call Wait afterward to get the result.
+func (f *SyncFuture) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the future is resolved. It returns nil when the entry was flushed to stable storage, or the error
+// which occurred while flushing.
+func (f *SyncFuture) Wait() error {
+	<-f.done
+	return f.err
+}
+
+func (f *SyncFuture) resolve(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// syncWaiter is a future waiting for a specific sequence number to be flushed.
+type syncWaiter struct {
+	sequenceNumber uint64
+	future         *SyncFuture
+}
+
+// appendedEntries remembers the total number of bytes appended up to some sequence number.
+type appendedEntries struct {
+	nextSequenceNumber uint64
+	totalBytes         int64
+}
+
+// SyncTracker keeps track of the entries which have been flushed to stable storage. Sync policies report every flush
+// to the tracker, which resolves the futures waiting for those entries. The writer reports the appended entries to the
+// tracker, which allows limiting the number of entries and bytes not yet flushed.
+//
+// SyncTracker is safe to use from multiple Go routines concurrently.
+type SyncTracker struct {
+	mutex sync.Mutex
+
+	// All entries with a sequence number below this one have been flushed to stable storage.
+	syncedSequenceNumber uint64
+
+	// The futures which are not yet resolved.
+	waiters []syncWaiter
+
+	// All entries with a sequence number below this one have been appended.
+	appendedSequenceNumber uint64
+
+	// The total number of bytes appended and the total number of bytes flushed to stable storage.
+	appendedBytes int64
+	syncedBytes   int64
+
+	// The entries which were appended but not yet flushed to stable storage, oldest first.
+	unsyncedEntries []appendedEntries
+
+	// Is closed the next time entries are reported as flushed. This is nil as long as nobody is waiting for it.
+	changed chan struct{}
+}
+
+// NewSyncTracker creates a new SyncTracker. All entries with a sequence number below nextSequenceNumber are considered
+// to already be flushed to stable storage.
+func NewSyncTracker(nextSequenceNumber uint64) *SyncTracker {
+	return &SyncTracker{
+		syncedSequenceNumber:   nextSequenceNumber,
+		appendedSequenceNumber: nextSequenceNumber,
+	}
+}
+
+// Synced reports that all entries with a sequence number below nextSequenceNumber have been flushed to stable storage.
+func (t *SyncTracker) Synced(nextSequenceNumber uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if nextSequenceNumber <= t.syncedSequenceNumber {
+		// Sync policies might report flushes out of order when multiple Go routines are involved.
+		return
+	}
+	t.syncedSequenceNumber = nextSequenceNumber
+	t.notify()
+
+	synced := 0
+	for synced < len(t.unsyncedEntries) && t.unsyncedEntries[synced].nextSequenceNumber <= nextSequenceNumber {
+		t.syncedBytes = t.unsyncedEntries[synced].totalBytes
+		synced++
+	}
+	t.unsyncedEntries = t.unsyncedEntries[synced:]
+
+	remaining := t.waiters[:0]
+	for _, waiter := range t.waiters {
+		if waiter.sequenceNumber < nextSequenceNumber {
+			waiter.future.resolve(nil)
+			continue
+		}
+		remaining = append(remaining, waiter)
+	}
+	clear(t.waiters[len(remaining):])
+	t.waiters = remaining
+}
+
+// Failed reports that flushing to stable storage failed. All pending futures are resolved with the given error, as we
+// can not know which of those entries made it to stable storage.
+func (t *SyncTracker) Failed(err error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	for _, waiter := range t.waiters {
+		waiter.future.resolve(err)
+	}
+	clear(t.waiters)
+	t.waiters = t.waiters[:0]
+}
+
+// Reset moves the tracker back to nextSequenceNumber. This is needed when entries were removed from the end of the WAL
+// and the sequence numbers are handed out again. All entries below nextSequenceNumber are considered to be flushed.
+// Pending futures for the removed entries are resolved with ErrTruncated, as those entries will never be flushed.
+func (t *SyncTracker) Reset(nextSequenceNumber uint64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.syncedSequenceNumber = nextSequenceNumber
+	t.appendedSequenceNumber = nextSequenceNumber
+	t.syncedBytes = t.appendedBytes
+	t.unsyncedEntries = nil
+	t.notify()
+
+	for _, waiter := range t.waiters {
+		if waiter.sequenceNumber < nextSequenceNumber {
+			waiter.future.resolve(nil)
+			continue
+		}
+		waiter.future.resolve(ErrTruncated)
+	}
+	clear(t.waiters)
+	t.waiters = t.waiters[:0]
+}
+
+// Appended reports that all entries with a sequence number below nextSequenceNumber have been appended, and that
+// appending them needed the given number of bytes.
+func (t *SyncTracker) Appended(nextSequenceNumber uint64, bytes int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.appendedSequenceNumber = max(t.appendedSequenceNumber, nextSequenceNumber)
+	t.appendedBytes += bytes
+	if nextSequenceNumber <= t.syncedSequenceNumber {
+		// The sync policy already reported the entries as flushed.
+		t.syncedBytes = t.appendedBytes
+		return
+	}
+	t.unsyncedEntries = append(t.unsyncedEntries, appendedEntries{
+		nextSequenceNumber: nextSequenceNumber,
+		totalBytes:         t.appendedBytes,
+	})
+}
+
+// SyncedSequenceNumber returns the sequence number all entries below have been flushed to stable storage.
+func (t *SyncTracker) SyncedSequenceNumber() uint64 {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.syncedSequenceNumber
+}
+
+// Unsynced returns the number of entries and bytes which were reported as appended but not yet flushed to stable
+// storage.
+func (t *SyncTracker) Unsynced() (uint64, int64) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.unsynced()
+}
+
+// unsynced returns the number of entries and bytes not yet flushed. The caller must hold the mutex.
+func (t *SyncTracker) unsynced() (uint64, int64) {
+	if t.appendedSequenceNumber <= t.syncedSequenceNumber {
+		return 0, 0
+	}
+	return t.appendedSequenceNumber - t.syncedSequenceNumber, t.appendedBytes - t.syncedBytes
+}
+
+// Future returns a future which is resolved when the entry with the given sequence number has been flushed to stable
+// storage. The future is already resolved when that happened before.
+func (t *SyncTracker) Future(sequenceNumber uint64) *SyncFuture {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	future := &SyncFuture{
+		done: make(chan struct{}),
+	}
+	if sequenceNumber < t.syncedSequenceNumber {
+		future.resolve(nil)
+		return future
+	}
+	t.waiters = append(t.waiters, syncWaiter{
+		sequenceNumber: sequenceNumber,
+		future:         future,
+	})
+	return future
+}
+
+// syncChanged returns a channel which is closed the next time entries are reported as flushed, the tracker is reset or
+// the writer is closed. Readers use it for waiting for new entries without polling.
+func (t *SyncTracker) syncChanged() <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.changed == nil {
+		t.changed = make(chan struct{})
+	}
+	return t.changed
+}
+
+// wakeReaders wakes up all readers waiting for the channel returned by syncChanged.
+func (t *SyncTracker) wakeReaders() {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.notify()
+}
+
+// notify closes the channel returned by syncChanged. The caller must hold the mutex.
+func (t *SyncTracker) notify() {
+	if t.changed != nil {
+		close(t.changed)
+		t.changed = nil
+	}
+}
+
+// liveSyncTrackers maps the directories of all writers in this process to their sync trackers. This allows readers in
+// the same process to only return entries which were flushed to stable storage, and to wake up when the writer flushed
+// more entries.
+var liveSyncTrackers sync.Map
+
+// liveSyncTrackerCount is the number of entries in liveSyncTrackers. It allows skipping the lookup when there are no
+// writers in this process.
+var liveSyncTrackerCount atomic.Int64
+
+// liveSyncTrackerKey returns the key for the directory in liveSyncTrackers. The same directory can be given with
+// different paths, so we use the absolute path where possible.
+func liveSyncTrackerKey(directory string) string {
+	absoluteDirectory, err := filepath.Abs(directory)
+	if err != nil {
+		return filepath.Clean(directory)
+	}
+	return absoluteDirectory
+}
+
+// registerSyncTracker makes the sync tracker of a writer available to readers of the same directory.
+func registerSyncTracker(key string, syncTracker *SyncTracker) {
+	if _, loaded := liveSyncTrackers.Swap(key, syncTracker); !loaded {
+		liveSyncTrackerCount.Add(1)
+	}
+}
+
+// unregisterSyncTracker removes the sync tracker of a closed writer and wakes up all readers waiting for it.
+func unregisterSyncTracker(key string, syncTracker *SyncTracker) {
+	if liveSyncTrackers.CompareAndDelete(key, syncTracker) {
+		liveSyncTrackerCount.Add(-1)
+	}
+	syncTracker.wakeReaders()
+}
+
+// lookupSyncTracker returns the sync tracker of the writer in this process which appends to the directory. It returns
+// nil when there is no such writer.
+func lookupSyncTracker(key string) *SyncTracker {
+	if liveSyncTrackerCount.Load() == 0 {
+		return nil
+	}
+	syncTracker, ok := liveSyncTrackers.Load(key)
+	if !ok {
+		return nil
+	}
+	return syncTracker.(*SyncTracker) //nolint:forcetypeassert // We only store sync trackers in the map.
+}
@@ -0,0 +1,147 @@
+package wal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/backbone81/write-ahead-log/internal/encoding"
+	"github.com/backbone81/write-ahead-log/internal/segment"
+)
+
+var (
+	ErrEntryChainBroken      = errors.New("the WAL entry chain is broken")
+	ErrEntryChainUnsupported = errors.New("the WAL segment does not use a chained entry checksum type")
+)
+
+// VerifyResult describes the entry chain which was verified by Verify.
+type VerifyResult struct {
+	// FirstSequenceNumber is the sequence number of the first entry of the oldest segment.
+	FirstSequenceNumber uint64
+
+	// NextSequenceNumber is the sequence number the next entry appended to the write-ahead log will receive.
+	NextSequenceNumber uint64
+
+	// FirstDigest is the digest the chain starts from, as stored in the header of the oldest segment. It is all zeros
+	// when the chain starts at the oldest segment. Otherwise, older segments were removed, and FirstDigest should match
+	// the LastDigest of an earlier verification.
+	FirstDigest []byte
+
+	// LastDigest is the digest of the last entry. It covers all entries of the write-ahead log.
+	LastDigest []byte
+}
+
+// Verify reads all entries of the write-ahead log in the given directory and proves that they form an unbroken entry
+// chain. This requires all segments to use a chained entry checksum type. Verify fails with ErrEntryChainBroken when an
+// entry was altered, removed or reordered, or when a segment does not continue the chain of the previous segment. Any
+// entry which cannot be read fails the verification as well, including a torn entry at the end of the newest segment.
+//
+// Removing entries from the end of the newest segment keeps the remaining chain intact. To detect that, store the last
+// digest and the next sequence number outside the write-ahead log and compare them with the result of the next
+// verification. With encoding.EntryChecksumTypeSha256Chain, anybody with write access to the segment files can
+// calculate a new chain, so the stored last digest is the only proof of integrity. With
+// encoding.EntryChecksumTypeHmacSha256Chain, the chain cannot be calculated again without the key given with
+// WithReaderEntryChecksumKey.
+//
+// Segment files are opened like with NewReader, but always read-only. The corruption policy does not apply, as every
+// entry which can not be read fails the verification. WithPollInterval and WithControlRecords have no effect.
+func Verify(directory string, options ...ReaderOption) (VerifyResult, error) {
+	// We use a reader here, to reuse its options. But we do not work with that reader.
+	verifyOptions := Reader{
+		directory:      directory,
+		readBufferSize: segment.DefaultReadBufferSize,
+	}
+	for _, option := range options {
+		option(&verifyOptions)
+	}
+	verifyOptions.readOnly = true
+
+	segments, err := segment.GetSegments(directory)
+	if err != nil {
+		return VerifyResult{}, err
+	}
+	if len(segments) == 0 {
+		return VerifyResult{}, fmt.Errorf("no segment available in %q", directory)
+	}
+
+	result := VerifyResult{
+		FirstSequenceNumber: segments[0],
+		NextSequenceNumber:  segments[0],
+	}
+	for i, segmentNumber := range segments {
+		if segmentNumber != result.NextSequenceNumber {
+			return VerifyResult{}, fmt.Errorf("expected the segment %d to follow, but found segment %d: %w", result.NextSequenceNumber, segmentNumber, ErrEntryChainBroken)
+		}
+		digest, nextSequenceNumber, err := verifySegment(segmentNumber, result.LastDigest, &verifyOptions)
+		if err != nil {
+			return VerifyResult{}, err
+		}
+		if i == 0 {
+			result.FirstDigest = digest.first
+		}
+		result.LastDigest = digest.last
+		result.NextSequenceNumber = nextSequenceNumber
+	}
+	return result, nil
+}
+
+// verifiedDigests holds the digest a segment continues the entry chain from and the digest of its last entry.
+type verifiedDigests struct {
+	first []byte
+	last  []byte
+}
+
+// verifySegment reads all entries of the segment and returns the digests of the entry chain together with the sequence
+// number following the last entry. The segment needs to continue the entry chain from the given digest, unless it is
+// nil.
+func verifySegment(firstSequenceNumber uint64, previousDigest []byte, verifyOptions *Reader) (verifiedDigests, uint64, error) {
+	segmentReader, err := verifyOptions.openSegment(firstSequenceNumber)
+	if err != nil {
+		return verifiedDigests{}, 0, err
+	}
+
+	header := segmentReader.Header()
+	if !header.EntryChecksumType.IsChained() {
+		return verifiedDigests{}, 0, errors.Join(
+			fmt.Errorf("the WAL segment file %q uses %s: %w", segmentReader.FilePath(), header.EntryChecksumType, ErrEntryChainUnsupported),
+			segmentReader.Close(),
+		)
+	}
+	if err := verifyChainLink(previousDigest, segmentReader); err != nil {
+		return verifiedDigests{}, 0, errors.Join(err, segmentReader.Close())
+	}
+
+	for segmentReader.Next() {
+		// We only need to read the entries to verify the chain.
+	}
+	if err := segmentReader.Err(); !errors.Is(err, io.EOF) && !errors.Is(err, segment.ErrEntryNotWritten) {
+		if errors.Is(err, encoding.ErrEntryChecksumMismatch) {
+			err = errors.Join(err, ErrEntryChainBroken)
+		}
+		return verifiedDigests{}, 0, errors.Join(err, segmentReader.Close())
+	}
+
+	digests := verifiedDigests{
+		first: header.Metadata.PreviousDigest,
+		last:  segmentReader.Digest(),
+	}
+	nextSequenceNumber := segmentReader.NextSequenceNumber()
+	if err := segmentReader.Close(); err != nil {
+		return verifiedDigests{}, 0, err
+	}
+	return digests, nextSequenceNumber, nil
+}
+
+// verifyChainLink checks that the segment continues the entry chain from the digest of the last entry of the previous
+// segment. Nothing is checked when the previous digest is nil or the segment does not use a chained entry checksum
+// type.
+func verifyChainLink(previousDigest []byte, segmentReader *segment.SegmentReader) error {
+	if previousDigest == nil || !segmentReader.Header().EntryChecksumType.IsChained() {
+		return nil
+	}
+	if !bytes.Equal(previousDigest, segmentReader.Header().Metadata.PreviousDigest) {
+		return fmt.Errorf("the WAL segment file %q does not continue the entry chain of the previous segment file: %w", segmentReader.FilePath(), ErrEntryChainBroken)
+	}
+	return nil
+}
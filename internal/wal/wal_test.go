@@ -1,16 +1,24 @@
 package wal_test
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"iter"
 	"math"
 	"os"
 	"path"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/prometheus/client_golang/prometheus"
 
 	"github.com/backbone81/write-ahead-log/internal/encoding"
 	"github.com/backbone81/write-ahead-log/internal/segment"
@@ -75,6 +83,1694 @@ var _ = Describe("WAL", func() {
 			Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
 			Expect(reader.Close()).To(Succeed())
 		})
+
+		It("should resolve futures of asynchronously appended entries only after the sync", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			sequenceNumber, future, err := writer.AppendEntryAsync([]byte("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sequenceNumber).To(Equal(uint64(0)))
+			Consistently(future.Done()).WithTimeout(50 * time.Millisecond).ShouldNot(BeClosed())
+
+			Expect(writer.Close()).To(Succeed())
+			Expect(future.Done()).To(BeClosed())
+			Expect(future.Wait()).To(Succeed())
+		})
+
+		It("should fail futures of truncated entries", func() {
+			syncTracker := wal.NewSyncTracker(0)
+			keptFuture := syncTracker.Future(1)
+			truncatedFuture := syncTracker.Future(3)
+
+			syncTracker.Reset(2)
+			Expect(keptFuture.Wait()).To(Succeed())
+			Expect(truncatedFuture.Wait()).To(MatchError(wal.ErrTruncated))
+
+			By("hand out the sequence numbers again")
+			future := syncTracker.Future(3)
+			Expect(future.Done()).ToNot(BeClosed())
+			syncTracker.Synced(4)
+			Expect(future.Wait()).To(Succeed())
+		})
+
+		It("should flush entries appended after truncation with the grouped sync policy", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(10 * time.Millisecond))
+			Expect(err).ToNot(HaveOccurred())
+
+			var future *wal.SyncFuture
+			for i := range 10 {
+				_, future, err = writer.AppendEntryAsync([]byte(fmt.Sprintf("foo%d", i)))
+				Expect(err).ToNot(HaveOccurred())
+			}
+			Expect(future.Wait()).To(Succeed())
+			Expect(writer.TruncateAfter(2)).To(Succeed())
+
+			By("resolve the future only after the entry was flushed")
+			syncs := syncTotal()
+			sequenceNumber, future, err := writer.AppendEntryAsync([]byte("bar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sequenceNumber).To(Equal(uint64(3)))
+			Eventually(future.Done()).Should(BeClosed())
+			Expect(future.Wait()).To(Succeed())
+			Expect(syncTotal()).To(BeNumerically(">", syncs))
+
+			By("read the flushed entries from the writer")
+			Expect(writer.AppendEntry([]byte("baz"))).To(Equal(uint64(4)))
+			liveReader, err := writer.NewReader(0)
+			Expect(err).ToNot(HaveOccurred())
+			for range 5 {
+				Expect(liveReader.Next()).To(BeTrue())
+			}
+			Expect(liveReader.Value().Data).To(Equal([]byte("baz")))
+			Expect(liveReader.Close()).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should only report entries appended after truncation as flushed with the periodic sync policy", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyPeriodic(2, time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			for i := range 10 {
+				Expect(writer.AppendEntry([]byte(fmt.Sprintf("foo%d", i)))).To(Equal(uint64(i)))
+			}
+			Expect(writer.TruncateAfter(2)).To(Succeed())
+
+			By("flush after the configured number of entries")
+			syncs := syncTotal()
+			_, future, err := writer.AppendEntryAsync([]byte("bar"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(future.Done()).ToNot(BeClosed())
+			Expect(writer.AppendEntry([]byte("baz"))).To(Equal(uint64(4)))
+			Expect(future.Wait()).To(Succeed())
+			Expect(syncTotal()).To(BeNumerically(">", syncs))
+
+			By("not report entries which were never appended as flushed")
+			sequenceNumber, future, err := writer.AppendEntryAsync([]byte("qux"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sequenceNumber).To(Equal(uint64(5)))
+			Expect(future.Done()).ToNot(BeClosed())
+			Expect(writer.Close()).To(Succeed())
+			Expect(future.Wait()).To(Succeed())
+		})
+
+		It("should give up appending when the context is done", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			By("give up before the entry is written")
+			canceledCtx, cancel := context.WithCancel(context.Background())
+			cancel()
+			Expect(writer.AppendEntryContext(canceledCtx, []byte("foo"))).Error().To(MatchError(context.Canceled))
+			Expect(writer.NextSequenceNumber()).To(Equal(uint64(0)))
+
+			By("give up after the entry is written")
+			timeoutCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			sequenceNumber, err := writer.AppendEntryContext(timeoutCtx, []byte("foo"))
+			Expect(err).To(MatchError(context.DeadlineExceeded))
+			Expect(sequenceNumber).To(Equal(uint64(0)))
+			Expect(writer.NextSequenceNumber()).To(Equal(uint64(1)))
+
+			By("close the writer")
+			Expect(writer.CloseContext(context.Background())).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+			Expect(writer.AppendEntry([]byte("bar"))).Error().To(MatchError(wal.ErrWriterClosed))
+
+			By("read back the entry")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Data).To(Equal([]byte("foo")))
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should remove segments before a sequence number", func() {
+			Expect(wal.Init(dir, wal.WithPreAllocationSize(512))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyNone(), wal.WithMaxSegmentSize(512), wal.WithPreAllocationSize(512))
+			Expect(err).ToNot(HaveOccurred())
+			for range 10 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 3, 6, 9}))
+
+			Expect(writer.RemoveBefore(7)).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{6, 9}))
+			Expect(writer.RemoveBefore(100)).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{9}))
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(10)))
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 9)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Data).To(Equal([]byte("foo")))
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should apply the retention policy after rollover", func() {
+			Expect(wal.Init(dir, wal.WithPreAllocationSize(512))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			var retentionFloor atomic.Uint64
+			writer, err := reader.ToWriter(
+				wal.WithSyncPolicyNone(),
+				wal.WithMaxSegmentSize(512),
+				wal.WithPreAllocationSize(512),
+				wal.WithRetentionPolicyMaxSegments(2),
+				wal.WithRetentionFloor(retentionFloor.Load),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("keep all segments required by the retention floor")
+			for range 10 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 3, 6, 9}))
+
+			By("remove segments on the next rollover when the retention floor moved")
+			retentionFloor.Store(7)
+			for range 3 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{6, 9, 12}))
+
+			retentionFloor.Store(math.MaxUint64)
+			for range 3 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{12, 15}))
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should not count the pre-allocated space of the active segment towards the max bytes", func() {
+			Expect(wal.Init(dir, wal.WithPreAllocationSize(1024*1024))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(
+				wal.WithSyncPolicyNone(),
+				wal.WithMaxSegmentSize(512),
+				wal.WithPreAllocationSize(1024*1024),
+				wal.WithRetentionPolicyMaxBytes(2048),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			for range 10 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{3, 6, 9}))
+			Consistently(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}, 100*time.Millisecond).Should(Equal([]uint64{3, 6, 9}))
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should allow the retention floor to call into the writer", func() {
+			Expect(wal.Init(dir, wal.WithPreAllocationSize(512))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			var writer *wal.Writer
+			writer, err = reader.ToWriter(
+				wal.WithSyncPolicyNone(),
+				wal.WithMaxSegmentSize(512),
+				wal.WithPreAllocationSize(512),
+				wal.WithRetentionPolicyMaxSegments(1),
+				wal.WithRetentionFloor(func() uint64 {
+					// Keep the segment with the last three entries.
+					return writer.NextSequenceNumber() - 3
+				}),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			for range 7 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{3, 6}))
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should fail readers which fell behind the retention policy", func() {
+			Expect(wal.Init(dir, wal.WithPreAllocationSize(512))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(
+				wal.WithSyncPolicyNone(),
+				wal.WithMaxSegmentSize(512),
+				wal.WithPreAllocationSize(512),
+				wal.WithRetentionPolicyMaxSegments(1),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(writer.Close()).To(Succeed())
+			}()
+
+			Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			liveReader, err := writer.NewReader(0)
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(liveReader.Close()).To(Succeed())
+			}()
+
+			for range 9 {
+				Expect(writer.AppendEntry(make([]byte, 200))).Error().ToNot(HaveOccurred())
+			}
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{9}))
+
+			By("reading the segment which was opened before it was removed")
+			for range 3 {
+				Expect(liveReader.Next()).To(BeTrue())
+			}
+
+			By("failing to continue with the removed segments")
+			Expect(liveReader.Next()).To(BeFalse())
+			Expect(liveReader.Err()).To(MatchError(segment.ErrSegmentNotFound))
+			Expect(wal.NewReader(dir, 0)).Error().To(MatchError(segment.ErrSegmentNotFound))
+
+			By("failing to replay the removed segments")
+			entries, entriesErr := wal.Replay(dir, 0, 1)
+			for range entries {
+				// Nothing to do.
+			}
+			Expect(entriesErr()).To(MatchError(segment.ErrSegmentNotFound))
+		})
+
+		It("should roll over by entry count, by age and manually", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(
+				wal.WithSyncPolicyNone(),
+				wal.WithMaxSegmentEntries(2),
+				wal.WithMaxSegmentAge(50*time.Millisecond),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("roll over manually")
+			Expect(writer.Rollover()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0}))
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+			Expect(writer.Rollover()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 1}))
+
+			By("roll over by entry count")
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(1)))
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(2)))
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 1}))
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(3)))
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 1, 3}))
+
+			By("roll over by age")
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{0, 1, 3, 4}))
+			Consistently(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}, 200*time.Millisecond).Should(Equal([]uint64{0, 1, 3, 4}))
+
+			By("start the age with the first entry which was appended")
+			Expect(writer.AppendEntryAt(99, []byte("foo"))).To(HaveOccurred())
+			time.Sleep(100 * time.Millisecond)
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(4)))
+			Eventually(func() ([]uint64, error) {
+				return segment.GetSegments(dir)
+			}).Should(Equal([]uint64{0, 1, 3, 4, 5}))
+
+			Expect(writer.Close()).To(Succeed())
+			Expect(writer.Rollover()).To(MatchError(wal.ErrWriterClosed))
+		})
+
+		It("should limit the number of unsynced entries and bytes", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(
+				wal.WithSyncPolicyPeriodic(100, time.Hour),
+				wal.WithMaxUnsyncedEntries(3),
+				wal.WithFailFastOnBackpressure(),
+			)
+			Expect(err).ToNot(HaveOccurred())
+
+			By("fail fast when too many entries are unsynced")
+			for range 3 {
+				Expect(writer.AppendEntry([]byte("foo"))).Error().ToNot(HaveOccurred())
+			}
+			_, err = writer.AppendEntry([]byte("foo"))
+			var backpressureErr *wal.BackpressureError
+			Expect(errors.As(err, &backpressureErr)).To(BeTrue())
+			Expect(backpressureErr.UnsyncedEntries).To(Equal(uint64(3)))
+			Expect(backpressureErr.UnsyncedBytes).To(BeNumerically(">", 3*3))
+			Expect(writer.Close()).To(Succeed())
+
+			By("flush when too many bytes are unsynced")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			for reader.Next() {
+			}
+			writer, err = reader.ToWriter(
+				wal.WithSyncPolicyPeriodic(100, time.Hour),
+				wal.WithMaxUnsyncedBytes(100),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry(make([]byte, 200))).To(Equal(uint64(3)))
+			liveReader, err := writer.NewReader(3)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(liveReader.Next()).To(BeFalse())
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(4)))
+			Expect(liveReader.Next()).To(BeTrue())
+			Expect(liveReader.Value().SequenceNumber).To(Equal(uint64(3)))
+			Expect(liveReader.Next()).To(BeFalse())
+			Expect(liveReader.Close()).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+
+			By("flush on every append with the grouped sync policy")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			for reader.Next() {
+			}
+			writer, err = reader.ToWriter(
+				wal.WithSyncPolicyGrouped(time.Hour),
+				wal.WithMaxUnsyncedEntries(1),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			var previousFuture *wal.SyncFuture
+			for range 3 {
+				_, future, err := writer.AppendEntryAsync([]byte("foo"))
+				Expect(err).ToNot(HaveOccurred())
+				if previousFuture != nil {
+					Expect(previousFuture.Done()).To(BeClosed())
+				}
+				previousFuture = future
+			}
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should follow the writer across rollovers", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyNone(), wal.WithMaxSegmentEntries(3))
+			Expect(err).ToNot(HaveOccurred())
+
+			By("append entries in the background")
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for i := range 10 {
+					time.Sleep(5 * time.Millisecond)
+					Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+				}
+			}()
+
+			By("follow the entries")
+			// The poll interval is long enough to fail the test, as the writer needs to wake up the follower.
+			follower, err := wal.NewReader(dir, 0, wal.WithPollInterval(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			for i := range 10 {
+				Expect(follower.NextWait(ctx)).To(BeTrue())
+				Expect(follower.Value().SequenceNumber).To(Equal(uint64(i)))
+				Expect(follower.Value().Data).To(Equal([]byte{byte(i)}))
+			}
+			wg.Wait()
+			Expect(segment.GetSegments(dir)).To(HaveLen(4))
+
+			By("give up waiting when the context is done")
+			timeoutCtx, timeoutCancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer timeoutCancel()
+			Expect(follower.NextWait(timeoutCtx)).To(BeFalse())
+			Expect(follower.Err()).To(MatchError(context.DeadlineExceeded))
+			Expect(follower.Close()).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should read entries in reverse order", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(100))
+			Expect(err).ToNot(HaveOccurred())
+
+			By("reading an empty write-ahead log")
+			reverseReader, err := wal.NewReverseReader(dir, math.MaxUint64)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reverseReader.Next()).To(BeFalse())
+			Expect(reverseReader.Err()).ToNot(HaveOccurred())
+			Expect(reverseReader.Close()).To(Succeed())
+
+			// Entries of 1 KiB make sure that every segment has records in its index.
+			entryData := func(sequenceNumber uint64) []byte {
+				return bytes.Repeat([]byte{byte(sequenceNumber)}, 1024)
+			}
+			for sequenceNumber := uint64(0); sequenceNumber < 300; sequenceNumber += 3 {
+				Expect(writer.AppendEntry(entryData(sequenceNumber))).To(Equal(sequenceNumber))
+				Expect(writer.AppendEntries([][]byte{
+					entryData(sequenceNumber + 1),
+					entryData(sequenceNumber + 2),
+				})).To(Equal(sequenceNumber + 1))
+			}
+			Expect(writer.Close()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(HaveLen(3))
+
+			expectReverse := func(sequenceNumber uint64, wantLast uint64, options ...wal.ReaderOption) {
+				reverseReader, err := wal.NewReverseReader(dir, sequenceNumber, options...)
+				Expect(err).ToNot(HaveOccurred())
+				for want := int(wantLast); want >= 0; want-- {
+					Expect(reverseReader.Next()).To(BeTrue())
+					Expect(reverseReader.Value().SequenceNumber).To(Equal(uint64(want)))
+					Expect(reverseReader.Value().Data).To(Equal(entryData(uint64(want))))
+				}
+				Expect(reverseReader.Next()).To(BeFalse())
+				Expect(reverseReader.Err()).ToNot(HaveOccurred())
+				Expect(reverseReader.Close()).To(Succeed())
+			}
+
+			By("reading from the newest entry")
+			expectReverse(math.MaxUint64, 299)
+
+			By("reading from a given sequence number")
+			expectReverse(0, 0)
+			expectReverse(100, 100)
+			expectReverse(150, 150)
+			expectReverse(199, 199)
+
+			By("reading read-only without index files")
+			segments, err := segment.GetSegments(dir)
+			Expect(err).ToNot(HaveOccurred())
+			for _, firstSequenceNumber := range segments {
+				Expect(os.Remove(segment.IndexFilePath(path.Join(dir, segment.SegmentFileName(firstSequenceNumber))))).To(Succeed())
+			}
+			expectReverse(250, 250, wal.WithReadOnly())
+
+			By("reading with the read options of the reader")
+			expectReverse(math.MaxUint64, 299, wal.WithMmap(), wal.WithReadAhead())
+			expectReverse(150, 150, wal.WithReadBufferSize(0))
+		})
+
+		It("should only read flushed entries of a writer in the same process in reverse order", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyPeriodic(2, time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			readReverse := func() []string {
+				reverseReader, err := wal.NewReverseReader(dir, math.MaxUint64)
+				Expect(err).ToNot(HaveOccurred())
+				var entries []string
+				for reverseReader.Next() {
+					entries = append(entries, string(reverseReader.Value().Data))
+				}
+				Expect(reverseReader.Err()).ToNot(HaveOccurred())
+				Expect(reverseReader.Close()).To(Succeed())
+				return entries
+			}
+
+			By("skip entries before the first flush")
+			Expect(writer.AppendEntryAsync([]byte("foo"))).Error().ToNot(HaveOccurred())
+			Expect(readReverse()).To(BeEmpty())
+
+			By("start at the newest flushed entry")
+			Expect(writer.AppendEntryAsync([]byte("bar"))).Error().ToNot(HaveOccurred())
+			Expect(writer.AppendEntryAsync([]byte("baz"))).Error().ToNot(HaveOccurred())
+			Expect(readReverse()).To(Equal([]string{"bar", "foo"}))
+
+			By("start at the newest entry after the writer was closed")
+			Expect(writer.Close()).To(Succeed())
+			Expect(readReverse()).To(Equal([]string{"baz", "bar", "foo"}))
+		})
+
+		It("should apply the corruption policy when reading backward", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(10))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 25 {
+				Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 10, 20}))
+
+			// Zero out the last entry of the first segment and flip a bit in the last entry of the newest segment.
+			sealedFilePath := path.Join(dir, segment.SegmentFileName(0))
+			content, err := os.ReadFile(sealedFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			clear(content[len(content)-(4+1+1+4):])
+			Expect(os.WriteFile(sealedFilePath, content, 0o600)).To(Succeed())
+			reader, err = wal.NewReader(dir, 24)
+			Expect(err).ToNot(HaveOccurred())
+			tornOffset := reader.Offset()
+			Expect(reader.Close()).To(Succeed())
+			file, err := os.OpenFile(path.Join(dir, segment.SegmentFileName(20)), os.O_RDWR, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.WriteAt([]byte{0xff}, tornOffset+4+1)).Error().ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			readAll := func(sequenceNumber uint64, options ...wal.ReaderOption) ([]uint64, error) {
+				reverseReader, err := wal.NewReverseReader(dir, sequenceNumber, options...)
+				Expect(err).ToNot(HaveOccurred())
+				var sequenceNumbers []uint64
+				for reverseReader.Next() {
+					sequenceNumbers = append(sequenceNumbers, reverseReader.Value().SequenceNumber)
+				}
+				reverseReaderErr := reverseReader.Err()
+				Expect(reverseReader.Close()).To(Succeed())
+				return sequenceNumbers, reverseReaderErr
+			}
+
+			By("stopping at the torn and the corrupt entry")
+			sequenceNumbers, err := readAll(math.MaxUint64)
+			Expect(sequenceNumbers).To(BeEmpty())
+			Expect(err).To(MatchError(segment.ErrEntryTorn))
+			sequenceNumbers, err = readAll(19)
+			Expect(sequenceNumbers).To(HaveLen(10))
+			Expect(err).To(MatchError(segment.ErrEntryCorrupt))
+
+			By("skipping the torn and the corrupt entry")
+			for _, options := range [][]wal.ReaderOption{{}, {wal.WithMmap()}} {
+				sequenceNumbers, err = readAll(math.MaxUint64, append(options, wal.WithCorruptionPolicy(wal.CorruptionPolicySkipCorrupt))...)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(sequenceNumbers).To(HaveLen(23))
+				Expect(sequenceNumbers).ToNot(ContainElements(uint64(9), uint64(24)))
+			}
+
+			By("truncating the torn entry in the newest segment only")
+			sequenceNumbers, err = readAll(math.MaxUint64, wal.WithCorruptionPolicy(wal.CorruptionPolicyTruncateTail), wal.WithReadOnly())
+			Expect(sequenceNumbers).To(BeEmpty())
+			Expect(err).To(MatchError(segment.ErrReadOnly))
+			sequenceNumbers, err = readAll(math.MaxUint64, wal.WithCorruptionPolicy(wal.CorruptionPolicyTruncateTail))
+			Expect(sequenceNumbers).To(HaveLen(14))
+			Expect(err).To(MatchError(segment.ErrEntryCorrupt))
+			sequenceNumbers, err = readAll(math.MaxUint64)
+			Expect(sequenceNumbers).To(HaveLen(14))
+			Expect(err).To(MatchError(segment.ErrEntryCorrupt))
+		})
+
+		It("should iterate over ranges of entries", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(3))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			collect := func(entries iter.Seq2[uint64, []byte]) []uint64 {
+				var result []uint64
+				for sequenceNumber, data := range entries {
+					Expect(data).To(Equal([]byte{byte(sequenceNumber)}))
+					result = append(result, sequenceNumber)
+				}
+				return result
+			}
+
+			By("stopping exactly at the end of the range")
+			entries, entriesErr := wal.Entries(dir, 2, 7)
+			Expect(collect(entries)).To(Equal([]uint64{2, 3, 4, 5, 6}))
+			Expect(entriesErr()).To(Succeed())
+
+			By("stopping at the end of the written entries")
+			entries, entriesErr = wal.Entries(dir, 8, math.MaxUint64)
+			Expect(collect(entries)).To(Equal([]uint64{8, 9}))
+			Expect(entriesErr()).To(Succeed())
+
+			By("yielding nothing for an empty range")
+			entries, entriesErr = wal.Entries(dir, 5, 5)
+			Expect(collect(entries)).To(BeEmpty())
+			Expect(entriesErr()).To(Succeed())
+
+			By("breaking out of the loop early")
+			entries, entriesErr = wal.Entries(dir, 0, 10)
+			for sequenceNumber := range entries {
+				if sequenceNumber == 4 {
+					break
+				}
+			}
+			Expect(entriesErr()).To(Succeed())
+
+			By("reporting a start beyond the written entries")
+			entries, entriesErr = wal.Entries(dir, 20, 30)
+			Expect(collect(entries)).To(BeEmpty())
+			Expect(entriesErr()).ToNot(Succeed())
+
+			By("iterating with a reader")
+			reader, err = wal.NewReader(dir, 1)
+			Expect(err).ToNot(HaveOccurred())
+			entries, entriesErr = reader.Entries(4)
+			Expect(collect(entries)).To(Equal([]uint64{1, 2, 3}))
+			Expect(entriesErr()).To(Succeed())
+			entries, entriesErr = reader.Entries(math.MaxUint64)
+			Expect(collect(entries)).To(Equal([]uint64{4, 5, 6, 7, 8, 9}))
+			Expect(entriesErr()).To(Succeed())
+			Expect(reader.Close()).To(Succeed())
+
+			By("reporting torn entries")
+			segments, err := segment.GetSegments(dir)
+			Expect(err).ToNot(HaveOccurred())
+			newestSegmentFilePath := path.Join(dir, segment.SegmentFileName(segments[len(segments)-1]))
+			segmentReader, err := segment.OpenSegmentReadOnly(dir, segments[len(segments)-1])
+			Expect(err).ToNot(HaveOccurred())
+			headerSize := segmentReader.Header().Size()
+			Expect(segmentReader.Close()).To(Succeed())
+			Expect(os.Truncate(newestSegmentFilePath, headerSize+2)).To(Succeed())
+			entries, entriesErr = wal.Entries(dir, 0, math.MaxUint64)
+			Expect(collect(entries)).To(Equal([]uint64{0, 1, 2, 3, 4, 5, 6, 7, 8}))
+			Expect(entriesErr()).To(MatchError(segment.ErrEntryTorn))
+		})
+
+		It("should only read flushed entries from a live writer", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			_, future, err := writer.AppendEntryAsync([]byte("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			liveReader, err := writer.NewReader(0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(liveReader.Next()).To(BeFalse())
+			Expect(liveReader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+			Expect(liveReader.ToWriter()).Error().To(MatchError(segment.ErrReadOnly))
+
+			Expect(writer.Close()).To(Succeed())
+			Expect(future.Wait()).To(Succeed())
+			Expect(writer.NewReader(0)).Error().To(MatchError(wal.ErrWriterClosed))
+			Expect(liveReader.Next()).To(BeTrue())
+			Expect(liveReader.Value().Data).To(Equal([]byte("foo")))
+			Expect(liveReader.Close()).To(Succeed())
+		})
+
+		It("should only follow flushed entries of a writer in the same process", func(ctx SpecContext) {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+
+			follower, err := wal.NewReader(dir, 0, wal.WithPollInterval(time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(follower.Close()).To(Succeed())
+			}()
+			_, future, err := writer.AppendEntryAsync([]byte("foo"))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(follower.Next()).To(BeFalse())
+			Expect(follower.Err()).To(MatchError(segment.ErrEntryNotWritten))
+
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(50 * time.Millisecond)
+				Expect(writer.Close()).To(Succeed())
+			}()
+			Expect(follower.NextWait(ctx)).To(BeTrue())
+			Expect(follower.Value().Data).To(Equal([]byte("foo")))
+			Expect(future.Wait()).To(Succeed())
+		}, SpecTimeout(5*time.Second))
+
+		It("should limit standalone readers to the flushed entries of a writer in the same process", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyPeriodic(2, time.Hour))
+			Expect(err).ToNot(HaveOccurred())
+			for _, data := range []string{"foo", "bar", "baz"} {
+				Expect(writer.AppendEntryAsync([]byte(data))).Error().ToNot(HaveOccurred())
+			}
+
+			By("only read the flushed entries, even when the directory is given with a different path")
+			for _, directory := range []string{dir, dir + "/."} {
+				standaloneReader, err := wal.NewReader(directory, 0)
+				Expect(err).ToNot(HaveOccurred())
+				Expect(standaloneReader.Next()).To(BeTrue())
+				Expect(standaloneReader.Next()).To(BeTrue())
+				Expect(standaloneReader.Next()).To(BeFalse())
+				Expect(standaloneReader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+				Expect(standaloneReader.Close()).To(Succeed())
+			}
+
+			By("read all entries after the writer was closed")
+			Expect(writer.Close()).To(Succeed())
+			standaloneReader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			for _, data := range []string{"foo", "bar", "baz"} {
+				Expect(standaloneReader.Next()).To(BeTrue())
+				Expect(standaloneReader.Value().Data).To(Equal([]byte(data)))
+			}
+			Expect(standaloneReader.Close()).To(Succeed())
+		})
+
+		It("should read from a live writer concurrently", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Millisecond), wal.WithMaxSegmentEntries(7))
+			Expect(err).ToNot(HaveOccurred())
+
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer GinkgoRecover()
+				defer wg.Done()
+				for i := range 50 {
+					Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+				}
+			}()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			defer cancel()
+			for range 3 {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					liveReader, err := writer.NewReader(0, wal.WithPollInterval(time.Millisecond))
+					Expect(err).ToNot(HaveOccurred())
+					for i := range 50 {
+						Expect(liveReader.NextWait(ctx)).To(BeTrue())
+						Expect(liveReader.Value().SequenceNumber).To(Equal(uint64(i)))
+						Expect(liveReader.Value().Data).To(Equal([]byte{byte(i)}))
+					}
+					Expect(liveReader.Close()).To(Succeed())
+				}()
+			}
+			wg.Wait()
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should read segments read-only", func() {
+			if os.Geteuid() == 0 {
+				Skip("root can write files without write permission")
+			}
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(3))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			segments, err := segment.GetSegments(dir)
+			Expect(err).ToNot(HaveOccurred())
+			for _, firstSequenceNumber := range segments {
+				Expect(os.Chmod(path.Join(dir, segment.SegmentFileName(firstSequenceNumber)), 0o400)).To(Succeed())
+			}
+
+			reader, err = wal.NewReader(dir, 0, wal.WithReadOnly())
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(reader.Next()).To(BeTrue())
+				Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+				Expect(reader.Value().Data).To(Equal([]byte{byte(i)}))
+			}
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.ToWriter()).Error().To(MatchError(segment.ErrReadOnly))
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should read sealed segments memory-mapped", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(3))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			By("reading all entries across mapped segments and the newest segment")
+			reader, err = wal.NewReader(dir, 0, wal.WithMmap())
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(reader.Next()).To(BeTrue())
+				Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+				Expect(reader.Value().Data).To(Equal([]byte{byte(i)}))
+			}
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Err()).To(MatchError(segment.ErrEntryNone))
+
+			By("appending after reading, as the newest segment is not mapped")
+			writer, err = reader.ToWriter()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry([]byte{10})).To(Equal(uint64(10)))
+			Expect(writer.Close()).To(Succeed())
+		})
+
+		It("should read entries with read-ahead across segments", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(30))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 100 {
+				Expect(writer.AppendEntry(bytes.Repeat([]byte{byte(i)}, i))).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 10, wal.WithReadBufferSize(64), wal.WithReadAhead())
+			Expect(err).ToNot(HaveOccurred())
+			for i := 10; i < 100; i++ {
+				Expect(reader.Next()).To(BeTrue())
+				Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+				Expect(reader.Value().Data).To(Equal(bytes.Repeat([]byte{byte(i)}, i)))
+			}
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+
+			writer, err = reader.ToWriter()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(100)))
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 100, wal.WithReadBufferSize(0))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Data).To(Equal([]byte("foo")))
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should replay entries in order while decoding segments concurrently", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(7))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 200 {
+				if i%10 == 0 {
+					Expect(writer.AppendEntries([][]byte{{byte(i)}, {byte(i + 1)}})).To(Equal(uint64(i)))
+				} else if i%10 != 1 {
+					Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+				}
+			}
+			Expect(writer.Close()).To(Succeed())
+
+			for _, from := range []uint64{0, 1, 123, 199, 200} {
+				By(fmt.Sprintf("replaying from sequence number %d", from))
+				entries, entriesErr := wal.Replay(dir, from, 4)
+				expected := from
+				for sequenceNumber, data := range entries {
+					Expect(sequenceNumber).To(Equal(expected))
+					Expect(data).To(Equal([]byte{byte(expected)}))
+					expected++
+				}
+				Expect(entriesErr()).To(Succeed())
+				Expect(expected).To(Equal(uint64(200)))
+			}
+
+			By("stopping the replay early")
+			entries, entriesErr := wal.Replay(dir, 0, 2)
+			for sequenceNumber := range entries {
+				if sequenceNumber == 50 {
+					break
+				}
+			}
+			Expect(entriesErr()).To(Succeed())
+
+			By("reporting a corrupt entry in a sealed segment")
+			segments, err := segment.GetSegments(dir)
+			Expect(err).ToNot(HaveOccurred())
+			filePath := path.Join(dir, segment.SegmentFileName(segments[5]))
+			content, err := os.ReadFile(filePath)
+			Expect(err).ToNot(HaveOccurred())
+			content[len(content)-1] ^= 0xff
+			Expect(os.WriteFile(filePath, content, 0o600)).To(Succeed())
+			entries, entriesErr = wal.Replay(dir, 0, 0)
+			expected := uint64(0)
+			for sequenceNumber := range entries {
+				Expect(sequenceNumber).To(Equal(expected))
+				expected++
+			}
+			Expect(entriesErr()).To(MatchError(segment.ErrEntryCorrupt))
+			Expect(expected).To(BeNumerically(">", segments[5]))
+			Expect(expected).To(BeNumerically("<", segments[6]))
+		})
+
+		It("should replay segments which are decoded in several chunks", func() {
+			Expect(wal.Init(dir, wal.WithEntryChecksumType(encoding.EntryChecksumTypeSha256Chain))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(100))
+			Expect(err).ToNot(HaveOccurred())
+
+			// Entries of 32 KiB make sure that the sealed segments are decoded in several chunks.
+			entryData := func(sequenceNumber uint64) []byte {
+				return bytes.Repeat([]byte{byte(sequenceNumber)}, 32*1024)
+			}
+			for sequenceNumber := range uint64(250) {
+				Expect(writer.AppendEntry(entryData(sequenceNumber))).To(Equal(sequenceNumber))
+			}
+			Expect(writer.Close()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 100, 200}))
+
+			for _, from := range []uint64{0, 1, 50, 99, 100, 163, 249, 250} {
+				By(fmt.Sprintf("replaying from sequence number %d", from))
+				entries, entriesErr := wal.Replay(dir, from, 3)
+				expected := from
+				for sequenceNumber, data := range entries {
+					Expect(sequenceNumber).To(Equal(expected))
+					Expect(data).To(Equal(entryData(expected)))
+					expected++
+				}
+				Expect(entriesErr()).To(Succeed())
+				Expect(expected).To(Equal(uint64(250)))
+			}
+
+			By("stopping the replay early")
+			entries, entriesErr := wal.Replay(dir, 0, 3)
+			for sequenceNumber := range entries {
+				if sequenceNumber == 150 {
+					break
+				}
+			}
+			Expect(entriesErr()).To(Succeed())
+
+			By("reporting a corrupt entry in the middle of a sealed segment")
+			reader, err = wal.NewReader(dir, 50)
+			Expect(err).ToNot(HaveOccurred())
+			corruptOffset := reader.Offset()
+			Expect(reader.Close()).To(Succeed())
+			file, err := os.OpenFile(path.Join(dir, segment.SegmentFileName(0)), os.O_RDWR, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.WriteAt([]byte{0xff}, corruptOffset+4+1)).Error().ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			entries, entriesErr = wal.Replay(dir, 0, 3)
+			expected := uint64(0)
+			for sequenceNumber := range entries {
+				Expect(sequenceNumber).To(Equal(expected))
+				expected++
+			}
+			Expect(entriesErr()).To(MatchError(segment.ErrEntryCorrupt))
+			Expect(expected).To(Equal(uint64(50)))
+
+			By("skipping the corrupt entries up to the next entry in the index")
+			entries, entriesErr = wal.Replay(dir, 0, 3, wal.WithCorruptionPolicy(wal.CorruptionPolicySkipCorrupt))
+			var sequenceNumbers []uint64
+			for sequenceNumber := range entries {
+				sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(sequenceNumbers).ToNot(ContainElement(uint64(50)))
+			Expect(sequenceNumbers).To(ContainElements(uint64(49), uint64(99), uint64(100), uint64(249)))
+			Expect(len(sequenceNumbers)).To(BeNumerically(">", 240))
+		})
+
+		It("should apply the corruption policy", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(10))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 25 {
+				Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+			}
+			Expect(writer.Close()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 10, 20}))
+
+			// Zero out the last entry of the first segment. This looks like an entry which was not yet written, but
+			// must be reported as corrupt in a sealed segment.
+			sealedFilePath := path.Join(dir, segment.SegmentFileName(0))
+			content, err := os.ReadFile(sealedFilePath)
+			Expect(err).ToNot(HaveOccurred())
+			clear(content[len(content)-(4+1+1+4):])
+			Expect(os.WriteFile(sealedFilePath, content, 0o600)).To(Succeed())
+
+			// Flip a bit in the data of the last entry of the newest segment, like an entry which was only partially
+			// written.
+			newestFilePath := path.Join(dir, segment.SegmentFileName(20))
+			reader, err = wal.NewReader(dir, 24)
+			Expect(err).ToNot(HaveOccurred())
+			tornOffset := reader.Offset()
+			Expect(reader.Close()).To(Succeed())
+			file, err := os.OpenFile(newestFilePath, os.O_RDWR, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.WriteAt([]byte{0xff}, tornOffset+4+1)).Error().ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+
+			readAll := func(options ...wal.ReaderOption) ([]uint64, error) {
+				reader, err := wal.NewReader(dir, 0, options...)
+				Expect(err).ToNot(HaveOccurred())
+				var sequenceNumbers []uint64
+				for reader.Next() {
+					sequenceNumbers = append(sequenceNumbers, reader.Value().SequenceNumber)
+				}
+				readerErr := reader.Err()
+				Expect(reader.Close()).To(Succeed())
+				return sequenceNumbers, readerErr
+			}
+
+			By("stopping at the corrupt entry in the sealed segment")
+			sequenceNumbers, err := readAll()
+			Expect(sequenceNumbers).To(HaveLen(9))
+			Expect(err).To(MatchError(segment.ErrEntryCorrupt))
+			Expect(err).ToNot(MatchError(segment.ErrEntryNotWritten))
+			var entryErr *segment.EntryError
+			Expect(errors.As(err, &entryErr)).To(BeTrue())
+			Expect(entryErr.Path).To(Equal(sealedFilePath))
+			Expect(entryErr.SequenceNumber).To(Equal(uint64(9)))
+			Expect(entryErr.Offset).To(Equal(int64(len(content) - (4 + 1 + 1 + 4))))
+			entries, entriesErr := wal.Replay(dir, 0, 2)
+			for range entries {
+				// Replay all entries up to the corrupt entry.
+			}
+			Expect(entriesErr()).To(MatchError(segment.ErrEntryCorrupt))
+
+			By("skipping the corrupt entry in the sealed segment")
+			sequenceNumbers, err = readAll(wal.WithCorruptionPolicy(wal.CorruptionPolicySkipCorrupt))
+			Expect(sequenceNumbers).To(HaveLen(23))
+			Expect(sequenceNumbers).ToNot(ContainElement(uint64(9)))
+			Expect(err).To(MatchError(segment.ErrEntryTorn))
+			entries, entriesErr = wal.Replay(dir, 0, 2, wal.WithCorruptionPolicy(wal.CorruptionPolicySkipCorrupt))
+			sequenceNumbers = nil
+			for sequenceNumber := range entries {
+				sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+			}
+			Expect(sequenceNumbers).To(HaveLen(23))
+			Expect(entriesErr()).To(MatchError(segment.ErrEntryTorn))
+
+			By("truncating the torn entry in the newest segment only")
+			sequenceNumbers, err = readAll(wal.WithCorruptionPolicy(wal.CorruptionPolicyTruncateTail))
+			Expect(sequenceNumbers).To(HaveLen(9))
+			Expect(err).To(MatchError(segment.ErrEntryCorrupt))
+			reader, err = wal.NewReader(dir, 20, wal.WithCorruptionPolicy(wal.CorruptionPolicyTruncateTail))
+			Expect(err).ToNot(HaveOccurred())
+			for range 4 {
+				Expect(reader.Next()).To(BeTrue())
+			}
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Err()).To(MatchError(segment.ErrEntryNotWritten))
+			writer, err = reader.ToWriter()
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(24)))
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 24)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Data).To(Equal([]byte("foo")))
+			Expect(reader.Close()).To(Succeed())
+
+			By("keeping valid entries after a damaged entry in the newest segment")
+			reader, err = wal.NewReader(dir, 21)
+			Expect(err).ToNot(HaveOccurred())
+			damagedOffset := reader.Offset()
+			Expect(reader.Close()).To(Succeed())
+			file, err = os.OpenFile(newestFilePath, os.O_RDWR, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(file.WriteAt([]byte{0xff}, damagedOffset+4+1)).Error().ToNot(HaveOccurred())
+			Expect(file.Close()).To(Succeed())
+			content, err = os.ReadFile(newestFilePath)
+			Expect(err).ToNot(HaveOccurred())
+
+			reader, err = wal.NewReader(dir, 20, wal.WithCorruptionPolicy(wal.CorruptionPolicyTruncateTail))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Err()).To(MatchError(segment.ErrEntryCorrupt))
+			Expect(reader.Err()).ToNot(MatchError(segment.ErrEntryTorn))
+			Expect(reader.Close()).To(Succeed())
+			Expect(os.ReadFile(newestFilePath)).To(Equal(content))
+		})
+
+		It("should store the writer metadata in the segment headers", func() {
+			beforeInit := time.Now()
+			Expect(wal.Init(dir, wal.WithWriterID("init"), wal.WithUserMetadata("schema-version", "1"))).To(Succeed())
+
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Header().Version).To(BeEquivalentTo(encoding.HeaderVersion3))
+			Expect(reader.Header().Metadata.CreatedAt).To(BeTemporally(">=", beforeInit.Round(0)))
+			Expect(reader.Header().Metadata.WriterID).To(Equal("init"))
+			Expect(reader.Header().Metadata.ApplicationID).To(BeEmpty())
+			Expect(reader.Header().Metadata.UserData).To(Equal(map[string]string{"schema-version": "1"}))
+			Expect(reader.Next()).To(BeFalse())
+
+			writer, err := reader.ToWriter(
+				wal.WithWriterID("orders-1.2.3"),
+				wal.WithApplicationID("orders"),
+				wal.WithUserMetadata("schema-version", "2"),
+				wal.WithUserMetadata("region", "eu"),
+			)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+			Expect(writer.Rollover()).To(Succeed())
+			Expect(writer.Header().Metadata.WriterID).To(Equal("orders-1.2.3"))
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 1)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Header().FirstSequenceNumber).To(Equal(uint64(1)))
+			Expect(reader.Header().Metadata.CreatedAt).To(BeTemporally(">=", beforeInit.Round(0)))
+			Expect(reader.Header().Metadata.WriterID).To(Equal("orders-1.2.3"))
+			Expect(reader.Header().Metadata.ApplicationID).To(Equal("orders"))
+			Expect(reader.Header().Metadata.UserData).To(Equal(map[string]string{"schema-version": "2", "region": "eu"}))
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should upgrade segment files of an older version which do not support entry flags", func() {
+			header := encoding.DefaultHeader
+			header.Version = encoding.HeaderVersion1
+			file, err := os.Create(path.Join(dir, segment.SegmentFileName(0)))
+			Expect(err).ToNot(HaveOccurred())
+			var buffer [encoding.HeaderSize]byte
+			Expect(encoding.WriteHeader(file, buffer[:], header)).To(Succeed())
+			Expect(file.Close()).To(Succeed())
+
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			var rollovers [][2]uint64
+			writer, err := reader.ToWriter(wal.WithRolloverCallback(func(previousSegment uint64, nextSegment uint64) {
+				rollovers = append(rollovers, [2]uint64{previousSegment, nextSegment})
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.Header().Version).To(BeEquivalentTo(encoding.HeaderVersion1))
+
+			By("replacing the empty segment file")
+			Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: 1, Data: []byte("foo")})).To(Equal(uint64(0)))
+			Expect(writer.Header().Version).To(BeEquivalentTo(encoding.HeaderVersion))
+			Expect(writer.Header().FirstSequenceNumber).To(Equal(uint64(0)))
+			Expect(rollovers).To(BeEmpty())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0}))
+			Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).To(Equal(uint64(1)))
+			Expect(writer.Close()).To(Succeed())
+
+			entries, entriesErr := wal.Entries(dir, 0, math.MaxUint64)
+			var found []string
+			for _, data := range entries {
+				found = append(found, string(data))
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(found).To(Equal([]string{"foo", "bar", "baz"}))
+
+			By("rolling over a segment file with entries")
+			Expect(os.RemoveAll(dir)).To(Succeed())
+			Expect(os.Mkdir(dir, 0o755)).To(Succeed())
+			file, err = os.Create(path.Join(dir, segment.SegmentFileName(0)))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(encoding.WriteHeader(file, buffer[:], header)).To(Succeed())
+			segmentWriter, err := segment.NewSegmentWriter(file, segment.NewSegmentWriterConfig{
+				Header: header,
+				Offset: encoding.HeaderSize,
+			})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(segmentWriter.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+			Expect(segmentWriter.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err = reader.ToWriter(wal.WithRolloverCallback(func(previousSegment uint64, nextSegment uint64) {
+				rollovers = append(rollovers, [2]uint64{previousSegment, nextSegment})
+			}))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: 1, Data: []byte("bar")})).To(Equal(uint64(1)))
+			Expect(writer.Close()).To(Succeed())
+			Expect(rollovers).To(Equal([][2]uint64{{0, 1}}))
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 1}))
+		})
+
+		It("should append typed entries and skip control records", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter()
+			Expect(err).ToNot(HaveOccurred())
+
+			headers := []encoding.EntryHeader{{Key: "schema", Value: []byte("order.v2")}}
+			Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: 1, Flags: 2, Headers: headers, Data: []byte("foo")})).To(Equal(uint64(0)))
+			Expect(writer.AppendEntry([]byte("bar"))).To(Equal(uint64(1)))
+			Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: encoding.EntryTypeControl})).Error().To(MatchError(wal.ErrEntryTypeReserved))
+			Expect(writer.AppendCheckpoint([]byte("snapshot-1"))).To(Equal(uint64(2)))
+			Expect(writer.AppendEntry([]byte("baz"))).To(Equal(uint64(3)))
+			Expect(writer.Close()).To(Succeed())
+
+			By("skipping control records with the reader")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Type).To(Equal(encoding.EntryType(1)))
+			Expect(reader.Value().Flags).To(Equal(uint8(2)))
+			Expect(reader.Value().Headers).To(Equal(headers))
+			Expect(reader.Value().Data).To(Equal([]byte("foo")))
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Type).To(Equal(encoding.EntryTypeData))
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().SequenceNumber).To(Equal(uint64(3)))
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Close()).To(Succeed())
+
+			By("returning control records when requested")
+			reader, err = wal.NewReader(dir, 2, wal.WithControlRecords())
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Type).To(Equal(encoding.EntryTypeCheckpoint))
+			Expect(reader.Value().Data).To(Equal([]byte("snapshot-1")))
+			Expect(reader.Close()).To(Succeed())
+
+			By("skipping control records when iterating")
+			var sequenceNumbers []uint64
+			entries, entriesErr := wal.Entries(dir, 0, math.MaxUint64, wal.WithControlRecords())
+			for sequenceNumber := range entries {
+				sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(sequenceNumbers).To(Equal([]uint64{0, 1, 3}))
+
+			By("stopping before the end when a control record is skipped")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			sequenceNumbers = nil
+			entries, entriesErr = reader.Entries(3)
+			for sequenceNumber := range entries {
+				sequenceNumbers = append(sequenceNumbers, sequenceNumber)
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(sequenceNumbers).To(Equal([]uint64{0, 1}))
+			Expect(reader.NextSequenceNumber()).To(Equal(uint64(3)))
+			Expect(reader.Close()).To(Succeed())
+
+			By("skipping control records when reading backward")
+			reverseReader, err := wal.NewReverseReader(dir, math.MaxUint64)
+			Expect(err).ToNot(HaveOccurred())
+			sequenceNumbers = nil
+			for reverseReader.Next() {
+				sequenceNumbers = append(sequenceNumbers, reverseReader.Value().SequenceNumber)
+			}
+			Expect(reverseReader.Err()).ToNot(HaveOccurred())
+			Expect(sequenceNumbers).To(Equal([]uint64{3, 1, 0}))
+			Expect(reverseReader.Value().Type).To(Equal(encoding.EntryType(1)))
+			Expect(reverseReader.Value().Headers).To(Equal(headers))
+			Expect(reverseReader.Close()).To(Succeed())
+
+			By("finding the most recent checkpoint when reading backward")
+			reverseReader, err = wal.NewReverseReader(dir, math.MaxUint64, wal.WithControlRecords())
+			Expect(err).ToNot(HaveOccurred())
+			for reverseReader.Next() && !reverseReader.Value().Type.IsControl() {
+				// Skip the entries written after the checkpoint.
+			}
+			Expect(reverseReader.Err()).ToNot(HaveOccurred())
+			Expect(reverseReader.Value().SequenceNumber).To(Equal(uint64(2)))
+			Expect(reverseReader.Value().Data).To(Equal([]byte("snapshot-1")))
+			Expect(reverseReader.Close()).To(Succeed())
+		})
+
+		It("should skip control records when waiting for entries", func(ctx SpecContext) {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter()
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(writer.Close()).To(Succeed())
+			}()
+
+			liveReader, err := writer.NewReader(0)
+			Expect(err).ToNot(HaveOccurred())
+			defer func() {
+				Expect(liveReader.Close()).To(Succeed())
+			}()
+
+			Expect(writer.AppendCheckpoint([]byte("snapshot-1"))).To(Equal(uint64(0)))
+			go func() {
+				defer GinkgoRecover()
+				time.Sleep(50 * time.Millisecond)
+				Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(1)))
+			}()
+			Expect(liveReader.NextWait(ctx)).To(BeTrue())
+			Expect(liveReader.Value().SequenceNumber).To(Equal(uint64(1)))
+			Expect(liveReader.Value().Data).To(Equal([]byte("foo")))
+		}, SpecTimeout(5*time.Second))
+
+		It("should append typed entries asynchronously and with a context", func(ctx SpecContext) {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyGrouped(time.Millisecond))
+			Expect(err).ToNot(HaveOccurred())
+
+			sequenceNumber, future, err := writer.AppendTypedEntryAsync(segment.TypedEntry{Type: 1, Data: []byte("foo")})
+			Expect(err).ToNot(HaveOccurred())
+			Expect(sequenceNumber).To(BeZero())
+			Expect(future.Wait()).To(Succeed())
+			Expect(writer.AppendTypedEntryContext(ctx, segment.TypedEntry{Type: 2, Data: []byte("bar")})).To(Equal(uint64(1)))
+
+			Expect(writer.AppendTypedEntryAsync(segment.TypedEntry{Type: encoding.EntryTypeCheckpoint})).Error().To(MatchError(wal.ErrEntryTypeReserved))
+			Expect(writer.AppendTypedEntryContext(ctx, segment.TypedEntry{Type: encoding.EntryTypeCheckpoint})).Error().To(MatchError(wal.ErrEntryTypeReserved))
+
+			canceledCtx, cancel := context.WithCancel(ctx)
+			cancel()
+			Expect(writer.AppendTypedEntryContext(canceledCtx, segment.TypedEntry{Type: 3})).Error().To(MatchError(context.Canceled))
+			Expect(writer.Close()).To(Succeed())
+
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Type).To(Equal(encoding.EntryType(1)))
+			Expect(reader.Next()).To(BeTrue())
+			Expect(reader.Value().Type).To(Equal(encoding.EntryType(2)))
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Close()).To(Succeed())
+		}, SpecTimeout(5*time.Second))
+
+		for _, entryChecksumType := range encoding.ChainedEntryChecksumTypes {
+			It(fmt.Sprintf("should verify the entry chain with entry checksum %s", entryChecksumType), func() {
+				key := []byte("secret")
+				Expect(wal.Init(dir, wal.WithEntryChecksumType(entryChecksumType), wal.WithEntryChecksumKey(key))).To(Succeed())
+				reader, err := wal.NewReader(dir, 0, wal.WithReaderEntryChecksumKey(key))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(reader.Next()).To(BeFalse())
+				writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(4))
+				Expect(err).ToNot(HaveOccurred())
+				for i := range 10 {
+					Expect(writer.AppendEntry([]byte{byte(i)})).To(Equal(uint64(i)))
+				}
+				Expect(writer.Close()).To(Succeed())
+				Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 4, 8}))
+
+				By("verifying the chain")
+				result, err := wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))
+				Expect(err).ToNot(HaveOccurred())
+				Expect(result.FirstSequenceNumber).To(BeZero())
+				Expect(result.NextSequenceNumber).To(Equal(uint64(10)))
+				Expect(result.FirstDigest).To(Equal(make([]byte, encoding.EntryChainDigestSize)))
+				Expect(result.LastDigest).To(HaveLen(encoding.EntryChainDigestSize))
+				Expect(result.LastDigest).ToNot(Equal(result.FirstDigest))
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key), wal.WithMmap(), wal.WithReadAhead())).To(Equal(result))
+
+				By("reading the entries forward, backward and replayed")
+				entries, entriesErr := wal.Entries(dir, 0, math.MaxUint64, wal.WithReaderEntryChecksumKey(key))
+				count := 0
+				for range entries {
+					count++
+				}
+				Expect(entriesErr()).To(Succeed())
+				Expect(count).To(Equal(10))
+				entries, entriesErr = wal.Replay(dir, 0, 2, wal.WithReaderEntryChecksumKey(key))
+				count = 0
+				for range entries {
+					count++
+				}
+				Expect(entriesErr()).To(Succeed())
+				Expect(count).To(Equal(10))
+				reverseReader, err := wal.NewReverseReader(dir, math.MaxUint64, wal.WithReaderEntryChecksumKey(key))
+				Expect(err).ToNot(HaveOccurred())
+				for range 10 {
+					Expect(reverseReader.Next()).To(BeTrue())
+				}
+				Expect(reverseReader.Close()).To(Succeed())
+
+				// All entries have the same size, as they hold a single byte of data.
+				segmentReader, err := segment.OpenSegmentReadOnly(dir, 4)
+				Expect(err).ToNot(HaveOccurred())
+				segmentReader.SetEntryChecksumKey(key)
+				entryStart := int(segmentReader.Offset())
+				Expect(segmentReader.Next()).To(BeTrue())
+				entrySize := int(segmentReader.Offset()) - entryStart
+				Expect(segmentReader.Close()).To(Succeed())
+				filePath := path.Join(dir, segment.SegmentFileName(4))
+				original, err := os.ReadFile(filePath)
+				Expect(err).ToNot(HaveOccurred())
+
+				By("detecting reordered entries")
+				tampered := bytes.Clone(original)
+				copy(tampered[entryStart:], original[entryStart+entrySize:entryStart+2*entrySize])
+				copy(tampered[entryStart+entrySize:], original[entryStart:entryStart+entrySize])
+				Expect(os.WriteFile(filePath, tampered, 0o600)).To(Succeed())
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))).Error().To(MatchError(wal.ErrEntryChainBroken))
+
+				By("detecting altered entries")
+				tampered = bytes.Clone(original)
+				tampered[entryStart+entrySize-encoding.EntryChainDigestSize-1] ^= 0xff
+				Expect(os.WriteFile(filePath, tampered, 0o600)).To(Succeed())
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))).Error().To(MatchError(wal.ErrEntryChainBroken))
+
+				By("detecting removed entries")
+				Expect(os.WriteFile(filePath, original[:len(original)-entrySize], 0o600)).To(Succeed())
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))).Error().To(MatchError(wal.ErrEntryChainBroken))
+
+				By("detecting removed segments")
+				Expect(segment.RemoveSegment(dir, 4)).To(Succeed())
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))).Error().To(MatchError(wal.ErrEntryChainBroken))
+
+				By("detecting a broken chain while reading")
+				Expect(os.WriteFile(filePath, original, 0o600)).To(Succeed())
+				Expect(segment.RemoveSegment(dir, 8)).To(Succeed())
+				segmentWriter, err := segment.CreateSegment(dir, 8, segment.CreateSegmentConfig{
+					EntryLengthEncoding: encoding.DefaultEntryLengthEncoding,
+					EntryChecksumType:   entryChecksumType,
+					EntryChecksumKey:    key,
+				})
+				Expect(err).ToNot(HaveOccurred())
+				Expect(segmentWriter.AppendEntry([]byte{8})).To(Equal(uint64(8)))
+				Expect(segmentWriter.Close()).To(Succeed())
+				Expect(wal.Verify(dir, wal.WithReaderEntryChecksumKey(key))).Error().To(MatchError(wal.ErrEntryChainBroken))
+				reader, err = wal.NewReader(dir, 4, wal.WithReaderEntryChecksumKey(key))
+				Expect(err).ToNot(HaveOccurred())
+				for reader.Next() {
+					// Read all entries up to the broken chain.
+				}
+				Expect(reader.Err()).To(MatchError(wal.ErrEntryChainBroken))
+				Expect(reader.Close()).To(Succeed())
+			})
+		}
+
+		It("should compress entries and decompress them when reading", func() {
+			entry := func(i int) []byte {
+				return append(bytes.Repeat([]byte(`{"event":"order-created","amount":42}`), 20), byte(i))
+			}
+			Expect(wal.Init(dir, wal.WithCompression(encoding.CompressionFlate))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithMaxSegmentEntries(4))
+			Expect(err).ToNot(HaveOccurred())
+			for i := range 10 {
+				Expect(writer.AppendEntry(entry(i))).To(Equal(uint64(i)))
+			}
+			Expect(writer.Header().Metadata.Compression).To(Equal(encoding.CompressionFlate))
+			Expect(writer.Close()).To(Succeed())
+			Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 4, 8}))
+
+			By("reading the entries forward, backward and replayed")
+			for _, options := range [][]wal.ReaderOption{nil, {wal.WithMmap()}} {
+				entries, entriesErr := wal.Entries(dir, 0, math.MaxUint64, options...)
+				expected := 0
+				for sequenceNumber, data := range entries {
+					Expect(sequenceNumber).To(BeEquivalentTo(expected))
+					Expect(data).To(Equal(entry(expected)))
+					expected++
+				}
+				Expect(entriesErr()).To(Succeed())
+				Expect(expected).To(Equal(10))
+			}
+			entries, entriesErr := wal.Replay(dir, 0, 2)
+			expected := 0
+			for sequenceNumber, data := range entries {
+				Expect(sequenceNumber).To(BeEquivalentTo(expected))
+				Expect(data).To(Equal(entry(expected)))
+				expected++
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(expected).To(Equal(10))
+			reverseReader, err := wal.NewReverseReader(dir, math.MaxUint64)
+			Expect(err).ToNot(HaveOccurred())
+			for i := 9; i >= 0; i-- {
+				Expect(reverseReader.Next()).To(BeTrue())
+				Expect(reverseReader.Value().Data).To(Equal(entry(i)))
+			}
+			Expect(reverseReader.Close()).To(Succeed())
+
+			By("disabling the compression for new segments")
+			reader, err = wal.NewReader(dir, 8)
+			Expect(err).ToNot(HaveOccurred())
+			for reader.Next() {
+				// Read all entries to reach the end of the write-ahead log.
+			}
+			writer, err = reader.ToWriter(wal.WithCompression(encoding.CompressionNone))
+			Expect(err).ToNot(HaveOccurred())
+			Expect(writer.AppendEntry(entry(10))).To(Equal(uint64(10)))
+			Expect(writer.Rollover()).To(Succeed())
+			Expect(writer.Header().Metadata.Compression).To(Equal(encoding.CompressionNone))
+			Expect(writer.AppendEntry(entry(11))).To(Equal(uint64(11)))
+			Expect(writer.Close()).To(Succeed())
+
+			entries, entriesErr = wal.Entries(dir, 8, math.MaxUint64)
+			expected = 8
+			for sequenceNumber, data := range entries {
+				Expect(sequenceNumber).To(BeEquivalentTo(expected))
+				Expect(data).To(Equal(entry(expected)))
+				expected++
+			}
+			Expect(entriesErr()).To(Succeed())
+			Expect(expected).To(Equal(12))
+		})
+
+		It("should compress entries appended concurrently", func() {
+			entry := func(i int) []byte {
+				return append(bytes.Repeat([]byte(`{"event":"order-created","amount":42}`), 20), byte(i))
+			}
+			Expect(wal.Init(dir, wal.WithCompression(encoding.CompressionFlateFast))).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyNone())
+			Expect(err).ToNot(HaveOccurred())
+
+			var wg sync.WaitGroup
+			for i := range 40 {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					data := entry(i)
+					switch i % 4 {
+					case 0:
+						Expect(writer.AppendEntry(data)).Error().ToNot(HaveOccurred())
+					case 1:
+						Expect(writer.AppendEntryParts(data[:10], data[10:])).Error().ToNot(HaveOccurred())
+					case 2:
+						Expect(writer.AppendTypedEntry(segment.TypedEntry{Type: 1, Data: data})).Error().ToNot(HaveOccurred())
+					case 3:
+						_, future, err := writer.AppendEntryAsync(data)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(future.Wait()).To(Succeed())
+					}
+				}()
+			}
+			wg.Wait()
+			Expect(writer.Offset()).To(BeNumerically("<", 40*len(entry(0))))
+			Expect(writer.Close()).To(Succeed())
+
+			entries, entriesErr := wal.Entries(dir, 0, math.MaxUint64)
+			var found []int
+			for _, data := range entries {
+				Expect(data[:len(data)-1]).To(Equal(entry(0)[:len(data)-1]))
+				found = append(found, int(data[len(data)-1]))
+			}
+			Expect(entriesErr()).To(Succeed())
+			expected := make([]int, 40)
+			for i := range expected {
+				expected[i] = i
+			}
+			Expect(found).To(ConsistOf(expected))
+		})
+
+		It("should fail to create a writer with an unknown compression", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.ToWriter(wal.WithCompression(encoding.Compression(99)))).Error().To(MatchError(encoding.ErrCompressionUnsupported))
+			Expect(reader.Close()).To(Succeed())
+		})
+
+		It("should only append entries with the expected sequence number", func() {
+			Expect(wal.Init(dir)).To(Succeed())
+			reader, err := wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			Expect(reader.Next()).To(BeFalse())
+			writer, err := reader.ToWriter(wal.WithSyncPolicyNone())
+			Expect(err).ToNot(HaveOccurred())
+
+			By("append concurrently with the same expected sequence number")
+			var successCount atomic.Int64
+			var wg sync.WaitGroup
+			for range 10 {
+				wg.Add(1)
+				go func() {
+					defer GinkgoRecover()
+					defer wg.Done()
+					err := writer.AppendEntryAt(0, []byte("foo"))
+					if err == nil {
+						successCount.Add(1)
+						return
+					}
+					var mismatchErr *wal.SequenceNumberMismatchError
+					Expect(errors.As(err, &mismatchErr)).To(BeTrue())
+					Expect(mismatchErr.ExpectedSequenceNumber).To(Equal(uint64(0)))
+					Expect(mismatchErr.NextSequenceNumber).To(Equal(uint64(1)))
+				}()
+			}
+			wg.Wait()
+			Expect(successCount.Load()).To(Equal(int64(1)))
+			Expect(writer.AppendEntryAt(1, []byte("bar"))).To(Succeed())
+			Expect(writer.Close()).To(Succeed())
+
+			By("read back the entries")
+			reader, err = wal.NewReader(dir, 0)
+			Expect(err).ToNot(HaveOccurred())
+			for _, entry := range [][]byte{[]byte("foo"), []byte("bar")} {
+				Expect(reader.Next()).To(BeTrue())
+				Expect(reader.Value().Data).To(Equal(entry))
+			}
+			Expect(reader.Next()).To(BeFalse())
+			Expect(reader.Close()).To(Succeed())
+		})
 	})
 
 	for _, entryLengthEncoding := range encoding.EntryLengthEncodings {
@@ -143,6 +1839,126 @@ var _ = Describe("WAL", func() {
 						Expect(reader.Close()).To(Succeed())
 					})
 
+					It("should append batches atomically", func() {
+						By("initialize WAL")
+						Expect(wal.Init(
+							dir,
+							wal.WithEntryLengthEncoding(entryLengthEncoding),
+							wal.WithEntryChecksumType(entryChecksumType),
+							wal.WithPreAllocationSize(0),
+						)).To(Succeed())
+
+						By("write a single entry and a batch")
+						reader, err := wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeFalse())
+						writer, err := reader.ToWriter(syncPolicy, wal.WithPreAllocationSize(0))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(writer.AppendEntry([]byte("foo"))).To(Equal(uint64(0)))
+						Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).To(Equal(uint64(1)))
+						Expect(writer.NextSequenceNumber()).To(Equal(uint64(3)))
+						filePath := writer.FilePath()
+						Expect(writer.Close()).To(Succeed())
+
+						By("simulate a crash while writing the batch")
+						fileInfo, err := os.Stat(filePath)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(os.Truncate(filePath, fileInfo.Size()-1)).To(Succeed())
+
+						By("read back and write the batch again")
+						reader, err = wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().Data).To(Equal([]byte("foo")))
+						Expect(reader.Next()).To(BeFalse())
+						writer, err = reader.ToWriter(syncPolicy, wal.WithPreAllocationSize(0))
+						Expect(err).ToNot(HaveOccurred())
+						Expect(writer.AppendEntries([][]byte{[]byte("bar"), []byte("baz")})).To(Equal(uint64(1)))
+						Expect(writer.Close()).To(Succeed())
+
+						By("read back all entries")
+						reader, err = wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						for i, entry := range [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")} {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().Data).To(Equal(entry))
+							Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+						}
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Close()).To(Succeed())
+					})
+
+					It("should resolve futures of asynchronously appended entries", func() {
+						By("initialize WAL")
+						Expect(wal.Init(dir, wal.WithEntryLengthEncoding(entryLengthEncoding), wal.WithEntryChecksumType(entryChecksumType))).To(Succeed())
+
+						By("write to WAL")
+						reader, err := wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeFalse())
+						writer, err := reader.ToWriter(syncPolicy)
+						Expect(err).ToNot(HaveOccurred())
+						var futures []*wal.SyncFuture
+						for i, entry := range [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")} {
+							sequenceNumber, future, err := writer.AppendEntryAsync(entry)
+							Expect(err).ToNot(HaveOccurred())
+							Expect(sequenceNumber).To(Equal(uint64(i)))
+							futures = append(futures, future)
+						}
+						for _, future := range futures {
+							Eventually(future.Done()).Should(BeClosed())
+							Expect(future.Wait()).To(Succeed())
+						}
+						Expect(writer.Close()).To(Succeed())
+					})
+
+					It("should append entries with a context", func() {
+						By("initialize WAL")
+						Expect(wal.Init(dir, wal.WithEntryLengthEncoding(entryLengthEncoding), wal.WithEntryChecksumType(entryChecksumType))).To(Succeed())
+
+						By("write to WAL")
+						reader, err := wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeFalse())
+						writer, err := reader.ToWriter(syncPolicy)
+						Expect(err).ToNot(HaveOccurred())
+						ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+						defer cancel()
+						for i, entry := range [][]byte{[]byte("foo"), []byte("bar"), []byte("baz")} {
+							Expect(writer.AppendEntryContext(ctx, entry)).To(Equal(uint64(i)))
+						}
+						Expect(writer.CloseContext(ctx)).To(Succeed())
+					})
+
+					It("should append entries from parts and readers", func() {
+						By("initialize WAL")
+						Expect(wal.Init(dir, wal.WithEntryLengthEncoding(entryLengthEncoding), wal.WithEntryChecksumType(entryChecksumType))).To(Succeed())
+
+						By("write to WAL")
+						reader, err := wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeFalse())
+						writer, err := reader.ToWriter(syncPolicy)
+						Expect(err).ToNot(HaveOccurred())
+						bigData := bytes.Repeat([]byte("foo"), math.MaxUint16/3)
+						Expect(writer.AppendEntryParts([]byte("foo"), []byte("bar"))).To(Equal(uint64(0)))
+						Expect(writer.AppendEntryFrom(bytes.NewReader(bigData), int64(len(bigData)))).To(Equal(uint64(1)))
+						Expect(writer.AppendEntryFrom(bytes.NewReader(bigData[:10]), int64(len(bigData)))).Error().To(MatchError(io.ErrUnexpectedEOF))
+						Expect(writer.AppendEntryFrom(strings.NewReader("baz"), 3)).To(Equal(uint64(2)))
+						Expect(writer.Close()).To(Succeed())
+
+						By("read back all entries")
+						reader, err = wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						for i, entry := range [][]byte{[]byte("foobar"), bigData, []byte("baz")} {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().Data).To(Equal(entry))
+							Expect(reader.Value().SequenceNumber).To(Equal(uint64(i)))
+						}
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Close()).To(Succeed())
+					})
+
 					It("should panic to close the reader when the writer was already created", func() {
 						By("initialize WAL")
 						Expect(wal.Init(dir, wal.WithEntryLengthEncoding(entryLengthEncoding), wal.WithEntryChecksumType(entryChecksumType))).To(Succeed())
@@ -201,6 +2017,54 @@ var _ = Describe("WAL", func() {
 						Expect(writer.Close()).To(Succeed())
 					})
 
+					It("should truncate entries after a sequence number", func() {
+						By("initialize WAL")
+						Expect(wal.Init(
+							dir,
+							wal.WithEntryLengthEncoding(entryLengthEncoding),
+							wal.WithEntryChecksumType(entryChecksumType),
+							wal.WithPreAllocationSize(512),
+						)).To(Succeed())
+
+						By("write entries over several segments")
+						reader, err := wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						Expect(reader.Next()).To(BeFalse())
+						writer, err := reader.ToWriter(syncPolicy, wal.WithMaxSegmentSize(512), wal.WithPreAllocationSize(512))
+						Expect(err).ToNot(HaveOccurred())
+						for i := range 10 {
+							Expect(writer.AppendEntry(bytes.Repeat([]byte{byte(i)}, 200))).To(Equal(uint64(i)))
+						}
+						Expect(writer.AppendEntries([][]byte{[]byte("foo"), []byte("bar")})).To(Equal(uint64(10)))
+						Expect(segment.GetSegments(dir)).To(HaveLen(4))
+
+						By("fail to truncate in the wrong places")
+						Expect(writer.TruncateAfter(12)).To(MatchError(wal.ErrTruncateBeyondEnd))
+						Expect(writer.TruncateAfter(10)).To(MatchError(segment.ErrTruncateInBatch))
+						Expect(segment.GetSegments(dir)).To(HaveLen(4))
+						Expect(writer.NextSequenceNumber()).To(Equal(uint64(12)))
+
+						By("truncate after an entry in the middle")
+						Expect(writer.TruncateAfter(11)).To(Succeed())
+						Expect(writer.TruncateAfter(4)).To(Succeed())
+						Expect(segment.GetSegments(dir)).To(Equal([]uint64{0, 3}))
+						Expect(writer.NextSequenceNumber()).To(Equal(uint64(5)))
+						Expect(writer.AppendEntry([]byte("baz"))).To(Equal(uint64(5)))
+						Expect(writer.Close()).To(Succeed())
+
+						By("read back all entries")
+						reader, err = wal.NewReader(dir, 0)
+						Expect(err).ToNot(HaveOccurred())
+						for i := range 5 {
+							Expect(reader.Next()).To(BeTrue())
+							Expect(reader.Value().Data).To(Equal(bytes.Repeat([]byte{byte(i)}, 200)))
+						}
+						Expect(reader.Next()).To(BeTrue())
+						Expect(reader.Value().Data).To(Equal([]byte("baz")))
+						Expect(reader.Next()).To(BeFalse())
+						Expect(reader.Close()).To(Succeed())
+					})
+
 					It("should report correct file names", func() {
 						By("initialize WAL")
 						Expect(wal.Init(
@@ -331,67 +2195,127 @@ var _ = Describe("WAL", func() {
 func BenchmarkReader_Next(b *testing.B) {
 	for _, entryLengthEncoding := range []encoding.EntryLengthEncoding{encoding.DefaultEntryLengthEncoding} {
 		for _, entryChecksumType := range []encoding.EntryChecksumType{encoding.DefaultEntryChecksumType} {
-			for _, dataSize := range []int{0, 1, 2, 4, 8, 16} {
-				dir := b.TempDir()
-				data := make([]byte, dataSize*1024)
-				if err := wal.Init(
-					dir,
-					wal.WithEntryLengthEncoding(entryLengthEncoding),
-					wal.WithEntryChecksumType(entryChecksumType),
-					wal.WithPreAllocationSize(0),
-				); err != nil {
-					b.Fatal(err)
-				}
-				reader, err := wal.NewReader(dir, 0)
-				if err != nil {
-					b.Fatal(err)
-				}
-				reader.Next()
-				writer, err := reader.ToWriter(
-					wal.WithSyncPolicyNone(),
-					wal.WithMaxSegmentSize(math.MaxInt64),
-				)
-				if err != nil {
-					b.Fatal(err)
-				}
-				for range 1000 {
-					if _, err := writer.AppendEntry(data); err != nil {
+			for _, readerVariant := range []struct {
+				name    string
+				options []wal.ReaderOption
+			}{
+				{"unbuffered", []wal.ReaderOption{wal.WithReadBufferSize(0)}},
+				{"buffered", nil},
+				{"readahead", []wal.ReaderOption{wal.WithReadAhead()}},
+				{"mmap", []wal.ReaderOption{wal.WithMmap()}},
+			} {
+				readerName, readerOptions := readerVariant.name, readerVariant.options
+				for _, dataSize := range []int{0, 1, 2, 4, 8, 16} {
+					dir := b.TempDir()
+					data := make([]byte, dataSize*1024)
+					if err := wal.Init(
+						dir,
+						wal.WithEntryLengthEncoding(entryLengthEncoding),
+						wal.WithEntryChecksumType(entryChecksumType),
+						wal.WithPreAllocationSize(0),
+					); err != nil {
 						b.Fatal(err)
 					}
-				}
-				if err := writer.Close(); err != nil {
-					b.Fatal(err)
-				}
-				reader, err = wal.NewReader(dir, 0)
-				if err != nil {
-					b.Fatal(err)
-				}
-				// Do one read before the test to prime the data buffer with the correct size.
-				reader.Next()
-				b.Run(fmt.Sprintf("%s %s %d KB", entryLengthEncoding, entryChecksumType, dataSize), func(b *testing.B) {
-					for range b.N {
-						if !reader.Next() {
-							if err := reader.Close(); err != nil {
-								b.Fatal(err)
-							}
-							reader, err = wal.NewReader(dir, 0)
-							if err != nil {
-								b.Fatal(err)
+					reader, err := wal.NewReader(dir, 0)
+					if err != nil {
+						b.Fatal(err)
+					}
+					reader.Next()
+					writer, err := reader.ToWriter(
+						wal.WithSyncPolicyNone(),
+						wal.WithMaxSegmentSize(math.MaxInt64),
+					)
+					if err != nil {
+						b.Fatal(err)
+					}
+					for range 1000 {
+						if _, err := writer.AppendEntry(data); err != nil {
+							b.Fatal(err)
+						}
+					}
+					// Seal the segment with the entries, as only sealed segments are mapped into memory.
+					if err := writer.Rollover(); err != nil {
+						b.Fatal(err)
+					}
+					if err := writer.Close(); err != nil {
+						b.Fatal(err)
+					}
+					reader, err = wal.NewReader(dir, 0, readerOptions...)
+					if err != nil {
+						b.Fatal(err)
+					}
+					// Do one read before the test to prime the data buffer with the correct size.
+					reader.Next()
+					b.Run(fmt.Sprintf("%s %s %s %d KB", entryLengthEncoding, entryChecksumType, readerName, dataSize), func(b *testing.B) {
+						for range b.N {
+							if !reader.Next() {
+								if err := reader.Close(); err != nil {
+									b.Fatal(err)
+								}
+								reader, err = wal.NewReader(dir, 0, readerOptions...)
+								if err != nil {
+									b.Fatal(err)
+								}
 							}
 						}
+						timeNeeded := b.Elapsed().Seconds()
+						dataRead := b.N * dataSize * 1024
+						b.ReportMetric(float64(dataRead/1024/1024)/timeNeeded, "MB/s")
+					})
+					if err := reader.Close(); err != nil {
+						b.Fatal(err)
 					}
-					timeNeeded := b.Elapsed().Seconds()
-					dataRead := b.N * dataSize * 1024
-					b.ReportMetric(float64(dataRead/1024/1024)/timeNeeded, "MB/s")
-				})
-				if err := reader.Close(); err != nil {
-					b.Fatal(err)
 				}
 			}
 		}
 	}
 }
 
+func BenchmarkReplay(b *testing.B) {
+	dir := b.TempDir()
+	data := make([]byte, 4*1024)
+	if err := wal.Init(dir, wal.WithEntryChecksumType(encoding.EntryChecksumTypeCrc64), wal.WithPreAllocationSize(0)); err != nil {
+		b.Fatal(err)
+	}
+	reader, err := wal.NewReader(dir, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	reader.Next()
+	writer, err := reader.ToWriter(
+		wal.WithSyncPolicyNone(),
+		wal.WithPreAllocationSize(0),
+		wal.WithMaxSegmentEntries(1000),
+	)
+	if err != nil {
+		b.Fatal(err)
+	}
+	for range 8 * 1000 {
+		if _, err := writer.AppendEntry(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		b.Fatal(err)
+	}
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("%d workers", workers), func(b *testing.B) {
+			dataRead := 0
+			for range b.N {
+				entries, entriesErr := wal.Replay(dir, 0, workers)
+				for _, entryData := range entries {
+					dataRead += len(entryData)
+				}
+				if err := entriesErr(); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(dataRead/1024/1024)/b.Elapsed().Seconds(), "MB/s")
+		})
+	}
+}
+
 //nolint:gocognit,cyclop
 func BenchmarkWriter_AppendEntry_Serial(b *testing.B) {
 	for _, entryLengthEncoding := range []encoding.EntryLengthEncoding{encoding.DefaultEntryLengthEncoding} {
@@ -518,3 +2442,13 @@ func BenchmarkWriter_AppendEntry_Concurrently(b *testing.B) {
 		}
 	}
 }
+
+// syncTotal returns the number of times segment files were flushed to stable storage.
+func syncTotal() float64 {
+	registry := prometheus.NewRegistry()
+	Expect(registry.Register(segment.SyncTotal)).To(Succeed())
+	metricFamilies, err := registry.Gather()
+	Expect(err).ToNot(HaveOccurred())
+	Expect(metricFamilies).To(HaveLen(1))
+	return metricFamilies[0].GetMetric()[0].GetCounter().GetValue()
+}
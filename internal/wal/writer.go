@@ -1,18 +1,67 @@
 package wal
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log"
+	"maps"
 	"path"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/backbone81/write-ahead-log/internal/encoding"
 	"github.com/backbone81/write-ahead-log/internal/segment"
+	"github.com/backbone81/write-ahead-log/internal/utils"
 )
 
+var (
+	ErrWriterClosed      = errors.New("the WAL writer is already closed")
+	ErrTruncateBeyondEnd = errors.New("the WAL does not contain the sequence number to truncate after")
+	ErrTruncated         = errors.New("the WAL entry was removed by a truncation before it was flushed")
+	ErrEntryTypeReserved = errors.New("the WAL entry type is reserved for control records")
+)
+
+// SequenceNumberMismatchError is returned by Writer.AppendEntryAt when the next sequence number of the WAL is not the
+// expected one.
+type SequenceNumberMismatchError struct {
+	// ExpectedSequenceNumber is the sequence number the caller expected the entry to receive.
+	ExpectedSequenceNumber uint64
+
+	// NextSequenceNumber is the sequence number the entry would have received.
+	NextSequenceNumber uint64
+}
+
+func (e *SequenceNumberMismatchError) Error() string {
+	return fmt.Sprintf(
+		"expected the WAL entry to receive sequence number %d but the next sequence number is %d",
+		e.ExpectedSequenceNumber,
+		e.NextSequenceNumber,
+	)
+}
+
+// BackpressureError is returned when appending fails, because the number of entries or bytes not yet flushed to stable
+// storage reached the limits configured with WithMaxUnsyncedEntries or WithMaxUnsyncedBytes. This only happens when
+// WithFailFastOnBackpressure is used. Otherwise, appending flushes the entries before continuing.
+type BackpressureError struct {
+	// UnsyncedEntries is the number of entries not yet flushed.
+	UnsyncedEntries uint64
+
+	// UnsyncedBytes is the number of bytes not yet flushed.
+	UnsyncedBytes int64
+}
+
+func (e *BackpressureError) Error() string {
+	return fmt.Sprintf(
+		"the WAL has %d entries with %d bytes which are not yet flushed to stable storage",
+		e.UnsyncedEntries,
+		e.UnsyncedBytes,
+	)
+}
+
 // Writer provides the main functionality for writing to the write-ahead log. It abstracts away the fact that the WAL
 // is distributed over several segment files and does rollover into new segments as necessary.
 //
@@ -21,17 +70,60 @@ import (
 // You can only create a writer with the Reader.ToWriter function. This makes sure that you have read all entries before
 // writing to the write-ahead log.
 type Writer struct {
-	mutex sync.Mutex
+	mutex utils.ContextMutex
 
 	segmentWriter *segment.SegmentWriter
 	syncPolicy    SyncPolicy
+	syncTracker   *SyncTracker
 
 	preAllocationSize   int64
 	maxSegmentSize      int64
+	maxSegmentAge       time.Duration
+	maxSegmentEntries   uint64
+	maxUnsyncedEntries  uint64
+	maxUnsyncedBytes    int64
 	firstSequenceNumber uint64
 	entryLengthEncoding encoding.EntryLengthEncoding
 	entryChecksumType   encoding.EntryChecksumType
 	rolloverCallback    RolloverCallback
+	retentionPolicy     RetentionPolicy
+	retentionFloor      RetentionFloor
+
+	// The metadata stored in the header of new segments. The creation time is set when the segment is created.
+	metadata encoding.HeaderMetadata
+
+	// The key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain.
+	entryChecksumKey []byte
+
+	// The compression of new segments and the entry size in bytes above which entries are compressed.
+	compression          encoding.Compression
+	compressionThreshold int
+
+	// The entry compressors for compressing entries before the writer lock is taken. This allows several Go routines to
+	// compress their entries in parallel.
+	entryCompressors sync.Pool
+
+	// Reports if appending fails with a BackpressureError instead of blocking when the unsynced limits are reached.
+	failFastOnBackpressure bool
+
+	// The time the first entry was appended to the current segment. This is the zero time for empty segments.
+	segmentStartTime time.Time
+
+	// The timer triggering a rollover when the current segment reaches the maximum segment age.
+	rolloverTimer *time.Timer
+
+	// Signals the retention Go routine that a rollover happened. This is nil when the retention policy never removes
+	// any segments.
+	retentionSignal chan struct{}
+
+	// Stops the retention Go routine.
+	retentionCancel context.CancelFunc
+
+	// The key the sync tracker is registered with for readers in the same process.
+	liveSyncTrackerKey string
+
+	// Reports if the writer was closed. Closing the writer a second time is a no-op.
+	closed bool
 }
 
 // RolloverCallback is the callback users can register for getting notified when a rollover of a segment file happens.
@@ -63,6 +155,56 @@ func WithMaxSegmentSize(maxSegmentSize int64) WriterOption {
 	}
 }
 
+// WithMaxSegmentAge causes rollover into a new segment when the first entry in the current segment was appended longer
+// ago than the given duration. The rollover happens in the background, even when no further entries are appended.
+// Empty segments are never rolled over. When continuing to write to an existing segment, the age is counted from the
+// first entry appended by the writer. Zero disables rollover by age, which is the default.
+// Can be used with Reader.ToWriter.
+func WithMaxSegmentAge(maxSegmentAge time.Duration) WriterOption {
+	return func(w *Writer) {
+		w.maxSegmentAge = max(maxSegmentAge, 0)
+	}
+}
+
+// WithMaxSegmentEntries causes rollover into a new segment when the current segment contains the given number of
+// entries. As batches are never split over several segments, a segment can contain more entries when a batch was
+// appended. Zero disables rollover by entry count, which is the default.
+// Can be used with Reader.ToWriter.
+func WithMaxSegmentEntries(maxSegmentEntries uint64) WriterOption {
+	return func(w *Writer) {
+		w.maxSegmentEntries = maxSegmentEntries
+	}
+}
+
+// WithMaxUnsyncedEntries limits the number of entries which are appended but not yet flushed to stable storage. When
+// the limit is reached, the next append asks the sync policy to flush all entries before appending. Zero disables the
+// limit, which is the default.
+// Can be used with Reader.ToWriter.
+func WithMaxUnsyncedEntries(maxUnsyncedEntries uint64) WriterOption {
+	return func(w *Writer) {
+		w.maxUnsyncedEntries = maxUnsyncedEntries
+	}
+}
+
+// WithMaxUnsyncedBytes limits the number of bytes which are appended but not yet flushed to stable storage. When the
+// limit is reached, the next append asks the sync policy to flush all entries before appending. Zero disables the
+// limit, which is the default.
+// Can be used with Reader.ToWriter.
+func WithMaxUnsyncedBytes(maxUnsyncedBytes int64) WriterOption {
+	return func(w *Writer) {
+		w.maxUnsyncedBytes = max(maxUnsyncedBytes, 0)
+	}
+}
+
+// WithFailFastOnBackpressure causes appending to fail with a BackpressureError instead of flushing, when the limits
+// of WithMaxUnsyncedEntries or WithMaxUnsyncedBytes are reached. The entries are flushed by the sync policy as usual.
+// Can be used with Reader.ToWriter.
+func WithFailFastOnBackpressure() WriterOption {
+	return func(w *Writer) {
+		w.failFastOnBackpressure = true
+	}
+}
+
 // WithEntryLengthEncoding overwrites the default entry length encoding.
 // Can be used with Init and Reader.ToWriter.
 func WithEntryLengthEncoding(entryLengthEncoding encoding.EntryLengthEncoding) WriterOption {
@@ -79,6 +221,66 @@ func WithEntryChecksumType(entryChecksumType encoding.EntryChecksumType) WriterO
 	}
 }
 
+// WithEntryChecksumKey provides the secret key for the entry checksum type encoding.EntryChecksumTypeHmacSha256Chain.
+// The same key is needed for reading the write-ahead log later on. With Reader.ToWriter, the key given with
+// WithReaderEntryChecksumKey is used by default.
+// Can be used with Init and Reader.ToWriter.
+func WithEntryChecksumKey(key []byte) WriterOption {
+	return func(w *Writer) {
+		w.entryChecksumKey = key
+	}
+}
+
+// WithCompression compresses entries bigger than the compression threshold with the given compression. The compression
+// is stored in the header of new segment files. With Reader.ToWriter, the compression of the segment file which is
+// continued is used by default. Entries which do not get smaller are stored uncompressed. Entries are compressed
+// before the writer is locked, so that concurrent appends compress in parallel. Batches appended with AppendEntries
+// are the exception, as their encoding depends on the segment file they are written to.
+// Can be used with Init and Reader.ToWriter.
+func WithCompression(compression encoding.Compression) WriterOption {
+	return func(w *Writer) {
+		w.compression = compression
+	}
+}
+
+// WithCompressionThreshold overwrites the default entry size in bytes above which entries are compressed. This has no
+// effect without WithCompression.
+// Can be used with Reader.ToWriter.
+func WithCompressionThreshold(compressionThreshold int) WriterOption {
+	return func(w *Writer) {
+		w.compressionThreshold = compressionThreshold
+	}
+}
+
+// WithWriterID stores the given identifier of the writer in the header of new segment files, like the host name or the
+// version of your service. This helps identifying who wrote a segment file.
+// Can be used with Init and Reader.ToWriter.
+func WithWriterID(writerID string) WriterOption {
+	return func(w *Writer) {
+		w.metadata.WriterID = writerID
+	}
+}
+
+// WithApplicationID stores the given identifier of the application in the header of new segment files.
+// Can be used with Init and Reader.ToWriter.
+func WithApplicationID(applicationID string) WriterOption {
+	return func(w *Writer) {
+		w.metadata.ApplicationID = applicationID
+	}
+}
+
+// WithUserMetadata stores the given key value in the header of new segment files, like the version of the schema your
+// entries are encoded with. Use this option multiple times for storing multiple key values.
+// Can be used with Init and Reader.ToWriter.
+func WithUserMetadata(key string, value string) WriterOption {
+	return func(w *Writer) {
+		userData := make(map[string]string, len(w.metadata.UserData)+1)
+		maps.Copy(userData, w.metadata.UserData)
+		userData[key] = value
+		w.metadata.UserData = userData
+	}
+}
+
 // WithSyncPolicyNone overwrites the default sync policy with sync policy none.
 // Can be used with Reader.ToWriter.
 func WithSyncPolicyNone() WriterOption {
@@ -119,6 +321,50 @@ func WithRolloverCallback(rolloverCallback RolloverCallback) WriterOption {
 	}
 }
 
+// WithRetentionPolicy overwrites the default retention policy with the given retention policy. Use this for providing
+// your own retention policy.
+// Can be used with Reader.ToWriter.
+func WithRetentionPolicy(retentionPolicy RetentionPolicy) WriterOption {
+	return func(w *Writer) {
+		w.retentionPolicy = retentionPolicy
+	}
+}
+
+// WithRetentionPolicyMaxBytes overwrites the default retention policy with retention policy max bytes.
+// Can be used with Reader.ToWriter.
+func WithRetentionPolicyMaxBytes(maxBytes int64) WriterOption {
+	return func(w *Writer) {
+		w.retentionPolicy = NewRetentionPolicyMaxBytes(maxBytes)
+	}
+}
+
+// WithRetentionPolicyMaxAge overwrites the default retention policy with retention policy max age.
+// Can be used with Reader.ToWriter.
+func WithRetentionPolicyMaxAge(maxAge time.Duration) WriterOption {
+	return func(w *Writer) {
+		w.retentionPolicy = NewRetentionPolicyMaxAge(maxAge)
+	}
+}
+
+// WithRetentionPolicyMaxSegments overwrites the default retention policy with retention policy max segments.
+// Can be used with Reader.ToWriter.
+func WithRetentionPolicyMaxSegments(maxSegments int) WriterOption {
+	return func(w *Writer) {
+		w.retentionPolicy = NewRetentionPolicyMaxSegments(maxSegments)
+	}
+}
+
+// WithRetentionFloor sets the callback which provides the lowest sequence number still needed. The retention policy
+// never removes segments containing entries at or above that sequence number. This is usually the sequence number of
+// your last checkpoint, or the sequence number of the slowest reader following the writer. The callback is called on
+// a separate Go routine without holding the writer lock, so it can call into the writer.
+// Can be used with Reader.ToWriter.
+func WithRetentionFloor(retentionFloor RetentionFloor) WriterOption {
+	return func(w *Writer) {
+		w.retentionFloor = retentionFloor
+	}
+}
+
 // FilePath returns the file path of the file this writer is writing to.
 func (w *Writer) FilePath() string {
 	w.mutex.Lock()
@@ -172,47 +418,665 @@ func (w *Writer) AppendEntry(data []byte) (uint64, error) {
 	return sequenceNumber, nil
 }
 
+// AppendEntryAsync appends the given data as a new entry to the write-ahead log. In contrast to AppendEntry, it returns
+// right after the entry was written to the segment file without waiting for the sync policy to flush it to stable
+// storage. The returned future is resolved when the entry was flushed.
+// It will roll over to the next segment file before appending if the current file size exceeds the desired maximum
+// segment size.
+func (w *Writer) AppendEntryAsync(data []byte) (uint64, *SyncFuture, error) {
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.Compress(data)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return w.appendAsync(func() (uint64, error) {
+		return w.appendCompressedEntryLocked(compressedEntry)
+	})
+}
+
+// appendAsync appends an entry with the given function under the writer lock and notifies the sync policy afterward
+// without waiting for the flush.
+func (w *Writer) appendAsync(appendLocked func() (uint64, error)) (uint64, *SyncFuture, error) {
+	sequenceNumber, future, err := func() (uint64, *SyncFuture, error) {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		sequenceNumber, err := appendLocked()
+		if err != nil {
+			return 0, nil, err
+		}
+		// The future needs to be registered under the writer lock. Otherwise, TruncateAfter could remove the entry
+		// before the future exists, and the future would be resolved when a different entry receives the same sequence
+		// number.
+		return sequenceNumber, w.syncTracker.Future(sequenceNumber), nil
+	}()
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if err := w.syncPolicy.EntryAppendedAsync(sequenceNumber); err != nil {
+		return 0, nil, err
+	}
+	return sequenceNumber, future, nil
+}
+
+// AppendEntryContext appends the given data as a new entry to the write-ahead log. It behaves the same way as
+// AppendEntry, but gives up waiting for the writer or the sync policy when the context is done.
+// When the context is done before the entry was written, no entry is appended and the error of the context is
+// returned. When the context is done after the entry was written but before it was flushed, the sequence number of
+// the entry is returned together with the error of the context. The entry might still be flushed to stable storage
+// later on in that situation.
+func (w *Writer) AppendEntryContext(ctx context.Context, data []byte) (uint64, error) {
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.Compress(data)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.appendContext(ctx, func() (uint64, error) {
+		return w.appendCompressedEntryLocked(compressedEntry)
+	})
+}
+
+// appendContext appends an entry with the given function under the writer lock and waits for the sync policy to flush
+// it afterward. It gives up waiting when the context is done.
+func (w *Writer) appendContext(ctx context.Context, appendLocked func() (uint64, error)) (uint64, error) {
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return 0, err
+	}
+	sequenceNumber, err := appendLocked()
+	var future *SyncFuture
+	if err == nil {
+		future = w.syncTracker.Future(sequenceNumber)
+	}
+	w.mutex.Unlock()
+	if err != nil {
+		return 0, err
+	}
+
+	// We do not use the blocking EntryAppended of the sync policy, because we would not be able to give up waiting.
+	if err := w.syncPolicy.EntryAppendedAsync(sequenceNumber); err != nil {
+		return 0, err
+	}
+	select {
+	case <-future.Done():
+		if err := future.Wait(); err != nil {
+			return 0, err
+		}
+		return sequenceNumber, nil
+	case <-ctx.Done():
+		return sequenceNumber, ctx.Err()
+	}
+}
+
 func (w *Writer) appendEntry(data []byte) (uint64, error) {
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.Compress(data)
+	if err != nil {
+		return 0, err
+	}
+
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
-	if err := w.rolloverIfNeeded(); err != nil {
+	return w.appendCompressedEntryLocked(compressedEntry)
+}
+
+// entryCompressor takes an entry compressor from the pool. Entries are compressed before the writer lock is taken, as
+// compressing big entries takes a lot longer than writing them. Hand the entry compressor back to the pool when the
+// entry was appended.
+func (w *Writer) entryCompressor() *segment.EntryCompressor {
+	return w.entryCompressors.Get().(*segment.EntryCompressor) //nolint:forcetypeassert // We only store entry compressors in the pool.
+}
+
+// appendCompressedEntryLocked appends the compressed entry to the segment. The caller must hold the mutex.
+func (w *Writer) appendCompressedEntryLocked(compressedEntry segment.CompressedEntry) (uint64, error) {
+	if err := w.prepareAppendLocked(); err != nil {
+		return 0, err
+	}
+	offset := w.segmentWriter.Offset()
+	sequenceNumber, err := w.segmentWriter.AppendCompressedEntry(compressedEntry)
+	if err != nil {
+		return 0, fmt.Errorf("writing entry to segment file: %w", err)
+	}
+	w.appendedLocked(offset)
+	return sequenceNumber, nil
+}
+
+// prepareAppendLocked makes sure that the writer is ready for appending another entry. The caller must hold the lock.
+// When the number of unsynced entries or bytes reached its limit, the sync policy is asked to flush them first.
+func (w *Writer) prepareAppendLocked() error {
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.maxUnsyncedEntries > 0 || w.maxUnsyncedBytes > 0 {
+		if err := w.flushUnsyncedLocked(); err != nil {
+			return err
+		}
+	}
+	return w.rolloverIfNeeded()
+}
+
+// flushUnsyncedLocked asks the sync policy to flush all entries appended so far, when the number of unsynced entries or
+// bytes reached their limits. This keeps the writer from waiting for a flush the sync policy might only do much later.
+// With fail fast enabled, a BackpressureError is returned instead of flushing. The caller must hold the lock.
+func (w *Writer) flushUnsyncedLocked() error {
+	unsyncedEntries, unsyncedBytes := w.syncTracker.Unsynced()
+	if (w.maxUnsyncedEntries == 0 || unsyncedEntries < w.maxUnsyncedEntries) &&
+		(w.maxUnsyncedBytes == 0 || unsyncedBytes < w.maxUnsyncedBytes) {
+		return nil
+	}
+	if w.failFastOnBackpressure {
+		return &BackpressureError{
+			UnsyncedEntries: unsyncedEntries,
+			UnsyncedBytes:   unsyncedBytes,
+		}
+	}
+	return w.syncPolicy.Flush(w.segmentWriter.NextSequenceNumber())
+}
+
+// appendedLocked is called after entries were appended since the segment was at the given offset. The age of the
+// segment starts with its first entry. The entries are reported to the sync tracker, when the number of unsynced
+// entries or bytes is limited. The caller must hold the lock.
+func (w *Writer) appendedLocked(offset int64) {
+	if w.segmentStartTime.IsZero() {
+		w.startSegmentAge()
+	}
+	if w.maxUnsyncedEntries == 0 && w.maxUnsyncedBytes == 0 {
+		return
+	}
+	w.syncTracker.Appended(w.segmentWriter.NextSequenceNumber(), w.segmentWriter.Offset()-offset)
+}
+
+// AppendEntryParts adds a single entry to the WAL which consists of the concatenation of all given parts. This avoids
+// copying the parts into a contiguous slice of bytes, when the entry is assembled from several buffers like a header
+// and a payload. The entry is indistinguishable from one appended with AppendEntry.
+func (w *Writer) AppendEntryParts(parts ...[]byte) (uint64, error) {
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.CompressParts(parts...)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.appendEntryWith(func(segmentWriter *segment.SegmentWriter) (uint64, error) {
+		sequenceNumber, err := segmentWriter.AppendCompressedEntry(compressedEntry)
+		if err != nil {
+			return 0, fmt.Errorf("writing entry to segment file: %w", err)
+		}
+		return sequenceNumber, nil
+	})
+}
+
+// AppendEntryFrom adds a single entry to the WAL which consists of exactly size bytes read from the reader. Entries of
+// up to 32 KiB are read into memory and compressed the same way as with AppendEntry. Bigger entries are streamed to the
+// segment file in chunks without holding the whole entry in memory and are never compressed.
+// When the reader returns fewer bytes than announced or fails, the entry is not appended and the WAL stays intact.
+// Note that the writer is locked while reading from the reader. A slow reader blocks all other writes.
+func (w *Writer) AppendEntryFrom(reader io.Reader, size int64) (uint64, error) {
+	return w.appendEntryWith(func(segmentWriter *segment.SegmentWriter) (uint64, error) {
+		sequenceNumber, err := segmentWriter.AppendEntryFrom(reader, size)
+		if err != nil {
+			return 0, fmt.Errorf("writing entry to segment file: %w", err)
+		}
+		return sequenceNumber, nil
+	})
+}
+
+// AppendEntryAt adds the given entry to the WAL only when it receives the expected sequence number. Otherwise, a
+// SequenceNumberMismatchError is returned and nothing is written. Checking the sequence number and appending the entry
+// happen atomically. This is needed by replicas applying the log of a leader or for optimistic concurrency control,
+// where checking NextSequenceNumber before calling AppendEntry would be racy.
+func (w *Writer) AppendEntryAt(expectedSequenceNumber uint64, data []byte) error {
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.Compress(data)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.appendEntryWith(func(segmentWriter *segment.SegmentWriter) (uint64, error) {
+		if nextSequenceNumber := segmentWriter.NextSequenceNumber(); nextSequenceNumber != expectedSequenceNumber {
+			return 0, &SequenceNumberMismatchError{
+				ExpectedSequenceNumber: expectedSequenceNumber,
+				NextSequenceNumber:     nextSequenceNumber,
+			}
+		}
+		sequenceNumber, err := segmentWriter.AppendCompressedEntry(compressedEntry)
+		if err != nil {
+			return 0, fmt.Errorf("writing entry to segment file: %w", err)
+		}
+		return sequenceNumber, nil
+	})
+	return err
+}
+
+// appendEntryWith appends an entry with the given function under the writer lock and notifies the sync policy
+// afterward.
+func (w *Writer) appendEntryWith(appendFunc func(segmentWriter *segment.SegmentWriter) (uint64, error)) (uint64, error) {
+	sequenceNumber, err := func() (uint64, error) {
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		if err := w.prepareAppendLocked(); err != nil {
+			return 0, err
+		}
+		offset := w.segmentWriter.Offset()
+		sequenceNumber, err := appendFunc(w.segmentWriter)
+		if err != nil {
+			return 0, err
+		}
+		w.appendedLocked(offset)
+		return sequenceNumber, nil
+	}()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.syncPolicy.EntryAppended(sequenceNumber); err != nil {
+		return 0, err
+	}
+	return sequenceNumber, nil
+}
+
+// AppendEntries appends all given entries as a single batch to the write-ahead log. The batch is written atomically:
+// after a crash, a reader either sees all entries of the batch or none of them. Every entry in the batch receives its
+// own sequence number. The return value is the sequence number of the first entry in the batch.
+// It will roll over to the next segment file before appending if the current file size exceeds the desired maximum
+// segment size. A batch is never split over multiple segment files.
+func (w *Writer) AppendEntries(entries [][]byte) (uint64, error) {
+	sequenceNumber, err := w.appendEntries(entries)
+	if err != nil {
+		return 0, err
+	}
+
+	// We only need to wait for the last entry of the batch, as the sync policy flushes all entries before it as well.
+	if err := w.syncPolicy.EntryAppended(sequenceNumber + uint64(len(entries)) - 1); err != nil {
+		return 0, err
+	}
+	return sequenceNumber, nil
+}
+
+func (w *Writer) appendEntries(entries [][]byte) (uint64, error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if err := w.prepareAppendLocked(); err != nil {
+		return 0, err
+	}
+	if len(entries) > 1 {
+		if err := w.requireEntryFlagsLocked(); err != nil {
+			return 0, err
+		}
+	}
+	offset := w.segmentWriter.Offset()
+	sequenceNumber, err := w.segmentWriter.AppendEntries(entries)
+	if err != nil {
+		return 0, fmt.Errorf("writing entries to segment file: %w", err)
+	}
+	w.appendedLocked(offset)
+	return sequenceNumber, nil
+}
+
+// requireEntryFlagsLocked makes sure that the segment file we are writing to supports entry flags. When the segment
+// file has an older version which does not support entry flags, we roll over into a new segment file which is always
+// created with the current version. An empty segment file is replaced instead, as the new segment file would have the
+// same name. The caller must hold the lock.
+func (w *Writer) requireEntryFlagsLocked() error {
+	if encoding.EntryFlagsSize(w.segmentWriter.Header().Version) > 0 {
+		return nil
+	}
+	if w.segmentEmpty() {
+		return w.replaceEmptySegment()
+	}
+	return w.rollover()
+}
+
+// AppendTypedEntry appends the given entry together with its entry type, flags and headers to the write-ahead log. This
+// allows distinguishing different kinds of entries and attaching metadata to them without an envelope inside the
+// entry data. Readers find them in Reader.Value().Type, .Flags and .Headers.
+// Returns ErrEntryTypeReserved for entry types which are reserved for control records.
+// It will roll over to the next segment file before appending if the current file size exceeds the desired maximum
+// segment size.
+func (w *Writer) AppendTypedEntry(entry segment.TypedEntry) (uint64, error) {
+	if entry.Type.IsControl() {
+		return 0, ErrEntryTypeReserved
+	}
+	return w.appendTypedEntry(entry)
+}
+
+// AppendTypedEntryAsync appends the given entry together with its entry type, flags and headers to the write-ahead log.
+// It behaves the same way as AppendTypedEntry, but returns right after the entry was written to the segment file like
+// AppendEntryAsync. The returned future is resolved when the entry was flushed.
+func (w *Writer) AppendTypedEntryAsync(entry segment.TypedEntry) (uint64, *SyncFuture, error) {
+	if entry.Type.IsControl() {
+		return 0, nil, ErrEntryTypeReserved
+	}
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.CompressTypedEntry(entry)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	return w.appendAsync(func() (uint64, error) {
+		return w.appendTypedEntryLocked(compressedEntry)
+	})
+}
+
+// AppendTypedEntryContext appends the given entry together with its entry type, flags and headers to the write-ahead
+// log. It behaves the same way as AppendTypedEntry, but gives up waiting for the writer or the sync policy when the
+// context is done. See AppendEntryContext for the details.
+func (w *Writer) AppendTypedEntryContext(ctx context.Context, entry segment.TypedEntry) (uint64, error) {
+	if entry.Type.IsControl() {
+		return 0, ErrEntryTypeReserved
+	}
+	entryCompressor := w.entryCompressor()
+	defer w.entryCompressors.Put(entryCompressor)
+	compressedEntry, err := entryCompressor.CompressTypedEntry(entry)
+	if err != nil {
+		return 0, err
+	}
+
+	return w.appendContext(ctx, func() (uint64, error) {
+		return w.appendTypedEntryLocked(compressedEntry)
+	})
+}
+
+// AppendCheckpoint appends a checkpoint control record with the given data to the write-ahead log. Applications use
+// checkpoints to mark the point up to which their state was persisted, with the data describing that state, like the
+// location of a snapshot. The checkpoint receives a sequence number like every other entry.
+// Readers skip control records unless created with WithControlRecords. Finding the most recent checkpoint works best
+// with a ReverseReader.
+func (w *Writer) AppendCheckpoint(data []byte) (uint64, error) {
+	return w.appendTypedEntry(segment.TypedEntry{
+		Type: encoding.EntryTypeCheckpoint,
+		Data: data,
+	})
+}
+
+// appendTypedEntry appends the entry under the writer lock and notifies the sync policy afterward. In contrast to
+// AppendTypedEntry, control records are accepted.
+func (w *Writer) appendTypedEntry(entry segment.TypedEntry) (uint64, error) {
+	sequenceNumber, err := func() (uint64, error) {
+		entryCompressor := w.entryCompressor()
+		defer w.entryCompressors.Put(entryCompressor)
+		compressedEntry, err := entryCompressor.CompressTypedEntry(entry)
+		if err != nil {
+			return 0, err
+		}
+
+		w.mutex.Lock()
+		defer w.mutex.Unlock()
+
+		return w.appendTypedEntryLocked(compressedEntry)
+	}()
+	if err != nil {
+		return 0, err
+	}
+
+	if err := w.syncPolicy.EntryAppended(sequenceNumber); err != nil {
+		return 0, err
+	}
+	return sequenceNumber, nil
+}
+
+// appendTypedEntryLocked appends the compressed typed entry to the segment. The caller must hold the mutex.
+func (w *Writer) appendTypedEntryLocked(compressedEntry segment.CompressedEntry) (uint64, error) {
+	if err := w.prepareAppendLocked(); err != nil {
 		return 0, err
 	}
-	sequenceNumber, err := w.segmentWriter.AppendEntry(data)
+	if err := w.requireEntryFlagsLocked(); err != nil {
+		return 0, err
+	}
+	offset := w.segmentWriter.Offset()
+	sequenceNumber, err := w.segmentWriter.AppendCompressedEntry(compressedEntry)
 	if err != nil {
 		return 0, fmt.Errorf("writing entry to segment file: %w", err)
 	}
+	w.appendedLocked(offset)
 	return sequenceNumber, nil
 }
 
+// TruncateAfter removes all entries with a sequence number greater than the given sequence number from the WAL. Later
+// segment files are deleted as a whole, the segment file containing the sequence number is truncated. The writer
+// continues appending at the given sequence number plus one afterward. This is needed for discarding uncommitted
+// entries, like when resolving conflicts with a leader in a replicated log.
+// Returns ErrTruncateBeyondEnd when the WAL does not contain the given sequence number. Truncating in the middle of a
+// batch is not possible and returns segment.ErrTruncateInBatch. Both are detected before any segment file is touched,
+// so the writer stays usable. When the truncation fails after the segment files were touched, the writer is closed.
+// Futures of removed entries which are not yet resolved fail with ErrTruncated.
+func (w *Writer) TruncateAfter(sequenceNumber uint64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	nextSequenceNumber := w.segmentWriter.NextSequenceNumber()
+	if sequenceNumber >= nextSequenceNumber {
+		return ErrTruncateBeyondEnd
+	}
+	if sequenceNumber+1 == nextSequenceNumber {
+		// There are no entries after the sequence number, so there is nothing to do.
+		return nil
+	}
+
+	directory := path.Dir(w.segmentWriter.FilePath())
+	targetSegment, err := segment.SegmentFromSequenceNumber(directory, sequenceNumber)
+	if err != nil {
+		return err
+	}
+	segments, err := segment.GetSegments(directory)
+	if err != nil {
+		return err
+	}
+	if err := segment.CheckTruncateSegment(directory, targetSegment, sequenceNumber+1, w.entryChecksumKey); err != nil {
+		return err
+	}
+
+	// From here on, the writer is not usable anymore, if anything fails.
+	w.closed = true
+	w.stopSegmentAge()
+	if err := w.syncPolicy.Shutdown(); err != nil {
+		return errors.Join(err, w.segmentWriter.Close())
+	}
+	if err := w.segmentWriter.Close(); err != nil {
+		return err
+	}
+
+	// We remove the segments from newest to oldest. When we crash in between, the WAL still contains a consistent
+	// sequence of entries.
+	for _, segmentNumber := range slices.Backward(segments) {
+		if segmentNumber <= targetSegment {
+			break
+		}
+		if err := segment.RemoveSegment(directory, segmentNumber); err != nil {
+			return err
+		}
+	}
+
+	segmentWriter, err := segment.TruncateSegment(directory, targetSegment, sequenceNumber+1, w.preAllocationSize, w.entryChecksumKey)
+	if err != nil {
+		return err
+	}
+	w.segmentWriter = segmentWriter
+	w.segmentWriter.SetCompressionThreshold(w.compressionThreshold)
+	w.syncTracker.Reset(sequenceNumber + 1)
+	w.syncPolicy.Reset(sequenceNumber + 1)
+	if err := w.syncPolicy.Startup(w.segmentWriter, w.syncTracker); err != nil {
+		return errors.Join(err, w.segmentWriter.Close())
+	}
+	w.closed = false
+	return nil
+}
+
+// RemoveBefore removes all segment files which only contain entries with a sequence number below the given sequence
+// number. The active segment file and the segment file containing the sequence number are never removed. In contrast
+// to segment.RemoveBefore, this does not run concurrently with a rollover or truncation of the writer.
+func (w *Writer) RemoveBefore(sequenceNumber uint64) error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	return segment.RemoveBefore(path.Dir(w.segmentWriter.FilePath()), sequenceNumber)
+}
+
+// NewReader creates a new Reader starting at the given sequence number while the writer keeps appending entries. The
+// reader only returns entries which were flushed to stable storage at the time of each call to Next. Use NextWait to
+// wait for more entries. The reader opens the segment files read-only and can therefore not be converted into a writer.
+// Every reader is independent of the writer and of other readers and needs to be closed separately.
+// The segment files the reader has not yet reached are not protected from the retention policy. When they are removed,
+// Next fails with segment.ErrSegmentNotFound. Use WithRetentionFloor with the sequence number of the slowest reader to
+// keep them. The same options as for NewReader can be used.
+func (w *Writer) NewReader(sequenceNumber uint64, options ...ReaderOption) (*Reader, error) {
+	w.mutex.Lock()
+	if w.closed {
+		w.mutex.Unlock()
+		return nil, ErrWriterClosed
+	}
+	directory := path.Dir(w.segmentWriter.FilePath())
+	entryChecksumKey := w.entryChecksumKey
+	w.mutex.Unlock()
+
+	return NewReader(directory, sequenceNumber, slices.Concat([]ReaderOption{
+		WithReaderEntryChecksumKey(entryChecksumKey),
+	}, options, []ReaderOption{
+		WithReadOnly(),
+		withSyncTracker(w.syncTracker),
+	})...)
+}
+
+// Rollover closes the current segment and continues writing to a new segment. This allows sealing a segment at a
+// specific point in time, like before taking a backup. Nothing happens when the current segment is empty.
+func (w *Writer) Rollover() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	if w.segmentEmpty() {
+		return nil
+	}
+	return w.rollover()
+}
+
 // Close closes the underlying writer.
 func (w *Writer) Close() error {
 	w.mutex.Lock()
 	defer w.mutex.Unlock()
 
+	return w.close()
+}
+
+// CloseContext closes the underlying writer. It behaves the same way as Close, but gives up waiting for the writer or
+// the final flush when the context is done. In that case, the error of the context is returned and closing continues
+// in the background.
+func (w *Writer) CloseContext(ctx context.Context) error {
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+
+	result := make(chan error, 1)
+	go func() {
+		defer w.mutex.Unlock()
+		result <- w.close()
+	}()
+
+	select {
+	case err := <-result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// close shuts down the sync policy and closes the segment. The caller must hold the mutex.
+func (w *Writer) close() error {
+	w.stopRetention()
+	// The writer might already be closed because a truncation failed, but it is still registered in that case.
+	defer unregisterSyncTracker(w.liveSyncTrackerKey, w.syncTracker)
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	w.stopSegmentAge()
+
 	syncErr := w.syncPolicy.Shutdown()
 	closeErr := w.segmentWriter.Close()
 
 	return errors.Join(syncErr, closeErr)
 }
 
-// rolloverIfNeeded will check if the current offset exceeds the desired maximum segment size and do a rollover then.
+// rolloverIfNeeded will check if the current segment exceeds the desired maximum segment size, entry count or age and
+// do a rollover then.
 func (w *Writer) rolloverIfNeeded() error {
-	if w.segmentWriter.Offset() < w.maxSegmentSize {
-		// We did not yet reach the desired maximum segment size. We can continue with what we have at hand.
+	if w.segmentEmpty() {
+		// We never roll over empty segments, as they would result in duplicate segment file names.
+		return nil
+	}
+	if w.segmentWriter.Offset() < w.maxSegmentSize &&
+		(w.maxSegmentEntries == 0 || w.segmentWriter.NextSequenceNumber()-w.segmentWriter.Header().FirstSequenceNumber < w.maxSegmentEntries) &&
+		(w.maxSegmentAge == 0 || w.segmentStartTime.IsZero() || time.Since(w.segmentStartTime) < w.maxSegmentAge) {
+		// We did not yet reach any of the limits. We can continue with what we have at hand.
 		return nil
 	}
 
 	return w.rollover()
 }
 
+// segmentEmpty reports if the current segment does not contain any entries.
+func (w *Writer) segmentEmpty() bool {
+	return w.segmentWriter.NextSequenceNumber() == w.segmentWriter.Header().FirstSequenceNumber
+}
+
+// startSegmentAge remembers the time the first entry was appended to the current segment and starts the timer for the
+// rollover by age.
+func (w *Writer) startSegmentAge() {
+	w.segmentStartTime = time.Now()
+	if w.maxSegmentAge == 0 {
+		return
+	}
+	w.rolloverTimer = time.AfterFunc(w.maxSegmentAge, w.rolloverOnAge)
+}
+
+// stopSegmentAge resets the time the first entry was appended to the current segment and stops the timer for the
+// rollover by age.
+func (w *Writer) stopSegmentAge() {
+	w.segmentStartTime = time.Time{}
+	if w.rolloverTimer != nil {
+		w.rolloverTimer.Stop()
+		w.rolloverTimer = nil
+	}
+}
+
+// rolloverOnAge is called by the rollover timer when the current segment reached the maximum segment age.
+func (w *Writer) rolloverOnAge() {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return
+	}
+	if err := w.rolloverIfNeeded(); err != nil {
+		log.Printf("WARNING: Segment rollover by age failed: %s\n", err)
+	}
+}
+
 // rollover closes the current writer and creates a new segment to write to.
 func (w *Writer) rollover() error {
 	RolloverTotal.Inc()
 	start := time.Now()
 
 	previousSegment := w.segmentWriter.Header().FirstSequenceNumber
+	w.stopSegmentAge()
 
 	if err := w.syncPolicy.Shutdown(); err != nil {
 		return err
@@ -223,28 +1087,158 @@ func (w *Writer) rollover() error {
 	if err := w.segmentWriter.Close(); err != nil {
 		return err
 	}
+	if err := w.createSegment(); err != nil {
+		return err
+	}
+
+	nextSegment := w.segmentWriter.Header().FirstSequenceNumber
+	w.rolloverCallback(previousSegment, nextSegment)
+	w.signalRetention()
 
+	duration := time.Since(start).Seconds()
+	if duration > 1.0 {
+		log.Printf("WARNING: Segment rollover needed %f seconds which is too slow.\n", duration)
+	}
+	RolloverDuration.Observe(duration)
+	return nil
+}
+
+// replaceEmptySegment replaces the current segment file, which must not contain any entries, with a new segment file
+// with the current configuration. The new segment file is renamed over the current one, so that there is always a
+// segment file for the next sequence number. This is not a rollover, so the rollover callback is not called.
+func (w *Writer) replaceEmptySegment() error {
+	if err := w.syncPolicy.Shutdown(); err != nil {
+		return err
+	}
+	if err := w.segmentWriter.Close(); err != nil {
+		return err
+	}
+	return w.createSegment()
+}
+
+// createSegment creates a new segment for the next sequence number of the current segment, which must already be
+// closed. The sync policy is started with the new segment.
+func (w *Writer) createSegment() error {
+	// The new segment continues the entry chain of the current segment.
+	metadata := w.metadata
+	if w.entryChecksumType.IsChained() {
+		metadata.PreviousDigest = w.segmentWriter.Digest()
+	}
 	nextSegmentWriter, err := segment.CreateSegment(path.Dir(w.segmentWriter.FilePath()), w.segmentWriter.NextSequenceNumber(), segment.CreateSegmentConfig{
 		PreAllocationSize:   w.preAllocationSize,
 		EntryLengthEncoding: w.entryLengthEncoding,
 		EntryChecksumType:   w.entryChecksumType,
+		Compression:         w.compression,
+		Metadata:            metadata,
+		EntryChecksumKey:    w.entryChecksumKey,
 	})
 	if err != nil {
 		return err
 	}
 	w.segmentWriter = nextSegmentWriter
+	w.segmentWriter.SetCompressionThreshold(w.compressionThreshold)
 
-	if err := w.syncPolicy.Startup(w.segmentWriter); err != nil {
-		return err
+	return w.syncPolicy.Startup(w.segmentWriter, w.syncTracker)
+}
+
+// startRetention starts the Go routine applying the retention policy after every rollover. Removing segments happens
+// in the background, so that appending entries does not wait for listing and removing segment files. Nothing is
+// started for RetentionPolicyNone, as it never removes any segments.
+func (w *Writer) startRetention() {
+	if _, ok := w.retentionPolicy.(*RetentionPolicyNone); ok {
+		return
 	}
 
-	nextSegment := w.segmentWriter.Header().FirstSequenceNumber
-	w.rolloverCallback(previousSegment, nextSegment)
+	var ctx context.Context
+	ctx, w.retentionCancel = context.WithCancel(context.Background())
+	w.retentionSignal = make(chan struct{}, 1)
+	go w.retentionTask(ctx, path.Dir(w.segmentWriter.FilePath()))
+}
 
-	duration := time.Since(start).Seconds()
-	if duration > 1.0 {
-		log.Printf("WARNING: Segment rollover needed %f seconds which is too slow.\n", duration)
+// stopRetention stops the retention Go routine. We do not wait for the Go routine to finish, because the retention
+// floor might be waiting for the writer lock. The Go routine does not remove any segments after this call.
+func (w *Writer) stopRetention() {
+	if w.retentionCancel != nil {
+		w.retentionCancel()
+	}
+}
+
+// signalRetention tells the retention Go routine that a rollover happened.
+func (w *Writer) signalRetention() {
+	if w.retentionSignal == nil {
+		return
+	}
+	select {
+	case w.retentionSignal <- struct{}{}:
+	default:
+		// There is already a signal pending, which covers this rollover as well.
+	}
+}
+
+func (w *Writer) retentionTask(ctx context.Context, directory string) {
+	for {
+		select {
+		case <-w.retentionSignal:
+			w.applyRetentionPolicy(ctx, directory)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// applyRetentionPolicy removes the segments which are not needed anymore according to the retention policy and the
+// retention floor. The retention policy and the retention floor are consulted without holding the writer lock. Only
+// removing the segments happens under the writer lock, so that it does not run concurrently with a rollover or
+// truncation. Failing to remove segments is not fatal for the writer, so we only log those errors.
+func (w *Writer) applyRetentionPolicy(ctx context.Context, directory string) {
+	segmentInfos, err := GetSegmentInfos(directory)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("WARNING: Applying retention policy %s failed: %s\n", w.retentionPolicy, err)
+		}
+		return
+	}
+	if err := w.adjustActiveSegmentSize(ctx, segmentInfos); err != nil {
+		// The writer was closed in the meantime.
+		return
+	}
+	retainFrom := min(w.retentionPolicy.RetainFrom(segmentInfos), w.retentionFloor())
+
+	if err := w.mutex.LockContext(ctx); err != nil {
+		// The writer was closed in the meantime.
+		return
+	}
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return
+	}
+	if err := segment.RemoveBefore(directory, retainFrom); err != nil {
+		log.Printf("WARNING: Applying retention policy %s failed: %s\n", w.retentionPolicy, err)
+		return
+	}
+}
+
+// adjustActiveSegmentSize replaces the file size of the segment the writer is currently writing to with the number of
+// bytes written to it. The file size of the active segment includes the pre-allocated space, which would otherwise
+// count towards the retention budget and lead to older segments being removed too early. When the active segment
+// changed after the segment infos were collected, the segment infos are left untouched.
+func (w *Writer) adjustActiveSegmentSize(ctx context.Context, segmentInfos []SegmentInfo) error {
+	if len(segmentInfos) == 0 {
+		return nil
+	}
+
+	if err := w.mutex.LockContext(ctx); err != nil {
+		return err
+	}
+	defer w.mutex.Unlock()
+
+	if w.closed {
+		return ErrWriterClosed
+	}
+	activeSegment := &segmentInfos[len(segmentInfos)-1]
+	if activeSegment.FirstSequenceNumber == w.segmentWriter.Header().FirstSequenceNumber {
+		activeSegment.Size = min(activeSegment.Size, w.segmentWriter.Offset())
 	}
-	RolloverDuration.Observe(duration)
 	return nil
 }
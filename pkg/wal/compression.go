@@ -0,0 +1,18 @@
+package wal
+
+import intencoding "github.com/backbone81/write-ahead-log/internal/encoding"
+
+// Compression describes how entries are compressed.
+type Compression = intencoding.Compression
+
+const (
+	CompressionNone      = intencoding.CompressionNone
+	CompressionFlate     = intencoding.CompressionFlate
+	CompressionFlateFast = intencoding.CompressionFlateFast
+)
+
+// ErrCompressionUnsupported is returned for segment files with a compression which is not supported.
+var ErrCompressionUnsupported = intencoding.ErrCompressionUnsupported
+
+// ErrCompressedDataInvalid is returned when the data of a compressed entry cannot be decompressed.
+var ErrCompressedDataInvalid = intencoding.ErrCompressedDataInvalid
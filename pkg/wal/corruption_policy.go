@@ -0,0 +1,26 @@
+package wal
+
+import (
+	intsegment "github.com/backbone81/write-ahead-log/internal/segment"
+	intwal "github.com/backbone81/write-ahead-log/internal/wal"
+)
+
+// CorruptionPolicy describes how a reader deals with entries which can not be read.
+type CorruptionPolicy = intwal.CorruptionPolicy
+
+const (
+	CorruptionPolicyStrict       = intwal.CorruptionPolicyStrict
+	CorruptionPolicySkipCorrupt  = intwal.CorruptionPolicySkipCorrupt
+	CorruptionPolicyTruncateTail = intwal.CorruptionPolicyTruncateTail
+)
+
+// WithCorruptionPolicy overwrites the default corruption policy CorruptionPolicyStrict.
+var WithCorruptionPolicy = intwal.WithCorruptionPolicy
+
+// EntryError describes why an entry could not be read from a segment file. It carries the path of the segment file,
+// the offset of the entry and its sequence number. Use errors.As to access it.
+type EntryError = intsegment.EntryError
+
+// ErrEntryCorrupt is returned by Reader.Err when an entry in a sealed segment or an entry followed by valid entries can
+// not be read. In contrast to ErrEntryTorn, this can never be the result of a crash while writing the entry.
+var ErrEntryCorrupt = intsegment.ErrEntryCorrupt
@@ -1,7 +1,8 @@
 // Package wal provides the implementation of a general purpose write-ahead log.
 //
 //   - The write-ahead log is made up of individual entries, which contain arbitrary data as a payload and metadata
-//     in the form of payload length and a checksum.
+//     in the form of payload length, flags and a checksum. Multiple entries can be grouped into a batch which is
+//     written atomically.
 //   - Entries are stored in segment files. Each segment file consists of a file header describing some details of the
 //     entries stored in the segment. After the file header, the entries follow one after the other.All segment files
 //     are assumed to be located in the same directory. Every segment file has the sequence number of its first entry
@@ -6,6 +6,15 @@ import intencoding "github.com/backbone81/write-ahead-log/internal/encoding"
 type EntryChecksumType = intencoding.EntryChecksumType
 
 const (
-	EntryChecksumTypeCrc32 = intencoding.EntryChecksumTypeCrc32
-	EntryChecksumTypeCrc64 = intencoding.EntryChecksumTypeCrc64
+	EntryChecksumTypeCrc32    = intencoding.EntryChecksumTypeCrc32
+	EntryChecksumTypeCrc64    = intencoding.EntryChecksumTypeCrc64
+	EntryChecksumTypeCrc32c   = intencoding.EntryChecksumTypeCrc32c
+	EntryChecksumTypeXXHash64 = intencoding.EntryChecksumTypeXXHash64
+
+	EntryChecksumTypeSha256Chain     = intencoding.EntryChecksumTypeSha256Chain
+	EntryChecksumTypeHmacSha256Chain = intencoding.EntryChecksumTypeHmacSha256Chain
 )
+
+// ErrEntryChecksumKeyRequired is returned when reading or writing segment files with EntryChecksumTypeHmacSha256Chain
+// without a key.
+var ErrEntryChecksumKeyRequired = intencoding.ErrEntryChecksumKeyRequired
@@ -0,0 +1,37 @@
+package wal
+
+import (
+	intencoding "github.com/backbone81/write-ahead-log/internal/encoding"
+	intsegment "github.com/backbone81/write-ahead-log/internal/segment"
+	intwal "github.com/backbone81/write-ahead-log/internal/wal"
+)
+
+// EntryType describes what kind of data an entry holds. The entry types starting with EntryTypeControl are reserved for
+// control records written by the library itself.
+type EntryType = intencoding.EntryType
+
+const (
+	EntryTypeData       = intencoding.EntryTypeData
+	EntryTypeControl    = intencoding.EntryTypeControl
+	EntryTypeCheckpoint = intencoding.EntryTypeCheckpoint
+)
+
+// EntryHeader is a single key value attached to an entry.
+type EntryHeader = intencoding.EntryHeader
+
+// TypedEntry is an entry which carries an entry type, flags and headers next to its data. Append it with
+// Writer.AppendTypedEntry.
+type TypedEntry = intsegment.TypedEntry
+
+// Value is an entry returned by Reader.Value and ReverseReader.Value.
+type Value = intsegment.SegmentReaderValue
+
+// ErrEntryTypeReserved is returned by Writer.AppendTypedEntry for entry types which are reserved for control records.
+var ErrEntryTypeReserved = intwal.ErrEntryTypeReserved
+
+// ErrEntryEnvelopeInvalid is returned when the entry type, flags and headers of an entry can not be written or read.
+var ErrEntryEnvelopeInvalid = intencoding.ErrEntryEnvelopeInvalid
+
+// WithControlRecords returns control records like checkpoints written with Writer.AppendCheckpoint from Reader.Next
+// and ReverseReader.Next instead of skipping them.
+var WithControlRecords = intwal.WithControlRecords
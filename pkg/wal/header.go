@@ -0,0 +1,12 @@
+package wal
+
+import intencoding "github.com/backbone81/write-ahead-log/internal/encoding"
+
+// Header describes the segment file header which is located at the start of every segment file.
+type Header = intencoding.Header
+
+// HeaderMetadata holds additional information about a segment file, like the time it was created and who wrote it.
+type HeaderMetadata = intencoding.HeaderMetadata
+
+// ErrHeaderInvalidMetadata is returned when the metadata of a segment file header can not be written or read.
+var ErrHeaderInvalidMetadata = intencoding.ErrHeaderInvalidMetadata
@@ -1,6 +1,9 @@
 package wal
 
-import intwal "github.com/backbone81/write-ahead-log/internal/wal"
+import (
+	intsegment "github.com/backbone81/write-ahead-log/internal/segment"
+	intwal "github.com/backbone81/write-ahead-log/internal/wal"
+)
 
 // Reader provides functionality to read the write-ahead log. It abstracts away the fact that the write-ahead log is
 // split into multiple segments.
@@ -11,4 +14,78 @@ type Reader = intwal.Reader
 
 // NewReader creates a new Reader starting at the given sequence number. It will find the segment the sequence number
 // belongs to and read all entries up until the requested sequence number.
+// While a writer in the same process appends to the directory, the reader only returns entries which that writer
+// flushed to stable storage, the same way as a reader created with Writer.NewReader. Entries which are written but not
+// yet flushed are reported as not written. This is not possible for a writer in a different process, so the reader
+// returns all entries written by it.
 var NewReader = intwal.NewReader
+
+// ReaderOption describes the function signature which all reader options need to implement.
+type ReaderOption = intwal.ReaderOption
+
+// WithPollInterval overwrites the default interval in which Reader.NextWait checks for new entries of a writer in a
+// different process.
+var WithPollInterval = intwal.WithPollInterval
+
+// ErrEntryNotWritten is returned by Reader.Err when there are no more entries. This is the case at the end of the
+// written entries.
+var ErrEntryNotWritten = intsegment.ErrEntryNotWritten
+
+// ErrEntryTorn is returned by Reader.Err when there is data which is not a valid entry. This is the case for entries
+// which were only partially written or are corrupt.
+var ErrEntryTorn = intsegment.ErrEntryTorn
+
+// WithReadOnly opens all segment files read-only. This allows reading the write-ahead log on read-only file systems or
+// without write permissions. A read-only reader can not be converted into a writer.
+var WithReadOnly = intwal.WithReadOnly
+
+// WithReaderEntryChecksumKey provides the key for reading segment files with the entry checksum type
+// EntryChecksumTypeHmacSha256Chain.
+var WithReaderEntryChecksumKey = intwal.WithReaderEntryChecksumKey
+
+// ErrSegmentNotFound is returned when a segment file does not exist, like when a reader falls behind a retention policy
+// which removed the segment files the reader did not yet reach.
+var ErrSegmentNotFound = intsegment.ErrSegmentNotFound
+
+// ErrReadOnly is returned by Reader.ToWriter when the reader was created with WithReadOnly.
+var ErrReadOnly = intsegment.ErrReadOnly
+
+// ReverseReader provides functionality to read the write-ahead log backward, from the newest entry to the oldest entry.
+// It abstracts away the fact that the write-ahead log is split into multiple segments.
+//
+// Instances of this struct are NOT safe for concurrent use. Either use it on a single Go routine or provide your own
+// external synchronization.
+type ReverseReader = intwal.ReverseReader
+
+// NewReverseReader creates a new ReverseReader starting at the given sequence number. Use math.MaxUint64 to start at
+// the newest entry. The same options as for NewReader can be used. While a writer in the same process appends to the
+// directory, the reader starts at the newest entry flushed by that writer at the latest.
+var NewReverseReader = intwal.NewReverseReader
+
+// Entries returns an iterator over all entries with a sequence number from "from" up to but excluding "to". The
+// segment files are closed when the iteration ends. The returned function reports the error which ended the iteration
+// before reaching "to".
+var Entries = intwal.Entries
+
+// WithMmap maps sealed segment files into memory instead of reading them. Entries are decoded from the mapping without
+// any read calls, and the data of the entries points into the mapping without being copied.
+var WithMmap = intwal.WithMmap
+
+// DefaultReadBufferSize is the size in bytes of the buffer for reading segment files when not configured otherwise.
+const DefaultReadBufferSize = intsegment.DefaultReadBufferSize
+
+// WithReadBufferSize overwrites the default size of the buffer for reading segment files. A size of zero disables the
+// buffer.
+var WithReadBufferSize = intwal.WithReadBufferSize
+
+// WithReadAhead reads the next chunk of the segment file on a separate Go routine while the entries of the current
+// chunk are decoded.
+var WithReadAhead = intwal.WithReadAhead
+
+// Replay returns an iterator over all entries starting at the sequence number "from" up to the end of the written
+// entries. The sealed segments are decoded and their checksums are verified concurrently on the given number of worker
+// Go routines, while the entries are still yielded strictly in sequence order. A number of workers of zero or less uses
+// one worker per CPU. The sealed segments are decoded in chunks of about 1 MiB, and at most twice the number of workers
+// chunks are decoded ahead, which limits the memory needed. The returned function reports the error which ended the
+// iteration.
+var Replay = intwal.Replay
@@ -5,3 +5,9 @@ import intsegment "github.com/backbone81/write-ahead-log/internal/segment"
 // GetSegments returns a list of sequence numbers representing the start of the corresponding segment. The sequence
 // numbers are sorted in ascending order.
 var GetSegments = intsegment.GetSegments
+
+// RemoveBefore removes all segment files from the directory which only contain entries with a sequence number below
+// the given sequence number. This allows reclaiming disk space for entries which are not needed anymore, like after
+// taking a checkpoint. The newest segment file and the segment file containing the sequence number are never removed.
+// It is safe to call while a writer is appending to the same directory. See also Writer.RemoveBefore.
+var RemoveBefore = intsegment.RemoveBefore
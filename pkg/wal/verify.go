@@ -0,0 +1,18 @@
+package wal
+
+import intwal "github.com/backbone81/write-ahead-log/internal/wal"
+
+// VerifyResult describes the entry chain which was verified by Verify.
+type VerifyResult = intwal.VerifyResult
+
+// Verify reads all entries of the write-ahead log and proves that they form an unbroken entry chain. All segments need
+// to use EntryChecksumTypeSha256Chain or EntryChecksumTypeHmacSha256Chain. Store the last digest and the next sequence
+// number of the result outside the write-ahead log to detect entries removed from the end.
+var Verify = intwal.Verify
+
+// ErrEntryChainBroken is returned when an entry was altered, removed or reordered, or when a segment does not continue
+// the entry chain of the previous segment.
+var ErrEntryChainBroken = intwal.ErrEntryChainBroken
+
+// ErrEntryChainUnsupported is returned by Verify when a segment does not use a chained entry checksum type.
+var ErrEntryChainUnsupported = intwal.ErrEntryChainUnsupported
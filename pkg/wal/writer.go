@@ -1,6 +1,9 @@
 package wal
 
-import intwal "github.com/backbone81/write-ahead-log/internal/wal"
+import (
+	intsegment "github.com/backbone81/write-ahead-log/internal/segment"
+	intwal "github.com/backbone81/write-ahead-log/internal/wal"
+)
 
 // Writer provides the main functionality for writing to the write-ahead log. It abstracts away the fact that the WAL
 // is distributed over several segment files and does rollover into new segments as necessary.
@@ -11,6 +14,31 @@ import intwal "github.com/backbone81/write-ahead-log/internal/wal"
 // writing to the write-ahead log.
 type Writer = intwal.Writer
 
+// ErrWriterClosed is returned when appending to a writer which is already closed.
+var ErrWriterClosed = intwal.ErrWriterClosed
+
+// ErrTruncateBeyondEnd is returned by Writer.TruncateAfter when the WAL does not contain the sequence number.
+var ErrTruncateBeyondEnd = intwal.ErrTruncateBeyondEnd
+
+// ErrTruncated resolves the SyncFuture of an entry which was removed by Writer.TruncateAfter before it was flushed.
+var ErrTruncated = intwal.ErrTruncated
+
+// ErrTruncateInBatch is returned by Writer.TruncateAfter when the sequence number is in the middle of a batch.
+var ErrTruncateInBatch = intsegment.ErrTruncateInBatch
+
+// SequenceNumberMismatchError is returned by Writer.AppendEntryAt when the next sequence number of the WAL is not the
+// expected one.
+type SequenceNumberMismatchError = intwal.SequenceNumberMismatchError
+
+// BackpressureError is returned when appending fails, because the number of entries or bytes not yet flushed to stable
+// storage reached the limits configured with WithMaxUnsyncedEntries or WithMaxUnsyncedBytes. This only happens when
+// WithFailFastOnBackpressure is used.
+type BackpressureError = intwal.BackpressureError
+
+// SyncFuture is handed out for entries which were appended asynchronously. It is resolved as soon as the sync policy
+// has flushed the entry to stable storage.
+type SyncFuture = intwal.SyncFuture
+
 // WriterOption describes the function signature which all writer options need to implement.
 type WriterOption = intwal.WriterOption
 
@@ -22,6 +50,16 @@ var WithPreAllocationSize = intwal.WithPreAllocationSize
 // Can be used with Reader.ToWriter.
 var WithMaxSegmentSize = intwal.WithMaxSegmentSize
 
+// WithMaxSegmentAge causes rollover into a new segment when the first entry in the current segment was appended longer
+// ago than the given duration. The rollover happens in the background, even when no further entries are appended.
+// Can be used with Reader.ToWriter.
+var WithMaxSegmentAge = intwal.WithMaxSegmentAge
+
+// WithMaxSegmentEntries causes rollover into a new segment when the current segment contains the given number of
+// entries.
+// Can be used with Reader.ToWriter.
+var WithMaxSegmentEntries = intwal.WithMaxSegmentEntries
+
 // WithEntryLengthEncoding overwrites the default entry length encoding.
 // Can be used with Init and Reader.ToWriter.
 var WithEntryLengthEncoding = intwal.WithEntryLengthEncoding
@@ -30,6 +68,31 @@ var WithEntryLengthEncoding = intwal.WithEntryLengthEncoding
 // Can be used with Init and Reader.ToWriter.
 var WithEntryChecksumType = intwal.WithEntryChecksumType
 
+// WithEntryChecksumKey provides the secret key for the entry checksum type EntryChecksumTypeHmacSha256Chain.
+// Can be used with Init and Reader.ToWriter.
+var WithEntryChecksumKey = intwal.WithEntryChecksumKey
+
+// WithCompression compresses entries bigger than the compression threshold with the given compression. The compression
+// is stored in the header of new segment files.
+// Can be used with Init and Reader.ToWriter.
+var WithCompression = intwal.WithCompression
+
+// WithCompressionThreshold overwrites the default entry size in bytes above which entries are compressed.
+// Can be used with Reader.ToWriter.
+var WithCompressionThreshold = intwal.WithCompressionThreshold
+
+// WithWriterID stores the given identifier of the writer in the header of new segment files.
+// Can be used with Init and Reader.ToWriter.
+var WithWriterID = intwal.WithWriterID
+
+// WithApplicationID stores the given identifier of the application in the header of new segment files.
+// Can be used with Init and Reader.ToWriter.
+var WithApplicationID = intwal.WithApplicationID
+
+// WithUserMetadata stores the given key value in the header of new segment files.
+// Can be used with Init and Reader.ToWriter.
+var WithUserMetadata = intwal.WithUserMetadata
+
 // WithSyncPolicyNone overwrites the default sync policy with sync policy none.
 // Can be used with Reader.ToWriter.
 var WithSyncPolicyNone = intwal.WithSyncPolicyNone
@@ -46,6 +109,54 @@ var WithSyncPolicyPeriodic = intwal.WithSyncPolicyPeriodic
 // Can be used with Reader.ToWriter.
 var WithSyncPolicyGrouped = intwal.WithSyncPolicyGrouped
 
+// WithMaxUnsyncedEntries limits the number of entries which are appended but not yet flushed to stable storage. When
+// the limit is reached, the next append asks the sync policy to flush all entries before appending.
+// Can be used with Reader.ToWriter.
+var WithMaxUnsyncedEntries = intwal.WithMaxUnsyncedEntries
+
+// WithMaxUnsyncedBytes limits the number of bytes which are appended but not yet flushed to stable storage. When the
+// limit is reached, the next append asks the sync policy to flush all entries before appending.
+// Can be used with Reader.ToWriter.
+var WithMaxUnsyncedBytes = intwal.WithMaxUnsyncedBytes
+
+// WithFailFastOnBackpressure causes appending to fail with a BackpressureError instead of flushing, when the limits
+// of WithMaxUnsyncedEntries or WithMaxUnsyncedBytes are reached.
+// Can be used with Reader.ToWriter.
+var WithFailFastOnBackpressure = intwal.WithFailFastOnBackpressure
+
 // WithRolloverCallback sets the given callback for being triggered when the current segment is rolled.
 // Can be used with Reader.ToWriter.
 var WithRolloverCallback = intwal.WithRolloverCallback
+
+// RetentionPolicy is the interface every retention policy needs to implement. The writer consults the retention policy
+// after every rollover and removes the segment files which are not needed anymore.
+type RetentionPolicy = intwal.RetentionPolicy
+
+// SegmentInfo describes a segment file for the retention policy to decide on.
+type SegmentInfo = intwal.SegmentInfo
+
+// RetentionFloor returns the lowest sequence number which is still needed by the caller. Retention policies never
+// remove segments containing entries at or above that sequence number.
+type RetentionFloor = intwal.RetentionFloor
+
+// WithRetentionPolicy overwrites the default retention policy with the given retention policy. Use this for providing
+// your own retention policy.
+// Can be used with Reader.ToWriter.
+var WithRetentionPolicy = intwal.WithRetentionPolicy
+
+// WithRetentionPolicyMaxBytes overwrites the default retention policy with retention policy max bytes.
+// Can be used with Reader.ToWriter.
+var WithRetentionPolicyMaxBytes = intwal.WithRetentionPolicyMaxBytes
+
+// WithRetentionPolicyMaxAge overwrites the default retention policy with retention policy max age.
+// Can be used with Reader.ToWriter.
+var WithRetentionPolicyMaxAge = intwal.WithRetentionPolicyMaxAge
+
+// WithRetentionPolicyMaxSegments overwrites the default retention policy with retention policy max segments.
+// Can be used with Reader.ToWriter.
+var WithRetentionPolicyMaxSegments = intwal.WithRetentionPolicyMaxSegments
+
+// WithRetentionFloor sets the callback which provides the lowest sequence number still needed. The retention policy
+// never removes segments containing entries at or above that sequence number.
+// Can be used with Reader.ToWriter.
+var WithRetentionFloor = intwal.WithRetentionFloor